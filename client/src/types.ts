@@ -6,23 +6,95 @@ export interface Event {
   description: string;
   date: string;
   venue: string;
+  venue_id?: string; // venue whose seat map the event uses
   capacity: number;
+  max_held_per_user: number; // 0 means unlimited
+  max_tickets_per_user: number; // 0 means unlimited
+  currency: string; // ISO 4217 code of the ticket prices
+  refundable: boolean;
+  refund_cutoff_hours: number; // refunds close this many hours before the event
+  refund_fee_per_ticket: number;
+  restock_refunds: boolean; // refunded seats go back on sale
   created_at: string;
   updated_at: string;
 }
 
+export interface VenueSeat {
+  id: string;
+  number: number;
+  label: string; // e.g. VIP-A12, the seat of the event's tickets
+  x: number; // position on the seat map drawing
+  y: number;
+  accessible: boolean;
+  restricted_view: boolean;
+  aisle: boolean;
+}
+
+export interface VenueRow {
+  id: string;
+  name: string;
+  seats: VenueSeat[];
+}
+
+export interface VenueSection {
+  id: string;
+  name: string;
+  rows: VenueRow[];
+}
+
+export interface Venue {
+  id: string;
+  name: string;
+  address: string;
+  capacity: number;
+  sections?: VenueSection[]; // only for a single venue
+  created_at: string;
+  updated_at: string;
+}
+
+export interface LayoutRow {
+  name: string;
+  from: number; // first seat number
+  to: number; // last seat number, inclusive
+}
+
+export interface LayoutSection {
+  name: string; // prefixes seat labels, e.g. VIP-A1
+  price: number;
+  rows: LayoutRow[];
+}
+
+export interface SeatLayout {
+  sections: LayoutSection[];
+}
+
 export interface Ticket {
   id: string;
   event_id: string;
   user_id: string;
-  seat: string;
-  status: 'available' | 'reserved' | 'sold';
+  seat_id?: string; // venue seat, for events at a venue
+  tier_id?: string;
+  seat: string; // seat label, or e.g. Floor-17 for general admission
+  status: 'available' | 'reserved' | 'sold' | 'refunded' | 'checked_in';
   price: number;
+  hold_id?: string;
   reserved_until?: string;
   created_at: string;
   updated_at: string;
 }
 
+export interface TicketTier {
+  id: string;
+  event_id: string;
+  name: string;
+  kind: 'reserved' | 'general_admission';
+  price: number; // general admission only
+  quantity?: number;
+  sections?: string[];
+  available?: number; // general-admission tickets left
+  created_at: string;
+}
+
 export interface User {
   id: string;
   email: string;
@@ -38,6 +110,12 @@ export interface Seat {
   status: 'available' | 'reserved' | 'sold';
 }
 
+export interface SeatAvailability {
+  seat: string;
+  status: 'available' | 'held' | 'sold';
+  price: number;
+}
+
 export interface CartItem {
   eventId: string;
   seat: string;
@@ -4,16 +4,22 @@ import (
 	"context"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/flashtix/server/db"
+	"github.com/flashtix/server/internal/domain"
 	"github.com/flashtix/server/internal/handlers"
 	"github.com/flashtix/server/internal/middleware"
+	"github.com/flashtix/server/internal/payments"
+	"github.com/flashtix/server/internal/repository/memory"
 	"github.com/flashtix/server/internal/repository/postgres"
 	"github.com/flashtix/server/internal/repository/redis"
 	"github.com/flashtix/server/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/shopspring/decimal"
 )
 
 func main() {
@@ -22,6 +28,9 @@ func main() {
 		log.Println("No .env file found")
 	}
 
+	// Amounts go out as JSON numbers, written exactly as stored
+	decimal.MarshalJSONWithoutQuotes = true
+
 	// Initialize Prisma Client
 	client := db.NewClient()
 	if err := client.Prisma.Connect(); err != nil {
@@ -38,28 +47,40 @@ func main() {
 		log.Println("Warning: Could not create uuid extension:", err)
 	}
 
-	// Redis connection (Upstash)
-	redisURL := os.Getenv("UPSTASH_REDIS_REST_URL")
-	redisToken := os.Getenv("UPSTASH_REDIS_REST_TOKEN")
-
-	if redisURL == "" || redisToken == "" {
-		log.Println("Warning: UPSTASH_REDIS_REST_URL and UPSTASH_REDIS_REST_TOKEN not set")
-		log.Println("Redis features (seat locking) will not work properly")
-		redisURL = "https://dummy.upstash.io" // dummy URL to prevent panic
-		redisToken = "dummy"
-	}
-
 	// Repositories
 	eventRepo := postgres.NewEventRepository(client)
 	ticketRepo := postgres.NewTicketRepository(client)
-	seatLockRepo := redis.NewSeatLockRepository(redisURL, redisToken)
+	holdRepo := postgres.NewHoldRepository(client)
+	orderRepo := postgres.NewOrderRepository(client)
+	refundRepo := postgres.NewRefundRepository(client)
+	venueRepo := postgres.NewVenueRepository(client)
+	tierRepo := postgres.NewTicketTierRepository(client)
+	paymentEventRepo := postgres.NewPaymentEventRepository(client)
+	backend := redisBackend()
+	seatLockRepo := newSeatLocker(backend)
+	waitingRoom := newWaitingRoom(backend)
+	rateLimiter := newRateLimiter(backend)
+	idempotencyStore := newIdempotencyStore(backend)
 
 	// Services
-	ticketService := services.NewTicketService(ticketRepo, eventRepo, seatLockRepo)
+	paymentProvider := newPaymentProvider()
+	ticketService := services.NewTicketService(ticketRepo, eventRepo, holdRepo, tierRepo, orderRepo, seatLockRepo, paymentProvider, orderPricingFromEnv())
+
+	refundService := services.NewRefundService(orderRepo, eventRepo, refundRepo, seatLockRepo, paymentProvider, ticketService.SeatEvents())
+
+	// Background workers
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	go services.NewReservationSweeper(ticketRepo, holdRepo, orderRepo, seatLockRepo, paymentProvider, ticketService.SeatEvents()).Run(workerCtx)
+	if waitingRoom != nil {
+		go waitingRoom.Run(workerCtx)
+	}
 
 	// Handlers
-	ticketHandler := handlers.NewTicketHandler(ticketService)
-	eventHandler := handlers.NewEventHandler(eventRepo)
+	ticketHandler := handlers.NewTicketHandler(ticketService, waitingRoom)
+	eventHandler := handlers.NewEventHandler(eventRepo, ticketRepo, venueRepo, tierRepo)
+	orderHandler := handlers.NewOrderHandler(orderRepo, refundService)
+	venueHandler := handlers.NewVenueHandler(venueRepo)
 
 	// Router
 	r := gin.Default()
@@ -68,8 +89,26 @@ func main() {
 	r.Use(middleware.CORSMiddleware())
 	r.Use(middleware.LoggingMiddleware())
 
+	// Rate limits per route group, keyed by client IP or, behind auth, by user
+	apiLimit := rateLimitFromEnv("api", "RATE_LIMIT_API", "300/1m")
+	authLimit := rateLimitFromEnv("auth", "RATE_LIMIT_AUTH", "120/1m")
+	reserveLimit := rateLimitFromEnv("reserve", "RATE_LIMIT_RESERVE", "10/1m")
+	// The seat socket's lock and reserve commands share the reserve limit
+	seatSocketHandler := handlers.NewSeatSocketHandler(ticketService, waitingRoom, rateLimiter, reserveLimit, os.Getenv("JWT_SECRET"))
+
 	// Routes
+	// The payment webhook is signed by the provider instead of carrying a
+	// user's JWT, and sits outside /api's per-IP limit since all deliveries
+	// come from a few provider addresses
+	if secret := os.Getenv("STRIPE_WEBHOOK_SECRET"); secret != "" {
+		paymentWebhookHandler := handlers.NewPaymentWebhookHandler(ticketService, paymentEventRepo, secret)
+		r.POST("/api/payments/webhook", paymentWebhookHandler.Receive)
+	} else {
+		log.Println("Warning: STRIPE_WEBHOOK_SECRET is not set, payment webhooks are disabled")
+	}
+
 	api := r.Group("/api")
+	api.Use(middleware.RateLimitMiddleware(rateLimiter, apiLimit))
 	{
 		// Root endpoint untuk informasi API
 		api.GET("/", func(c *gin.Context) {
@@ -77,18 +116,48 @@ func main() {
 				"message": "FlashTix API Server",
 				"version": "1.0.0",
 				"endpoints": gin.H{
-					"GET /api/":                 "API information",
-					"GET /api/events":           "Get all events",
-					"POST /api/events":          "Create new event",
-					"GET /api/redis-test":       "Test Redis connection",
-					"POST /api/tickets/reserve": "Reserve a ticket seat (requires auth)",
-					"POST /api/tickets/confirm": "Confirm ticket purchase (requires auth)",
+					"GET /api/":                         "API information",
+					"GET /api/events":                   "Get all events",
+					"POST /api/admin/events":            "Create new event, with its seats when a layout is given (requires admin)",
+					"GET /api/events/:id/seats":         "Get seat availability for an event",
+					"GET /api/events/:id/tiers":         "Get an event's ticket tiers and general-admission tickets left",
+					"GET /api/events/:id/seats/stream":  "Live seat updates for an event (Server-Sent Events)",
+					"GET /api/ws/seats":                 "Interactive seat selection over WebSocket (auth message required)",
+					"GET /api/redis-test":               "Test Redis connection",
+					"POST /api/events/:id/queue":        "Join the event's waiting room (requires auth, when enabled)",
+					"GET /api/events/:id/queue":         "Waiting room position and estimated wait (requires auth, when enabled)",
+					"POST /api/tickets/reserve":         "Reserve seats or a quantity of a general-admission tier (requires auth)",
+					"POST /api/tickets/confirm":         "Confirm the purchase of seats or tier tickets (requires auth)",
+					"GET /api/holds/:id":                "Get a seat hold and its remaining time (requires auth)",
+					"POST /api/holds/:id/extend":        "Extend a seat hold once (requires auth)",
+					"POST /api/holds/:id/payment":       "Start the payment for a seat hold (requires auth)",
+					"POST /api/holds/:id/confirm":       "Confirm a paid seat hold (requires auth)",
+					"DELETE /api/holds/:id":             "Release a seat hold (requires auth)",
+					"GET /api/orders":                   "List your orders (requires auth)",
+					"GET /api/orders/:id":               "Get one of your orders (requires auth)",
+					"POST /api/orders/:id/refund":       "Refund tickets of one of your orders (requires auth)",
+					"POST /api/admin/orders/:id/refund": "Refund tickets of any order (requires admin)",
+					"POST /api/admin/events/:id/seats":  "Generate an event's seats from a layout or its venue (requires admin)",
+					"POST /api/admin/events/:id/tiers":  "Add a reserved or general-admission ticket tier to an event (requires admin)",
+					"GET /api/venues":                   "List venues",
+					"GET /api/venues/:id":               "Get a venue with its seat map",
+					"POST /api/admin/venues":            "Create a venue with its seat map (requires admin)",
+					"PUT /api/admin/venues/:id":         "Rename a venue or change its address (requires admin)",
+					"DELETE /api/admin/venues/:id":      "Delete a venue no event uses (requires admin)",
+					"POST /api/payments/webhook":        "Payment provider webhook (Stripe-Signature required)",
 				},
 			})
 		})
 
 		api.GET("/events", eventHandler.GetEvents)
-		api.POST("/events", eventHandler.CreateEvent)
+		api.GET("/venues", venueHandler.ListVenues)
+		api.GET("/venues/:id", venueHandler.GetVenue)
+		api.GET("/events/:id/seats", ticketHandler.GetEventSeats)
+		api.GET("/events/:id/seats/stream", ticketHandler.StreamEventSeats)
+		api.GET("/events/:id/tiers", ticketHandler.GetEventTiers)
+		// Authenticates with an auth message after the upgrade, since
+		// browsers cannot set headers on WebSocket requests
+		api.GET("/ws/seats", seatSocketHandler.Serve)
 
 		// Test Redis endpoint
 		api.GET("/redis-test", func(c *gin.Context) {
@@ -96,7 +165,7 @@ func main() {
 			testValue := "Hello from Upstash Redis!"
 
 			// Test SET
-			err := seatLockRepo.LockSeat(ctx, "test", "test", testValue, 60*time.Second)
+			_, _, err := seatLockRepo.LockSeat(ctx, "test", "test", testValue, 60*time.Second)
 			if err != nil {
 				c.JSON(500, gin.H{"error": "Failed to set Redis key", "details": err.Error()})
 				return
@@ -118,12 +187,222 @@ func main() {
 
 		auth := api.Group("")
 		auth.Use(middleware.AuthMiddleware(os.Getenv("JWT_SECRET")))
+		auth.Use(middleware.RateLimitMiddleware(rateLimiter, authLimit))
+		auth.Use(middleware.IdempotencyMiddleware(idempotencyStore, 24*time.Hour))
 		{
-			auth.POST("/tickets/reserve", ticketHandler.ReserveSeat)
+			if waitingRoom != nil {
+				waitingRoomHandler := handlers.NewWaitingRoomHandler(waitingRoom)
+				auth.POST("/events/:id/queue", waitingRoomHandler.Join)
+				auth.GET("/events/:id/queue", waitingRoomHandler.Status)
+			}
+			auth.POST("/tickets/reserve", middleware.RateLimitMiddleware(rateLimiter, reserveLimit), ticketHandler.ReserveSeat)
 			auth.POST("/tickets/confirm", ticketHandler.ConfirmPurchase)
+			auth.GET("/holds/:id", ticketHandler.GetHold)
+			auth.POST("/holds/:id/extend", ticketHandler.ExtendHold)
+			auth.POST("/holds/:id/payment", ticketHandler.StartPayment)
+			auth.POST("/holds/:id/confirm", ticketHandler.ConfirmHold)
+			auth.DELETE("/holds/:id", ticketHandler.ReleaseHold)
+			auth.GET("/orders", orderHandler.ListOrders)
+			auth.GET("/orders/:id", orderHandler.GetOrder)
+			auth.POST("/orders/:id/refund", orderHandler.RequestRefund)
+
+			admin := auth.Group("/admin")
+			admin.Use(middleware.AdminMiddleware(strings.Split(os.Getenv("ADMIN_USER_IDS"), ",")))
+			admin.POST("/orders/:id/refund", orderHandler.AdminRefund)
+			admin.POST("/events", eventHandler.CreateEvent)
+			admin.POST("/events/:id/seats", eventHandler.GenerateSeats)
+			admin.POST("/events/:id/tiers", eventHandler.CreateTier)
+			admin.POST("/venues", venueHandler.CreateVenue)
+			admin.PUT("/venues/:id", venueHandler.UpdateVenue)
+			admin.DELETE("/venues/:id", venueHandler.DeleteVenue)
 		}
 	}
 
 	log.Println("Server starting on :8080")
 	r.Run(":8080")
 }
+
+// redisBackend picks the backend for seat locks, the waiting room and rate
+// limits from SEAT_LOCK_BACKEND (upstash, redis or memory). When unset,
+// Upstash is used if its credentials are present, then REDIS_URL, and
+// finally the in-memory backend.
+func redisBackend() string {
+	backend := os.Getenv("SEAT_LOCK_BACKEND")
+	if backend != "" {
+		return backend
+	}
+	switch {
+	case os.Getenv("UPSTASH_REDIS_REST_URL") != "" && os.Getenv("UPSTASH_REDIS_REST_TOKEN") != "":
+		return "upstash"
+	case os.Getenv("REDIS_URL") != "":
+		return "redis"
+	}
+	return "memory"
+}
+
+func newSeatLocker(backend string) domain.SeatLocker {
+	upstashURL := os.Getenv("UPSTASH_REDIS_REST_URL")
+	upstashToken := os.Getenv("UPSTASH_REDIS_REST_TOKEN")
+	redisURL := os.Getenv("REDIS_URL")
+
+	switch backend {
+	case "upstash":
+		if upstashURL == "" || upstashToken == "" {
+			log.Fatal("SEAT_LOCK_BACKEND=upstash requires UPSTASH_REDIS_REST_URL and UPSTASH_REDIS_REST_TOKEN")
+		}
+		log.Println("Seat locks: Upstash Redis REST")
+		return redis.NewSeatLockRepository(upstashURL, upstashToken)
+	case "redis":
+		if redisURL == "" {
+			log.Fatal("SEAT_LOCK_BACKEND=redis requires REDIS_URL")
+		}
+		locker, err := redis.NewNativeSeatLockRepository(redisURL)
+		if err != nil {
+			log.Fatal("Failed to configure Redis:", err)
+		}
+		log.Println("Seat locks: Redis at", redisURL)
+		return locker
+	case "memory":
+		log.Println("Warning: using in-memory seat locks, which are not shared between server instances")
+		return memory.NewSeatLockRepository()
+	}
+
+	log.Fatalf("Unknown SEAT_LOCK_BACKEND %q (want upstash, redis or memory)", backend)
+	return nil
+}
+
+// newPaymentProvider picks the payment provider from PAYMENT_PROVIDER
+// (stripe or fake). When unset, Stripe is used if STRIPE_SECRET_KEY is
+// present and the fake provider otherwise.
+func newPaymentProvider() domain.PaymentProvider {
+	provider := os.Getenv("PAYMENT_PROVIDER")
+	if provider == "" {
+		provider = "fake"
+		if os.Getenv("STRIPE_SECRET_KEY") != "" {
+			provider = "stripe"
+		}
+	}
+
+	switch provider {
+	case "stripe":
+		key := os.Getenv("STRIPE_SECRET_KEY")
+		if key == "" {
+			log.Fatal("PAYMENT_PROVIDER=stripe requires STRIPE_SECRET_KEY")
+		}
+		return payments.NewStripeProvider(key)
+	case "fake":
+		log.Println("Warning: using the fake payment provider, which authorizes every payment without charging")
+		return payments.NewFakeProvider()
+	}
+
+	log.Fatalf("Unknown PAYMENT_PROVIDER %q (want stripe or fake)", provider)
+	return nil
+}
+
+// orderPricingFromEnv reads the booking fee per ticket of each currency,
+// as in ORDER_FEE_PER_TICKET=USD:2.50,IDR:5000, and the tax rate charged
+// on orders. Events in a currency without a fee are charged none.
+func orderPricingFromEnv() services.OrderPricing {
+	pricing := services.OrderPricing{FeePerTicket: make(map[string]decimal.Decimal)}
+	if raw := os.Getenv("ORDER_FEE_PER_TICKET"); raw != "" {
+		for _, entry := range strings.Split(raw, ",") {
+			code, amount, ok := strings.Cut(strings.TrimSpace(entry), ":")
+			currency, err := domain.NormalizeCurrency(code)
+			if !ok || err != nil {
+				log.Fatalf("Invalid ORDER_FEE_PER_TICKET entry %q (want CURRENCY:AMOUNT)", entry)
+			}
+			fee, err := decimal.NewFromString(amount)
+			if err != nil || fee.IsNegative() {
+				log.Fatalf("Invalid ORDER_FEE_PER_TICKET entry %q", entry)
+			}
+			pricing.FeePerTicket[currency] = fee
+		}
+	}
+	if raw := os.Getenv("ORDER_TAX_RATE"); raw != "" {
+		rate, err := decimal.NewFromString(raw)
+		if err != nil || rate.IsNegative() {
+			log.Fatalf("Invalid ORDER_TAX_RATE %q", raw)
+		}
+		pricing.TaxRate = rate
+	}
+	return pricing
+}
+
+func newRateLimiter(backend string) domain.RateLimiter {
+	switch backend {
+	case "upstash":
+		return redis.NewRateLimitRepository(os.Getenv("UPSTASH_REDIS_REST_URL"), os.Getenv("UPSTASH_REDIS_REST_TOKEN"))
+	case "redis":
+		limiter, err := redis.NewNativeRateLimitRepository(os.Getenv("REDIS_URL"))
+		if err != nil {
+			log.Fatal("Failed to configure Redis:", err)
+		}
+		return limiter
+	}
+	return memory.NewRateLimitRepository()
+}
+
+func newIdempotencyStore(backend string) domain.IdempotencyStore {
+	switch backend {
+	case "upstash":
+		return redis.NewIdempotencyRepository(os.Getenv("UPSTASH_REDIS_REST_URL"), os.Getenv("UPSTASH_REDIS_REST_TOKEN"))
+	case "redis":
+		store, err := redis.NewNativeIdempotencyRepository(os.Getenv("REDIS_URL"))
+		if err != nil {
+			log.Fatal("Failed to configure Redis:", err)
+		}
+		return store
+	}
+	return memory.NewIdempotencyRepository()
+}
+
+// rateLimitFromEnv reads a "<requests>/<window>" limit from the environment
+func rateLimitFromEnv(name, env, fallback string) middleware.RateLimit {
+	value := os.Getenv(env)
+	if value == "" {
+		value = fallback
+	}
+	limit, err := middleware.ParseRateLimit(name, value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", env, err)
+	}
+	return limit
+}
+
+// newWaitingRoom returns nil unless WAITING_ROOM_ENABLED is true. Queues
+// live on the same backend as the seat locks.
+func newWaitingRoom(backend string) *services.WaitingRoom {
+	if os.Getenv("WAITING_ROOM_ENABLED") != "true" {
+		return nil
+	}
+
+	var store domain.WaitingRoomStore
+	switch backend {
+	case "upstash":
+		store = redis.NewWaitingRoomRepository(os.Getenv("UPSTASH_REDIS_REST_URL"), os.Getenv("UPSTASH_REDIS_REST_TOKEN"))
+	case "redis":
+		native, err := redis.NewNativeWaitingRoomRepository(os.Getenv("REDIS_URL"))
+		if err != nil {
+			log.Fatal("Failed to configure Redis:", err)
+		}
+		store = native
+	default:
+		store = memory.NewWaitingRoomRepository()
+	}
+
+	admitRate := 50
+	if value := os.Getenv("WAITING_ROOM_ADMIT_RATE"); value != "" {
+		rate, err := strconv.Atoi(value)
+		if err != nil || rate < 1 {
+			log.Fatalf("Invalid WAITING_ROOM_ADMIT_RATE %q", value)
+		}
+		admitRate = rate
+	}
+	secret := os.Getenv("WAITING_ROOM_SECRET")
+	if secret == "" {
+		secret = os.Getenv("JWT_SECRET")
+	}
+
+	log.Printf("Waiting room: admitting %d buyers per second per event", admitRate)
+	return services.NewWaitingRoom(store, secret, admitRate)
+}
@@ -2,9 +2,73 @@ package domain
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Reasons a reservation, confirmation or release can be refused
+var (
+	ErrEventNotFound       = errors.New("event not found")
+	ErrTicketNotFound      = errors.New("ticket not found")
+	ErrSeatTaken           = errors.New("seat is already reserved")
+	ErrSeatNotReserved     = errors.New("seat is not reserved")
+	ErrReservationNotOwned = errors.New("seat not reserved by this user")
+	ErrReservationExpired  = errors.New("reservation has expired")
+	ErrHoldNotFound        = errors.New("hold not found")
+	ErrHoldExtensionLimit  = errors.New("hold cannot be extended any further")
+	ErrInvalidQueueToken   = errors.New("invalid waiting room token")
+	ErrNotAdmitted         = errors.New("not yet admitted from the waiting room")
+	ErrHoldLimit           = errors.New("too many seats held for this event")
+	ErrPurchaseLimit       = errors.New("ticket limit for this event reached")
+	ErrPaymentRequired     = errors.New("hold has not been paid for")
+	ErrPaymentFailed       = errors.New("payment failed")
+	ErrPaymentNotFound     = errors.New("payment not found")
+	ErrOrderNotFound       = errors.New("order not found")
+	ErrOrderExists         = errors.New("hold already has an order")
+	ErrPaymentConflict     = errors.New("hold already has another payment")
+	ErrOrderNotRefundable  = errors.New("order has no paid tickets to refund")
+	ErrAlreadyRefunded     = errors.New("ticket has already been refunded")
+	ErrTicketNotRefundable = errors.New("ticket is no longer sold and cannot be refunded")
+	ErrRefundNotAllowed    = errors.New("refunds are not offered for this event")
+	ErrRefundWindowClosed  = errors.New("refund window has closed")
+)
+
+// ErrStaleLockToken is returned when a ticket write carries an older fencing
+// token than the one already recorded, i.e. the writer's seat lock expired
+// and someone else has since locked the seat.
+var ErrStaleLockToken = errors.New("seat lock is no longer held")
+
+// SeatResult is the outcome of a conditional seat update
+type SeatResult int
+
+const (
+	SeatOK           SeatResult = iota // the update was applied
+	SeatNotFound                       // no ticket exists for the event and seat
+	SeatTaken                          // the seat is held or sold by someone else, or a newer lock token is recorded
+	SeatNotReserved                    // the seat has no reservation to confirm or release
+	SeatExpired                        // the caller's reservation has lapsed
+	SeatLimitReached                   // the caller already bought the event's ticket limit
 )
 
+// Err maps a failed result to the matching domain error, or nil for SeatOK
+func (r SeatResult) Err() error {
+	switch r {
+	case SeatOK:
+		return nil
+	case SeatNotFound:
+		return ErrTicketNotFound
+	case SeatNotReserved:
+		return ErrSeatNotReserved
+	case SeatExpired:
+		return ErrReservationExpired
+	}
+	return ErrSeatTaken
+}
+
 // Event represents an event entity
 type Event struct {
 	ID          string    `json:"id" gorm:"primaryKey"`
@@ -12,22 +76,138 @@ type Event struct {
 	Description string    `json:"description"`
 	Date        time.Time `json:"date"`
 	Venue       string    `json:"venue"`
+	VenueID     string    `json:"venue_id,omitempty"` // venue whose seat map the event uses, if any
 	Capacity    int       `json:"capacity"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	Currency    string    `json:"currency"` // ISO 4217 code the event's prices are in
+	// Per-user limits, 0 means unlimited
+	MaxHeldPerUser    int `json:"max_held_per_user"`    // seats held at once
+	MaxTicketsPerUser int `json:"max_tickets_per_user"` // seats held and bought in total
+	// Refund policy for buyers; admins may refund at any time and free of charge
+	Refundable         bool            `json:"refundable"`
+	RefundCutoffHours  int             `json:"refund_cutoff_hours"`   // refunds close this many hours before the event
+	RefundFeePerTicket decimal.Decimal `json:"refund_fee_per_ticket"` // kept from each refunded ticket
+	RestockRefunds     bool            `json:"restock_refunds"`       // refunded seats go back on sale
+	CreatedAt          time.Time       `json:"created_at"`
+	UpdatedAt          time.Time       `json:"updated_at"`
 }
 
 // Ticket represents a ticket entity
 type Ticket struct {
-	ID            string     `json:"id" gorm:"primaryKey"`
-	EventID       string     `json:"event_id"`
-	UserID        string     `json:"user_id"`
-	Seat          string     `json:"seat"`
-	Status        string     `json:"status"` // available, reserved, sold
-	Price         float64    `json:"price"`
-	ReservedUntil *time.Time `json:"reserved_until"`
-	CreatedAt     time.Time  `json:"created_at"`
-	UpdatedAt     time.Time  `json:"updated_at"`
+	ID            string          `json:"id" gorm:"primaryKey"`
+	EventID       string          `json:"event_id"`
+	UserID        string          `json:"user_id"`
+	SeatID        string          `json:"seat_id,omitempty"` // the venue seat, for events at a venue
+	TierID        string          `json:"tier_id,omitempty"` // the ticket tier, if the event has tiers
+	Seat          string          `json:"seat"`              // seat label, unique within the event
+	Status        TicketStatus    `json:"status"`
+	Price         decimal.Decimal `json:"price"`             // in the event's currency
+	HoldID        string          `json:"hold_id,omitempty"` // groups seats reserved together
+	ReservedUntil *time.Time      `json:"reserved_until"`
+	LockToken     int64           `json:"lock_token"` // fencing token of the seat lock that last wrote this row
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+// SeatConflictError reports the seats that kept a multi-seat reservation
+// from being made. It unwraps to the matching SeatResult error.
+type SeatConflictError struct {
+	Result SeatResult
+	Seats  []string
+}
+
+func (e *SeatConflictError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Result.Err(), strings.Join(e.Seats, ", "))
+}
+
+func (e *SeatConflictError) Unwrap() error {
+	return e.Result.Err()
+}
+
+// Hold is a reservation of one or more seats for one user, created by a
+// single reserve request and sharing one expiry. A hold of general-admission
+// tickets has their tier and counts against the tier's stock instead of
+// locking seats.
+type Hold struct {
+	ID         string    `json:"id"`
+	EventID    string    `json:"event_id"`
+	UserID     string    `json:"user_id"`
+	TierID     string    `json:"tier_id,omitempty"` // general-admission tier of the tickets
+	Seats      []string  `json:"seats"`             // seats the hold still reserves
+	Sold       []string  `json:"sold,omitempty"`    // seats sold under the hold
+	ExpiresAt  time.Time `json:"expires_at"`
+	Extensions int       `json:"extensions"`
+	PaymentID  string    `json:"payment_id,omitempty"` // provider payment started for the hold
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Payment states as reported by a PaymentProvider. Payments are authorized
+// first and only captured once the seats they pay for are sold.
+const (
+	PaymentPending    = "pending"    // waiting for the buyer to pay
+	PaymentAuthorized = "authorized" // funds reserved, not yet collected
+	PaymentCaptured   = "captured"   // funds collected
+	PaymentFailed     = "failed"     // the last attempt was declined
+	PaymentVoided     = "voided"     // cancelled before capture
+)
+
+// Payment is a provider payment covering one hold
+type Payment struct {
+	ID           string          `json:"id"`
+	HoldID       string          `json:"hold_id"`
+	Amount       decimal.Decimal `json:"amount"`
+	Currency     string          `json:"currency"` // ISO 4217 code
+	Status       string          `json:"status"`
+	ClientSecret string          `json:"client_secret,omitempty"` // lets the buyer's browser complete the payment
+}
+
+// Public seat availability, as shown on the seat map
+const (
+	SeatAvailable = "available"
+	SeatHeld      = "held"
+	SeatSold      = "sold"
+)
+
+// SeatAvailability is the public state of one seat. It never says who holds
+// or bought the seat.
+type SeatAvailability struct {
+	Seat   string          `json:"seat"`
+	SeatID string          `json:"seat_id,omitempty"` // the venue seat, for events at a venue
+	Status string          `json:"status"`            // available, held, sold
+	Price  decimal.Decimal `json:"price"`
+}
+
+// Seat transitions pushed to live seat maps
+const (
+	SeatEventLocked   = "locked"
+	SeatEventReleased = "released"
+	SeatEventExpired  = "expired"
+	SeatEventSold     = "sold"
+)
+
+// SeatEvent announces that a seat changed state. Like SeatAvailability it
+// never says who caused the change.
+type SeatEvent struct {
+	EventID string    `json:"event_id"`
+	Seat    string    `json:"seat"`
+	Type    string    `json:"type"`   // locked, released, expired, sold
+	Status  string    `json:"status"` // the seat's new public status
+	At      time.Time `json:"at"`
+}
+
+// QueueStatus is a buyer's place in an event's waiting room
+type QueueStatus struct {
+	EventID              string `json:"event_id"`
+	Position             int64  `json:"position"` // buyers still ahead
+	Admitted             bool   `json:"admitted"`
+	EstimatedWaitSeconds int64  `json:"estimated_wait_seconds"`
+}
+
+// ExpiredReservation identifies a lapsed hold returned to inventory
+type ExpiredReservation struct {
+	EventID string
+	Seat    string
+	UserID  string
 }
 
 // User represents a user entity
@@ -42,6 +222,9 @@ type User struct {
 // EventRepository interface
 type EventRepository interface {
 	Create(ctx context.Context, event *Event) error
+	// CreateWithInventory stores the event together with its tickets, all
+	// or nothing
+	CreateWithInventory(ctx context.Context, event *Event, tickets []*Ticket) error
 	GetByID(ctx context.Context, id string) (*Event, error)
 	GetAll(ctx context.Context) ([]*Event, error)
 	Update(ctx context.Context, event *Event) error
@@ -53,10 +236,291 @@ type TicketRepository interface {
 	Create(ctx context.Context, ticket *Ticket) error
 	GetByID(ctx context.Context, id string) (*Ticket, error)
 	GetByEventID(ctx context.Context, eventID string) ([]*Ticket, error)
+	GetByEventAndSeat(ctx context.Context, eventID, seat string) (*Ticket, error)
+	GetByEventAndSeatID(ctx context.Context, eventID, seatID string) (*Ticket, error)
 	Update(ctx context.Context, ticket *Ticket) error
 	Delete(ctx context.Context, id string) error
-	ReserveSeat(ctx context.Context, eventID, seat string, userID string, duration time.Duration) error
-	ReleaseSeat(ctx context.Context, eventID, seat string) error
+	// ReserveSeats reserves every seat and records the hold, or does neither
+	ReserveSeats(ctx context.Context, eventID string, seats []string, lockTokens []int64, userID, holdID string, duration time.Duration) (SeatResult, []string, error)
+	// ConfirmSeat refuses with SeatLimitReached once the user bought
+	// maxTickets tickets for the event (0 for no limit)
+	ConfirmSeat(ctx context.Context, eventID, seat string, userID string, lockToken int64, maxTickets int) (SeatResult, error)
+	CountSold(ctx context.Context, eventID, userID string) (int, error)
+	// CreateInventory stores the seats of an event without any, all or
+	// nothing, and fails with ErrInventoryExists when it already has some.
+	// General-admission tickets do not count as seats.
+	CreateInventory(ctx context.Context, eventID string, tickets []*Ticket) error
+	// ReserveTier reserves quantity available tickets of a general-admission
+	// tier under a new hold and returns their labels, or reserves none and
+	// fails with ErrSoldOut
+	ReserveTier(ctx context.Context, eventID, tierID string, quantity int, userID, holdID string, duration time.Duration) ([]string, error)
+	ReleaseSeat(ctx context.Context, eventID, seat string, userID string) (SeatResult, error)
+	ReleaseExpired(ctx context.Context, now time.Time, limit int) ([]ExpiredReservation, error)
+}
+
+// SeatLocker holds short-lived exclusive seat locks. Implementations must
+// make LockSeat atomic and only let the holder unlock or extend a lock.
+// Every new lock gets a fencing token greater than any previous token for
+// the same seat.
+type SeatLocker interface {
+	LockSeat(ctx context.Context, eventID, seat string, userID string, expiration time.Duration) (int64, bool, error)
+	// LockSeats locks every seat or none; on conflict it returns the seats
+	// held by someone else instead of tokens. It fails with ErrHoldLimit
+	// when the user would end up holding more than maxHeld seats of the
+	// event (0 for no limit).
+	LockSeats(ctx context.Context, eventID string, seats []string, userID string, expiration time.Duration, maxHeld int) ([]int64, []string, error)
+	UnlockSeat(ctx context.Context, eventID, seat string, userID string) (bool, error)
+	IsSeatLocked(ctx context.Context, eventID, seat string) (string, error)
+	ExtendLock(ctx context.Context, eventID, seat string, userID string, expiration time.Duration) (bool, error)
+	// LockHolders returns for each seat the user holding its lock, or ""
+	// when it is not locked
+	LockHolders(ctx context.Context, eventID string, seats []string) ([]string, error)
+
+	// General-admission tiers have no seats to lock; instead the locker
+	// counts their stock. A claim takes tickets off the stock for a hold
+	// until it is settled, or gives them back once it lapses.
+
+	// SeedTierStock starts counting the tier's stock at available unless
+	// it is counted already
+	SeedTierStock(ctx context.Context, eventID, tierID string, available int) error
+	// TierStock returns the tickets of the tier left to claim, or false
+	// when its stock is not counted
+	TierStock(ctx context.Context, eventID, tierID string) (int, bool, error)
+	// ClaimTierStock takes quantity tickets off the tier's stock for claim
+	// claimID, or fails with ErrSoldOut without taking any. Claimed tickets
+	// count toward the user's maxHeld like locked seats. It fails with
+	// ErrTierStockUnknown until the stock is seeded.
+	ClaimTierStock(ctx context.Context, eventID, tierID, claimID, userID string, quantity int, expiration time.Duration, maxHeld int) error
+	// ExtendTierClaim moves an unexpired claim's expiry; it returns false
+	// when the claim is gone
+	ExtendTierClaim(ctx context.Context, eventID, tierID, claimID, userID string, expiration time.Duration) (bool, error)
+	// SettleTierClaim ends a claim, keeping sold of its tickets off the
+	// stock and returning the rest. It returns false when the claim is
+	// gone, e.g. because it lapsed and its tickets were returned already.
+	SettleTierClaim(ctx context.Context, eventID, tierID, claimID, userID string, sold int) (bool, error)
+	// ReturnTierStock puts quantity tickets, e.g. refunded ones, back on
+	// the tier's stock. Tiers whose stock is not counted are left alone.
+	ReturnTierStock(ctx context.Context, eventID, tierID string, quantity int) error
+}
+
+// HoldRepository interface. Holds are created together with their seats by
+// TicketRepository.ReserveSeats.
+type HoldRepository interface {
+	GetByID(ctx context.Context, id string) (*Hold, error)
+	// FindByTier returns the user's newest unexpired hold of the
+	// general-admission tier, or ErrHoldNotFound
+	FindByTier(ctx context.Context, tierID, userID string) (*Hold, error)
+	// Extend moves an unexpired hold owned by userID to expiresAt, at most
+	// maxExtensions times
+	Extend(ctx context.Context, id, userID string, expiresAt time.Time, maxExtensions int) error
+	// SetPayment records the payment started for the hold. It fails with
+	// ErrPaymentConflict when the hold already has a different payment.
+	SetPayment(ctx context.Context, id, paymentID string) error
+	Delete(ctx context.Context, id string) error
+	// DeleteExpired removes lapsed holds without reserved seats and returns
+	// the payments of those that sold nothing, which must be voided. Holds
+	// that sold seats whose order is still pending are kept until their
+	// payment is captured.
+	DeleteExpired(ctx context.Context, now time.Time, limit int) ([]string, error)
+}
+
+// Order statuses
+const (
+	OrderPending   = "pending"   // payment started, not yet collected
+	OrderPaid      = "paid"      // seats sold and payment captured
+	OrderCancelled = "cancelled" // payment failed, voided or the hold lapsed
+	OrderRefunded  = "refunded"  // every ticket refunded
+)
+
+// Order is a purchase of one or more seats of an event by one user and the
+// payment that covers it. An order is opened when the payment for a hold is
+// started and keeps only the seats that were sold once it is paid.
+type Order struct {
+	ID        string          `json:"id"`
+	UserID    string          `json:"user_id"`
+	EventID   string          `json:"event_id"`
+	HoldID    string          `json:"hold_id,omitempty"`
+	Items     []OrderItem     `json:"items"`
+	Subtotal  decimal.Decimal `json:"subtotal"`
+	Fees      decimal.Decimal `json:"fees"`
+	Taxes     decimal.Decimal `json:"taxes"`
+	Total     decimal.Decimal `json:"total"`
+	Refunded  decimal.Decimal `json:"refunded"` // returned to the buyer so far
+	Currency  string          `json:"currency"` // ISO 4217 code
+	PaymentID string          `json:"payment_id,omitempty"`
+	Status    string          `json:"status"` // pending, paid, cancelled, refunded
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// OrderItem is one ticket of an order at the price it was sold for
+type OrderItem struct {
+	TicketID string          `json:"ticket_id"`
+	Seat     string          `json:"seat"`
+	Price    decimal.Decimal `json:"price"`
+	RefundID string          `json:"refund_id,omitempty"` // refund pending or made for the ticket
+}
+
+// Refund statuses
+const (
+	RefundPending   = "pending"   // items claimed, provider refund not yet confirmed
+	RefundSucceeded = "succeeded" // money returned and tickets refunded
+	RefundFailed    = "failed"    // the provider refused; the items may be refunded again
+)
+
+// Refund returns the money paid for some or all tickets of an order. The
+// buyer gets Amount back; Fee is what the event keeps of their share of
+// the order total.
+type Refund struct {
+	ID               string          `json:"id"`
+	OrderID          string          `json:"order_id"`
+	PaymentID        string          `json:"payment_id"`
+	ProviderRefundID string          `json:"provider_refund_id,omitempty"`
+	Items            []OrderItem     `json:"items"`
+	Amount           decimal.Decimal `json:"amount"`
+	Fee              decimal.Decimal `json:"fee"`
+	Currency         string          `json:"currency"`
+	Restock          bool            `json:"restock"` // the seats go back on sale
+	RequestedBy      string          `json:"requested_by"`
+	Status           string          `json:"status"` // pending, succeeded, failed
+	CreatedAt        time.Time       `json:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at"`
+}
+
+// Payment notifications delivered by a provider's webhook
+const (
+	PaymentEventAuthorized = "payment.authorized"
+	PaymentEventCaptured   = "payment.captured"
+	PaymentEventFailed     = "payment.failed"
+	PaymentEventVoided     = "payment.voided"
+)
+
+// PaymentEvent is a verified webhook notification about a payment. Type is
+// empty for provider events the server does not act on.
+type PaymentEvent struct {
+	ID           string    `json:"id"` // the provider's event ID
+	Type         string    `json:"type"`
+	ProviderType string    `json:"provider_type"`
+	PaymentID    string    `json:"payment_id"`
+	HoldID       string    `json:"hold_id"`
+	Payload      []byte    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// PaymentEventRepository keeps every webhook event received, which both
+// deduplicates deliveries and serves as the payment audit log
+type PaymentEventRepository interface {
+	// Record stores the event and reports whether it still needs
+	// processing, i.e. it is new or an earlier delivery failed midway
+	Record(ctx context.Context, event *PaymentEvent) (bool, error)
+	// MarkProcessed stores the outcome of processing the event
+	MarkProcessed(ctx context.Context, id, result string) error
+}
+
+// PaymentProvider takes payments for holds. Payments are authorized by the
+// buyer and captured by the server, so a hold that is never confirmed can be
+// voided without charging anyone.
+type PaymentProvider interface {
+	// CreatePayment starts a payment of amount for the hold. Calling it
+	// again for the same hold returns the same payment.
+	CreatePayment(ctx context.Context, holdID string, amount decimal.Decimal, currency string) (*Payment, error)
+	GetPayment(ctx context.Context, id string) (*Payment, error)
+	// CapturePayment collects amount, at most the authorized amount, of an
+	// authorized payment and releases the rest
+	CapturePayment(ctx context.Context, id string, amount decimal.Decimal) (*Payment, error)
+	// VoidPayment cancels a payment that has not been captured. Voiding a
+	// voided payment succeeds.
+	VoidPayment(ctx context.Context, id string) error
+	// RefundPayment returns amount of a captured payment and the provider's
+	// reference for the refund. Calling it again with the same refundID
+	// does not refund twice.
+	RefundPayment(ctx context.Context, id string, amount decimal.Decimal, refundID string) (string, error)
+}
+
+// WaitingRoomStore keeps per-event waiting room queues. Buyers are numbered
+// in arrival order and everyone up to the admitted watermark may buy.
+type WaitingRoomStore interface {
+	// Join returns the user's queue number, the same one on every call
+	Join(ctx context.Context, eventID, userID string) (int64, error)
+	Admitted(ctx context.Context, eventID string) (int64, error)
+	// Advance raises the admitted watermark by up to count, at most once per
+	// tick across all callers, and returns the new watermark
+	Advance(ctx context.Context, eventID string, tick, count int64) (int64, error)
+	// Events lists the events with buyers still waiting
+	Events(ctx context.Context) ([]string, error)
+}
+
+// RateLimitWindow is the state of a sliding-window rate limit after a hit.
+// The window is approximated from two fixed windows: the previous one is
+// weighted by how much of it still overlaps the sliding window.
+type RateLimitWindow struct {
+	Allowed  bool
+	Current  int           // requests counted in the current fixed window
+	Previous int           // requests counted in the previous fixed window
+	Elapsed  time.Duration // time into the current fixed window
+}
+
+// RateLimiter counts requests per key. Implementations must check and count
+// a hit atomically so concurrent requests cannot overshoot the limit.
+type RateLimiter interface {
+	// Hit counts a request for key unless that would exceed limit within
+	// the sliding window
+	Hit(ctx context.Context, key string, limit int, window time.Duration) (RateLimitWindow, error)
+}
+
+// IdempotencyRecord is what is remembered about a request made with an
+// Idempotency-Key: a fingerprint of the request and, once it finished, the
+// response to replay
+type IdempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	Completed   bool   `json:"completed"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// IdempotencyStore remembers requests by idempotency key
+type IdempotencyStore interface {
+	// Begin claims key with an in-flight record for the request. When the
+	// key is already claimed it returns the existing record and false.
+	Begin(ctx context.Context, key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error)
+	// Complete stores the finished request's response under key
+	Complete(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error
+	// Abandon releases a claim so the request can be retried
+	Abandon(ctx context.Context, key string) error
+}
+
+// OrderRepository interface
+type OrderRepository interface {
+	// Create stores the order with its items. A hold gets at most one
+	// order; creating another for the same hold fails with ErrOrderExists.
+	Create(ctx context.Context, order *Order) error
+	GetByID(ctx context.Context, id string) (*Order, error)
+	GetByHoldID(ctx context.Context, holdID string) (*Order, error)
+	// ListByUser returns the user's orders, newest first
+	ListByUser(ctx context.Context, userID string) ([]*Order, error)
+	// Update stores the order's status, amounts and payment and drops the
+	// items it no longer has
+	Update(ctx context.Context, order *Order) error
+	// CancelPending cancels the pending order paid with paymentID, if any
+	CancelPending(ctx context.Context, paymentID string) error
+}
+
+// RefundRepository interface
+type RefundRepository interface {
+	// Create stores a pending refund and claims its order items for it. It
+	// fails with ErrAlreadyRefunded when another refund claimed any of them
+	// and with ErrTicketNotRefundable when any ticket is no longer sold.
+	Create(ctx context.Context, refund *Refund) error
+	// ListByOrder returns the order's refunds, oldest first
+	ListByOrder(ctx context.Context, orderID string) ([]*Refund, error)
+	// Complete records the provider's refund, adds the amount to the
+	// order's refunded total and marks the order refunded once every item
+	// belongs to a succeeded refund. The tickets become refunded or, for a restocking refund,
+	// available again; the restocked tickets are returned.
+	Complete(ctx context.Context, id, providerRefundID string) ([]*Ticket, error)
+	// Fail marks a pending refund failed and releases its items
+	Fail(ctx context.Context, id string) error
 }
 
 // UserRepository interface
@@ -67,3 +531,35 @@ type UserRepository interface {
 	Update(ctx context.Context, user *User) error
 	Delete(ctx context.Context, id string) error
 }
+
+// VenueRepository interface. A venue's seat map is stored with it and not
+// changed afterwards, since tickets of its events refer to the seats.
+type VenueRepository interface {
+	// Create stores the venue with its seat map, giving every section, row
+	// and seat an ID
+	Create(ctx context.Context, venue *Venue) error
+	// GetByID returns the venue with its seat map
+	GetByID(ctx context.Context, id string) (*Venue, error)
+	// GetAll returns the venues without their seat maps
+	GetAll(ctx context.Context) ([]*Venue, error)
+	// Update stores the venue's name and address
+	Update(ctx context.Context, venue *Venue) error
+	// Delete fails with ErrVenueInUse while events use the venue
+	Delete(ctx context.Context, id string) error
+}
+
+// TicketTierRepository interface
+type TicketTierRepository interface {
+	// Create stores the tier. A general-admission tier is stored with its
+	// tickets, all or nothing, and fails with ErrCapacityExceeded when the
+	// event has no room for them. A reserved tier takes over the event's
+	// seats in its sections, including seats generated later.
+	Create(ctx context.Context, tier *TicketTier, tickets []*Ticket) error
+	// GetByID returns ErrTierNotFound when the tier does not exist
+	GetByID(ctx context.Context, id string) (*TicketTier, error)
+	// ListByEvent returns the event's tiers, oldest first
+	ListByEvent(ctx context.Context, eventID string) ([]*TicketTier, error)
+	// CountAvailable counts the tier's tickets that can be reserved: those
+	// available and those whose reservation has lapsed
+	CountAvailable(ctx context.Context, tierID string) (int, error)
+}
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// DefaultCurrency is the currency of events created without one
+const DefaultCurrency = "IDR"
+
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+// currencyDigits is the number of minor unit digits of each supported ISO
+// 4217 currency. Amounts are rounded to this many decimals.
+var currencyDigits = map[string]int32{
+	"IDR": 0,
+	"JPY": 0,
+	"KRW": 0,
+	"VND": 0,
+	"USD": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"SGD": 2,
+	"MYR": 2,
+	"AUD": 2,
+	"THB": 2,
+	"PHP": 2,
+}
+
+// NormalizeCurrency upper-cases an ISO 4217 code and checks it is supported
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if _, ok := currencyDigits[code]; !ok {
+		return "", ErrUnsupportedCurrency
+	}
+	return code, nil
+}
+
+// CurrencyDigits returns the number of minor unit digits of the currency
+func CurrencyDigits(currency string) (int32, error) {
+	digits, ok := currencyDigits[strings.ToUpper(currency)]
+	if !ok {
+		return 0, ErrUnsupportedCurrency
+	}
+	return digits, nil
+}
+
+// RoundMoney rounds amount half away from zero to the currency's minor
+// unit, e.g. to whole rupiah or to cents. Unsupported currencies are
+// rounded to two decimals.
+func RoundMoney(amount decimal.Decimal, currency string) decimal.Decimal {
+	digits, err := CurrencyDigits(currency)
+	if err != nil {
+		digits = 2
+	}
+	return amount.Round(digits)
+}
@@ -0,0 +1,149 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidLayout   = errors.New("invalid seat layout")
+	ErrInventoryExists = errors.New("event already has seats")
+)
+
+const (
+	MaxSeatLabel   = 50      // longest seat label the tickets table stores
+	MaxLayoutSeats = 100_000 // most seats a layout may generate
+)
+
+// SeatLayout describes the seats of an event as sections of numbered rows
+type SeatLayout struct {
+	Sections []LayoutSection `json:"sections"`
+}
+
+// LayoutSection is a block of rows sold at one price
+type LayoutSection struct {
+	Name  string          `json:"name"` // prefixes its seat labels, may be empty for a single section
+	Price decimal.Decimal `json:"price"`
+	Rows  []LayoutRow     `json:"rows"`
+}
+
+// LayoutRow is a range of seat numbers, both ends included
+type LayoutRow struct {
+	Name string `json:"name"`
+	From int    `json:"from"`
+	To   int    `json:"to"`
+}
+
+// SeatLabel names seat number n of the row, e.g. "A12", or "VIP-A12" in a
+// named section
+func (s LayoutSection) SeatLabel(row LayoutRow, n int) string {
+	return seatLabel(s.Name, row.Name, n)
+}
+
+func seatLabel(section, row string, n int) string {
+	label := row + strconv.Itoa(n)
+	if section != "" {
+		label = section + "-" + label
+	}
+	return label
+}
+
+// checkRowName refuses row names that could make two seats share a label:
+// names running into the seat number or the section prefix
+func checkRowName(name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: rows need a name", ErrInvalidLayout)
+	}
+	if _, err := strconv.Atoi(name[len(name)-1:]); err == nil || strings.Contains(name, "-") {
+		return fmt.Errorf("%w: row %q must not end in a digit or contain a dash", ErrInvalidLayout, name)
+	}
+	return nil
+}
+
+// Count returns how many seats the layout has
+func (l SeatLayout) Count() int {
+	count := 0
+	for _, section := range l.Sections {
+		for _, row := range section.Rows {
+			if row.To >= row.From {
+				count += row.To - row.From + 1
+			}
+		}
+	}
+	return count
+}
+
+// Validate checks that every row has a name and a valid range, prices are
+// not negative, labels fit and no seat appears twice
+func (l SeatLayout) Validate() error {
+	if len(l.Sections) == 0 {
+		return fmt.Errorf("%w: no sections", ErrInvalidLayout)
+	}
+	seats := 0
+	sections := make(map[string]bool, len(l.Sections))
+	rows := make(map[string]bool)
+	for _, section := range l.Sections {
+		if sections[section.Name] {
+			return fmt.Errorf("%w: section %q appears twice", ErrInvalidLayout, section.Name)
+		}
+		sections[section.Name] = true
+		if section.Price.IsNegative() {
+			return fmt.Errorf("%w: section %q has a negative price", ErrInvalidLayout, section.Name)
+		}
+		if len(section.Rows) == 0 {
+			return fmt.Errorf("%w: section %q has no rows", ErrInvalidLayout, section.Name)
+		}
+		for _, row := range section.Rows {
+			if row.Name == "" || row.From < 1 || row.To < row.From {
+				return fmt.Errorf("%w: row %q of section %q needs a name and seats numbered from 1", ErrInvalidLayout, row.Name, section.Name)
+			}
+			if seats += row.To - row.From + 1; seats > MaxLayoutSeats {
+				return fmt.Errorf("%w: more than %d seats", ErrInvalidLayout, MaxLayoutSeats)
+			}
+			if len(section.SeatLabel(row, row.To)) > MaxSeatLabel {
+				return fmt.Errorf("%w: seat labels of row %q are longer than %d characters", ErrInvalidLayout, row.Name, MaxSeatLabel)
+			}
+			if err := checkRowName(row.Name); err != nil {
+				return err
+			}
+			key := section.Name + "\x00" + row.Name
+			if rows[key] {
+				return fmt.Errorf("%w: row %q of section %q appears twice", ErrInvalidLayout, row.Name, section.Name)
+			}
+			rows[key] = true
+		}
+	}
+	return nil
+}
+
+// Tickets returns an available ticket for every seat of the layout, priced
+// in the event's currency. The layout must have exactly seats seats, the
+// event's SeatedCapacity.
+func (l SeatLayout) Tickets(event *Event, seats int) ([]*Ticket, error) {
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+	if count := l.Count(); count != seats {
+		return nil, fmt.Errorf("%w: %d seats for %d seated places", ErrInvalidLayout, count, seats)
+	}
+
+	tickets := make([]*Ticket, 0, seats)
+	for _, section := range l.Sections {
+		price := RoundMoney(section.Price, event.Currency)
+		for _, row := range section.Rows {
+			for n := row.From; n <= row.To; n++ {
+				tickets = append(tickets, &Ticket{
+					EventID: event.ID,
+					Seat:    section.SeatLabel(row, n),
+					Status:  TicketAvailable,
+					Price:   price,
+				})
+			}
+		}
+	}
+	return tickets, nil
+}
@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestSeatLayoutTickets(t *testing.T) {
+	layout := SeatLayout{Sections: []LayoutSection{
+		{Name: "VIP", Price: decimal.RequireFromString("150000.4"), Rows: []LayoutRow{{Name: "A", From: 1, To: 10}}},
+		{Name: "", Price: decimal.NewFromInt(50000), Rows: []LayoutRow{{Name: "B", From: 1, To: 20}, {Name: "C", From: 5, To: 24}}},
+	}}
+	event := &Event{ID: "event1", Capacity: 50, Currency: "IDR"}
+
+	tickets, err := layout.Tickets(event, event.Capacity)
+	if err != nil {
+		t.Fatalf("Tickets failed: %v", err)
+	}
+	if len(tickets) != 50 {
+		t.Fatalf("Expected 50 tickets, got %d", len(tickets))
+	}
+	first, last := tickets[0], tickets[len(tickets)-1]
+	if first.Seat != "VIP-A1" || !first.Price.Equal(decimal.NewFromInt(150000)) || first.Status != TicketAvailable || first.EventID != "event1" {
+		t.Errorf("Unexpected first ticket %+v", first)
+	}
+	if last.Seat != "C24" || !last.Price.Equal(decimal.NewFromInt(50000)) {
+		t.Errorf("Unexpected last ticket %+v", last)
+	}
+
+	if _, err := layout.Tickets(event, 49); !errors.Is(err, ErrInvalidLayout) {
+		t.Errorf("Expected a capacity mismatch to be refused, got %v", err)
+	}
+}
+
+func TestSeatLayoutValidate(t *testing.T) {
+	row := LayoutRow{Name: "A", From: 1, To: 10}
+	tests := []struct {
+		name   string
+		layout SeatLayout
+	}{
+		{"no sections", SeatLayout{}},
+		{"no rows", SeatLayout{Sections: []LayoutSection{{Name: "A"}}}},
+		{"negative price", SeatLayout{Sections: []LayoutSection{{Price: decimal.NewFromInt(-1), Rows: []LayoutRow{row}}}}},
+		{"empty range", SeatLayout{Sections: []LayoutSection{{Rows: []LayoutRow{{Name: "A", From: 5, To: 4}}}}}},
+		{"seat zero", SeatLayout{Sections: []LayoutSection{{Rows: []LayoutRow{{Name: "A", From: 0, To: 4}}}}}},
+		{"duplicate row", SeatLayout{Sections: []LayoutSection{{Rows: []LayoutRow{row, row}}}}},
+		{"duplicate section", SeatLayout{Sections: []LayoutSection{{Name: "X", Rows: []LayoutRow{row}}, {Name: "X", Rows: []LayoutRow{{Name: "B", From: 1, To: 1}}}}}},
+		{"row ending in a digit", SeatLayout{Sections: []LayoutSection{{Rows: []LayoutRow{{Name: "A1", From: 1, To: 1}}}}}},
+		{"too many seats", SeatLayout{Sections: []LayoutSection{{Rows: []LayoutRow{{Name: "A", From: 1, To: MaxLayoutSeats + 1}}}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.layout.Validate(); !errors.Is(err, ErrInvalidLayout) {
+				t.Errorf("Expected ErrInvalidLayout, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TicketStatus is a ticket's place in its lifecycle
+type TicketStatus string
+
+const (
+	TicketAvailable TicketStatus = "available"  // on sale
+	TicketReserved  TicketStatus = "reserved"   // held for a buyer until the reservation lapses
+	TicketSold      TicketStatus = "sold"       // bought
+	TicketRefunded  TicketStatus = "refunded"   // bought and refunded, kept off sale
+	TicketCheckedIn TicketStatus = "checked_in" // bought and used at the door
+)
+
+// ticketTransitions lists the statuses each status may move to. The
+// check_ticket_transition trigger on the tickets table enforces the same
+// table for writes made in SQL, and TestTransitionsMatchTrigger checks that
+// the two stay in step.
+var ticketTransitions = map[TicketStatus][]TicketStatus{
+	TicketAvailable: {TicketReserved},
+	TicketReserved:  {TicketSold, TicketAvailable},
+	TicketSold:      {TicketRefunded, TicketCheckedIn},
+	TicketRefunded:  {TicketAvailable}, // restocked
+	TicketCheckedIn: {},
+}
+
+// ErrIllegalTransition is wrapped by every *TransitionError
+var ErrIllegalTransition = errors.New("illegal ticket transition")
+
+// TransitionError reports a ticket write the lifecycle does not allow:
+// either the move between the two statuses or the ticket it would leave
+// behind
+type TransitionError struct {
+	Seat   string
+	From   TicketStatus // empty for a new ticket
+	To     TicketStatus
+	Reason string // the failed precondition, empty when the move itself is illegal
+}
+
+func (e *TransitionError) Error() string {
+	msg := fmt.Sprintf("%s from %s to %s", ErrIllegalTransition, e.From, e.To)
+	if e.From == "" {
+		msg = fmt.Sprintf("%s to %s", ErrIllegalTransition, e.To)
+	}
+	if e.Seat != "" {
+		msg = fmt.Sprintf("%s of seat %s", msg, e.Seat)
+	}
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return msg
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrIllegalTransition
+}
+
+// Valid reports whether s is a known status
+func (s TicketStatus) Valid() bool {
+	_, ok := ticketTransitions[s]
+	return ok
+}
+
+// Purchased reports whether a ticket in status s has been bought, whatever
+// happened to it since
+func (s TicketStatus) Purchased() bool {
+	return s == TicketSold || s == TicketRefunded || s == TicketCheckedIn
+}
+
+// CanTransitionTo reports whether a ticket may move from s to next. Staying
+// in the same status is not a transition and is always allowed.
+func (s TicketStatus) CanTransitionTo(next TicketStatus) bool {
+	if s == next {
+		return s.Valid()
+	}
+	for _, to := range ticketTransitions[s] {
+		if to == next {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckTransition reports whether a ticket in status from may be written as
+// next. Besides the move being legal, next must satisfy the preconditions
+// of its status at now:
+//   - reserved: it has a holder, a hold and an unlapsed reservation
+//   - sold, refunded and checked in: it has a buyer
+//   - available: it has no holder, hold or reservation
+func CheckTransition(from TicketStatus, next *Ticket, now time.Time) error {
+	fail := func(reason string) error {
+		return &TransitionError{Seat: next.Seat, From: from, To: next.Status, Reason: reason}
+	}
+	if !from.CanTransitionTo(next.Status) {
+		return fail("")
+	}
+
+	switch next.Status {
+	case TicketReserved:
+		if next.UserID == "" || next.HoldID == "" {
+			return fail("a reservation needs a holder and a hold")
+		}
+		if next.ReservedUntil == nil || !next.ReservedUntil.After(now) {
+			return fail("the reservation has lapsed")
+		}
+	case TicketSold, TicketRefunded, TicketCheckedIn:
+		if next.UserID == "" {
+			return fail("the ticket has no buyer")
+		}
+	case TicketAvailable:
+		if next.UserID != "" || next.HoldID != "" || next.ReservedUntil != nil {
+			return fail("an available ticket cannot have a holder")
+		}
+	}
+	return nil
+}
+
+// CheckNewTicket checks that a ticket may be created as it is. New
+// inventory always starts out available.
+func CheckNewTicket(ticket *Ticket) error {
+	if ticket.Status != TicketAvailable {
+		return &TransitionError{Seat: ticket.Seat, To: ticket.Status, Reason: "new tickets start out available"}
+	}
+	return CheckTransition(TicketAvailable, ticket, time.Now())
+}
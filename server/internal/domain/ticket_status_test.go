@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckTransition(t *testing.T) {
+	now := time.Now()
+	future := now.Add(5 * time.Minute)
+	past := now.Add(-time.Minute)
+
+	tests := []struct {
+		name   string
+		from   TicketStatus
+		next   Ticket
+		wantOK bool
+	}{
+		{"reserve", TicketAvailable, Ticket{Status: TicketReserved, UserID: "u1", HoldID: "h1", ReservedUntil: &future}, true},
+		{"reserve without hold", TicketAvailable, Ticket{Status: TicketReserved, UserID: "u1", ReservedUntil: &future}, false},
+		{"reserve already lapsed", TicketAvailable, Ticket{Status: TicketReserved, UserID: "u1", HoldID: "h1", ReservedUntil: &past}, false},
+		{"extend reservation", TicketReserved, Ticket{Status: TicketReserved, UserID: "u1", HoldID: "h1", ReservedUntil: &future}, true},
+		{"sell", TicketReserved, Ticket{Status: TicketSold, UserID: "u1", HoldID: "h1"}, true},
+		{"release", TicketReserved, Ticket{Status: TicketAvailable}, true},
+		{"release keeping holder", TicketReserved, Ticket{Status: TicketAvailable, UserID: "u1"}, false},
+		{"sell unreserved", TicketAvailable, Ticket{Status: TicketSold, UserID: "u1"}, false},
+		{"refund", TicketSold, Ticket{Status: TicketRefunded, UserID: "u1"}, true},
+		{"check in", TicketSold, Ticket{Status: TicketCheckedIn, UserID: "u1"}, true},
+		{"release sold", TicketSold, Ticket{Status: TicketAvailable}, false},
+		{"restock refunded", TicketRefunded, Ticket{Status: TicketAvailable}, true},
+		{"check in refunded", TicketRefunded, Ticket{Status: TicketCheckedIn, UserID: "u1"}, false},
+		{"refund checked in", TicketCheckedIn, Ticket{Status: TicketRefunded, UserID: "u1"}, false},
+		{"unknown status", TicketAvailable, Ticket{Status: "lost"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckTransition(tt.from, &tt.next, now)
+			if tt.wantOK && err != nil {
+				t.Errorf("Expected %s to %s to be allowed, got %v", tt.from, tt.next.Status, err)
+			}
+			if !tt.wantOK && !errors.Is(err, ErrIllegalTransition) {
+				t.Errorf("Expected ErrIllegalTransition for %s to %s, got %v", tt.from, tt.next.Status, err)
+			}
+		})
+	}
+}
+
+func TestCheckNewTicket(t *testing.T) {
+	if err := CheckNewTicket(&Ticket{Seat: "A1", Status: TicketAvailable}); err != nil {
+		t.Errorf("Expected a new available ticket to be allowed, got %v", err)
+	}
+
+	err := CheckNewTicket(&Ticket{Seat: "A1", Status: TicketSold, UserID: "u1"})
+	var transition *TransitionError
+	if !errors.As(err, &transition) || transition.Seat != "A1" || transition.To != TicketSold {
+		t.Errorf("Expected a TransitionError for a ticket created sold, got %v", err)
+	}
+}
+
+// TestTransitionsMatchTrigger checks that the check_ticket_transition
+// trigger allows exactly the moves of ticketTransitions
+func TestTransitionsMatchTrigger(t *testing.T) {
+	sql, err := os.ReadFile("../../migrations/20261017103000_ticket_state_machine/migration.sql")
+	if err != nil {
+		t.Fatalf("Failed to read the migration: %v", err)
+	}
+	allowed := make(map[[2]TicketStatus]bool)
+	for _, pair := range regexp.MustCompile(`\('([A-Z_]+)', '([A-Z_]+)'\)`).FindAllStringSubmatch(string(sql), -1) {
+		from, to := TicketStatus(strings.ToLower(pair[1])), TicketStatus(strings.ToLower(pair[2]))
+		if !from.Valid() || !to.Valid() {
+			t.Errorf("Trigger allows %s to %s between unknown statuses", pair[1], pair[2])
+		}
+		allowed[[2]TicketStatus{from, to}] = true
+	}
+
+	for from := range ticketTransitions {
+		for to := range ticketTransitions {
+			if from == to {
+				continue
+			}
+			if got, want := allowed[[2]TicketStatus{from, to}], from.CanTransitionTo(to); got != want {
+				t.Errorf("Trigger allows %s to %s: %v, domain allows it: %v", from, to, got, want)
+			}
+		}
+	}
+}
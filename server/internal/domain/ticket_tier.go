@@ -0,0 +1,121 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrTierNotFound = errors.New("ticket tier not found")
+	ErrInvalidTier  = errors.New("invalid ticket tier")
+	ErrSoldOut      = errors.New("not enough tickets left in this tier")
+	// ErrCapacityExceeded is returned when a general-admission tier would
+	// take the event past its capacity
+	ErrCapacityExceeded = errors.New("tier does not fit the event's capacity")
+	// ErrTierStockUnknown is returned by a SeatLocker that does not track
+	// a tier's stock yet; seed it with SeedTierStock and retry
+	ErrTierStockUnknown = errors.New("tier stock is not tracked")
+)
+
+// Ticket tier kinds
+const (
+	TierReserved         = "reserved"          // sold by seat
+	TierGeneralAdmission = "general_admission" // sold by quantity
+)
+
+// maxTierName leaves room in a general-admission ticket's label for a dash
+// and the ticket number
+const maxTierName = MaxSeatLabel - 7
+
+// TicketTier is a type of ticket on sale for an event. Reserved-seating
+// tiers group sections of the seat map; general-admission tiers are a
+// counted quantity of tickets without a seat, e.g. a standing area.
+type TicketTier struct {
+	ID        string          `json:"id"`
+	EventID   string          `json:"event_id"`
+	Name      string          `json:"name"`
+	Kind      string          `json:"kind"`               // reserved or general_admission
+	Price     decimal.Decimal `json:"price"`              // general admission only, seats are priced by section
+	Quantity  int             `json:"quantity,omitempty"` // general admission tickets on sale
+	Sections  []string        `json:"sections,omitempty"` // seat map sections of a reserved tier
+	Available *int            `json:"available,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// GeneralAdmission reports whether the tier is sold by quantity
+func (t *TicketTier) GeneralAdmission() bool {
+	return t.Kind == TierGeneralAdmission
+}
+
+// Validate checks the tier's name and that it has what its kind needs: a
+// quantity and a price for general admission, named sections for reserved
+// seating
+func (t *TicketTier) Validate() error {
+	if t.Name == "" || len(t.Name) > maxTierName {
+		return fmt.Errorf("%w: the name must have 1 to %d characters", ErrInvalidTier, maxTierName)
+	}
+	switch t.Kind {
+	case TierGeneralAdmission:
+		if t.Quantity < 1 || t.Quantity > MaxLayoutSeats {
+			return fmt.Errorf("%w: general admission needs a quantity of 1 to %d", ErrInvalidTier, MaxLayoutSeats)
+		}
+		if t.Price.IsNegative() {
+			return fmt.Errorf("%w: the price must not be negative", ErrInvalidTier)
+		}
+		if len(t.Sections) > 0 {
+			return fmt.Errorf("%w: general admission has no sections", ErrInvalidTier)
+		}
+	case TierReserved:
+		if t.Quantity != 0 || !t.Price.IsZero() {
+			return fmt.Errorf("%w: reserved seats are counted and priced by the seat map", ErrInvalidTier)
+		}
+		if len(t.Sections) == 0 {
+			return fmt.Errorf("%w: reserved seating needs sections", ErrInvalidTier)
+		}
+		sections := make(map[string]bool, len(t.Sections))
+		for _, section := range t.Sections {
+			if section == "" || sections[section] {
+				return fmt.Errorf("%w: sections need a unique name, got %q", ErrInvalidTier, section)
+			}
+			sections[section] = true
+		}
+	default:
+		return fmt.Errorf("%w: kind must be %s or %s", ErrInvalidTier, TierReserved, TierGeneralAdmission)
+	}
+	return nil
+}
+
+// Tickets returns the available tickets of a general-admission tier, priced
+// in the event's currency and labelled with the tier name and a number,
+// e.g. "Floor-17". Seat labels always end in a row name and a number, so
+// the labels never clash with seats.
+func (t *TicketTier) Tickets(event *Event) []*Ticket {
+	price := RoundMoney(t.Price, event.Currency)
+	tickets := make([]*Ticket, 0, t.Quantity)
+	for n := 1; n <= t.Quantity; n++ {
+		tickets = append(tickets, &Ticket{
+			EventID: event.ID,
+			TierID:  t.ID,
+			Seat:    t.Name + "-" + strconv.Itoa(n),
+			Status:  TicketAvailable,
+			Price:   price,
+		})
+	}
+	return tickets
+}
+
+// SeatedCapacity returns how many seats the event has room for once its
+// general-admission tiers are sold
+func SeatedCapacity(event *Event, tiers []*TicketTier) int {
+	seats := event.Capacity
+	for _, tier := range tiers {
+		if tier.GeneralAdmission() {
+			seats -= tier.Quantity
+		}
+	}
+	return seats
+}
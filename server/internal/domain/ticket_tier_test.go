@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestTicketTierValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		tier  TicketTier
+		valid bool
+	}{
+		{"general admission", TicketTier{Name: "Floor", Kind: TierGeneralAdmission, Price: decimal.NewFromInt(50), Quantity: 100}, true},
+		{"reserved", TicketTier{Name: "Tribune", Kind: TierReserved, Sections: []string{"East", "West"}}, true},
+		{"no name", TicketTier{Kind: TierGeneralAdmission, Quantity: 1}, false},
+		{"no quantity", TicketTier{Name: "Floor", Kind: TierGeneralAdmission}, false},
+		{"negative price", TicketTier{Name: "Floor", Kind: TierGeneralAdmission, Price: decimal.NewFromInt(-1), Quantity: 1}, false},
+		{"reserved with quantity", TicketTier{Name: "Tribune", Kind: TierReserved, Quantity: 5, Sections: []string{"East"}}, false},
+		{"duplicate section", TicketTier{Name: "Tribune", Kind: TierReserved, Sections: []string{"East", "East"}}, false},
+		{"unknown kind", TicketTier{Name: "Floor", Kind: "standing", Quantity: 1}, false},
+	}
+	for _, tt := range tests {
+		err := tt.tier.Validate()
+		if tt.valid && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidTier) {
+			t.Errorf("%s: expected ErrInvalidTier, got %v", tt.name, err)
+		}
+	}
+}
+
+func TestTicketTierTickets(t *testing.T) {
+	event := &Event{ID: "event1", Capacity: 10, Currency: "USD"}
+	tier := &TicketTier{ID: "tier1", Name: "Floor", Kind: TierGeneralAdmission, Price: decimal.RequireFromString("9.999"), Quantity: 3}
+
+	tickets := tier.Tickets(event)
+	if len(tickets) != 3 || tickets[2].Seat != "Floor-3" || tickets[0].TierID != "tier1" || !tickets[0].Price.Equal(decimal.NewFromInt(10)) {
+		t.Errorf("Unexpected tickets %+v", tickets[2])
+	}
+
+	reserved := &TicketTier{Kind: TierReserved, Sections: []string{"VIP"}}
+	if seats := SeatedCapacity(event, []*TicketTier{tier, reserved}); seats != 7 {
+		t.Errorf("Expected 7 seated places, got %d", seats)
+	}
+}
@@ -0,0 +1,153 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrVenueNotFound = errors.New("venue not found")
+	ErrVenueInUse    = errors.New("venue is used by events")
+)
+
+// Venue is a place with a seat map that any number of events can share
+type Venue struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Address   string         `json:"address"`
+	Capacity  int            `json:"capacity"`           // seats in the map
+	Sections  []VenueSection `json:"sections,omitempty"` // the seat map, only loaded for a single venue
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// VenueSection is a named block of rows, the unit events price seats by
+type VenueSection struct {
+	ID   string     `json:"id"`
+	Name string     `json:"name"`
+	Rows []VenueRow `json:"rows"`
+}
+
+// VenueRow is a named row of seats within a section
+type VenueRow struct {
+	ID    string      `json:"id"`
+	Name  string      `json:"name"`
+	Seats []VenueSeat `json:"seats"`
+}
+
+// VenueSeat is one seat of the map. X and Y place it on the seat map
+// drawing, in whatever units the drawing uses.
+type VenueSeat struct {
+	ID             string  `json:"id"`
+	Number         int     `json:"number"`
+	Label          string  `json:"label"` // e.g. "VIP-A12", the seat of the event's tickets
+	X              float64 `json:"x"`
+	Y              float64 `json:"y"`
+	Accessible     bool    `json:"accessible"`
+	RestrictedView bool    `json:"restricted_view"`
+	Aisle          bool    `json:"aisle"`
+}
+
+// Prepare validates the seat map like SeatLayout.Validate does, labels its
+// seats and sets the venue's capacity
+func (v *Venue) Prepare() error {
+	if v.Name == "" {
+		return fmt.Errorf("%w: the venue needs a name", ErrInvalidLayout)
+	}
+	if len(v.Sections) == 0 {
+		return fmt.Errorf("%w: no sections", ErrInvalidLayout)
+	}
+
+	seats := 0
+	sections := make(map[string]bool, len(v.Sections))
+	for i := range v.Sections {
+		section := &v.Sections[i]
+		if section.Name == "" || sections[section.Name] {
+			return fmt.Errorf("%w: sections need a unique name, got %q", ErrInvalidLayout, section.Name)
+		}
+		sections[section.Name] = true
+		if len(section.Rows) == 0 {
+			return fmt.Errorf("%w: section %q has no rows", ErrInvalidLayout, section.Name)
+		}
+
+		rows := make(map[string]bool, len(section.Rows))
+		for j := range section.Rows {
+			row := &section.Rows[j]
+			if err := checkRowName(row.Name); err != nil {
+				return err
+			}
+			if rows[row.Name] {
+				return fmt.Errorf("%w: row %q of section %q appears twice", ErrInvalidLayout, row.Name, section.Name)
+			}
+			rows[row.Name] = true
+			if len(row.Seats) == 0 {
+				return fmt.Errorf("%w: row %q of section %q has no seats", ErrInvalidLayout, row.Name, section.Name)
+			}
+			if seats += len(row.Seats); seats > MaxLayoutSeats {
+				return fmt.Errorf("%w: more than %d seats", ErrInvalidLayout, MaxLayoutSeats)
+			}
+
+			numbers := make(map[int]bool, len(row.Seats))
+			for k := range row.Seats {
+				seat := &row.Seats[k]
+				if seat.Number < 1 || numbers[seat.Number] {
+					return fmt.Errorf("%w: seats of row %q need unique numbers from 1, got %d", ErrInvalidLayout, row.Name, seat.Number)
+				}
+				numbers[seat.Number] = true
+				seat.Label = seatLabel(section.Name, row.Name, seat.Number)
+				if len(seat.Label) > MaxSeatLabel {
+					return fmt.Errorf("%w: seat label %q is longer than %d characters", ErrInvalidLayout, seat.Label, MaxSeatLabel)
+				}
+			}
+		}
+	}
+	v.Capacity = seats
+	return nil
+}
+
+// Tickets returns an available ticket for every seat of the venue, priced
+// per section in the event's currency. Every section needs a price and the
+// venue must have exactly seats seats, the event's SeatedCapacity.
+func (v *Venue) Tickets(event *Event, seats int, prices map[string]decimal.Decimal) ([]*Ticket, error) {
+	if v.Capacity != seats {
+		return nil, fmt.Errorf("%w: %d seats for %d seated places", ErrInvalidLayout, v.Capacity, seats)
+	}
+	for name := range prices {
+		if !v.hasSection(name) {
+			return nil, fmt.Errorf("%w: the venue has no section %q", ErrInvalidLayout, name)
+		}
+	}
+
+	tickets := make([]*Ticket, 0, v.Capacity)
+	for _, section := range v.Sections {
+		price, ok := prices[section.Name]
+		if !ok || price.IsNegative() {
+			return nil, fmt.Errorf("%w: section %q needs a price", ErrInvalidLayout, section.Name)
+		}
+		price = RoundMoney(price, event.Currency)
+		for _, row := range section.Rows {
+			for _, seat := range row.Seats {
+				tickets = append(tickets, &Ticket{
+					EventID: event.ID,
+					SeatID:  seat.ID,
+					Seat:    seat.Label,
+					Status:  TicketAvailable,
+					Price:   price,
+				})
+			}
+		}
+	}
+	return tickets, nil
+}
+
+func (v *Venue) hasSection(name string) bool {
+	for _, section := range v.Sections {
+		if section.Name == name {
+			return true
+		}
+	}
+	return false
+}
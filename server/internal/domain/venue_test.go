@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestVenuePrepareAndTickets(t *testing.T) {
+	venue := &Venue{Name: "Istora", Sections: []VenueSection{
+		{Name: "VIP", Rows: []VenueRow{{Name: "A", Seats: []VenueSeat{{ID: "s1", Number: 1, Aisle: true}, {ID: "s2", Number: 2}}}}},
+		{Name: "Tribune", Rows: []VenueRow{{Name: "B", Seats: []VenueSeat{{ID: "s3", Number: 7, Accessible: true}}}}},
+	}}
+	if err := venue.Prepare(); err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if venue.Capacity != 3 || venue.Sections[1].Rows[0].Seats[0].Label != "Tribune-B7" {
+		t.Errorf("Expected 3 labelled seats, got %d and %q", venue.Capacity, venue.Sections[1].Rows[0].Seats[0].Label)
+	}
+
+	event := &Event{ID: "event1", Capacity: 3, Currency: "USD"}
+	prices := map[string]decimal.Decimal{"VIP": decimal.RequireFromString("99.999"), "Tribune": decimal.NewFromInt(20)}
+	tickets, err := venue.Tickets(event, event.Capacity, prices)
+	if err != nil {
+		t.Fatalf("Tickets failed: %v", err)
+	}
+	if len(tickets) != 3 || tickets[0].SeatID != "s1" || tickets[0].Seat != "VIP-A1" || !tickets[0].Price.Equal(decimal.NewFromInt(100)) {
+		t.Errorf("Unexpected tickets %+v", tickets[0])
+	}
+
+	delete(prices, "Tribune")
+	if _, err := venue.Tickets(event, event.Capacity, prices); !errors.Is(err, ErrInvalidLayout) {
+		t.Errorf("Expected a section without a price to be refused, got %v", err)
+	}
+	prices["Balcony"] = decimal.NewFromInt(10)
+	prices["Tribune"] = decimal.NewFromInt(20)
+	if _, err := venue.Tickets(event, event.Capacity, prices); !errors.Is(err, ErrInvalidLayout) {
+		t.Errorf("Expected a price for an unknown section to be refused, got %v", err)
+	}
+}
+
+func TestVenuePrepareRefusesDuplicateSeats(t *testing.T) {
+	venue := &Venue{Name: "Istora", Sections: []VenueSection{
+		{Name: "VIP", Rows: []VenueRow{{Name: "A", Seats: []VenueSeat{{Number: 1}, {Number: 1}}}}},
+	}}
+	if err := venue.Prepare(); !errors.Is(err, ErrInvalidLayout) {
+		t.Errorf("Expected ErrInvalidLayout, got %v", err)
+	}
+}
@@ -1,70 +1,386 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/flashtix/server/internal/domain"
 	"github.com/flashtix/server/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
+// QueueTokenHeader carries the waiting room position token on purchase requests
+const QueueTokenHeader = "X-Queue-Token"
+
 type TicketHandler struct {
 	ticketService *services.TicketService
+	waitingRoom   *services.WaitingRoom // nil when the waiting room is disabled
 }
 
-func NewTicketHandler(ticketService *services.TicketService) *TicketHandler {
-	return &TicketHandler{ticketService: ticketService}
+func NewTicketHandler(ticketService *services.TicketService, waitingRoom *services.WaitingRoom) *TicketHandler {
+	return &TicketHandler{ticketService: ticketService, waitingRoom: waitingRoom}
 }
 
-func (h *TicketHandler) ReserveSeat(c *gin.Context) {
-	var req struct {
-		EventID string `json:"event_id" binding:"required"`
-		Seat    string `json:"seat" binding:"required"`
+// admitted checks the caller's waiting room token for the event and writes
+// a 403 response when they have not been let in yet
+func (h *TicketHandler) admitted(c *gin.Context, eventID string) bool {
+	if h.waitingRoom == nil {
+		return true
+	}
+
+	err := h.waitingRoom.Admit(c.Request.Context(), eventID, c.GetString("user_id"), c.GetHeader(QueueTokenHeader))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, domain.ErrInvalidQueueToken) || errors.Is(err, domain.ErrNotAdmitted) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return false
 	}
+	return true
+}
 
+// ReserveSeat reserves either a single "seat" or a list of "seats" or venue
+// "seat_ids" held together under one hold ID
+func (h *TicketHandler) ReserveSeat(c *gin.Context) {
+	var req purchaseRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := req.check(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.admitted(c, req.EventID) {
+		return
+	}
 
 	userID := c.GetString("user_id") // from auth middleware
-	err := h.ticketService.ReserveSeat(c.Request.Context(), req.EventID, req.Seat, userID)
+	var hold *domain.Hold
+	var err error
+	if req.TierID != "" {
+		hold, err = h.ticketService.ReserveTier(c.Request.Context(), req.EventID, req.TierID, req.Quantity, userID)
+	} else {
+		var seats []string
+		if seats, err = h.seats(c, &req); err == nil {
+			hold, err = h.ticketService.ReserveSeats(c.Request.Context(), req.EventID, seats, userID)
+		}
+	}
 	if err != nil {
-		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		var conflict *domain.SeatConflictError
+		if errors.As(err, &conflict) {
+			c.JSON(reserveErrorStatus(err), gin.H{"error": err.Error(), "seats": conflict.Seats})
+			return
+		}
+		c.JSON(reserveErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Seat reserved successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Seat reserved successfully", "hold": hold})
 }
 
-func (h *TicketHandler) ConfirmPurchase(c *gin.Context) {
-	var req struct {
-		EventID string `json:"event_id" binding:"required"`
-		Seat    string `json:"seat" binding:"required"`
+// purchaseRequest names what to reserve or confirm: a seat, a list of seats
+// by label or by venue seat ID, or a quantity of a general-admission tier
+type purchaseRequest struct {
+	EventID  string   `json:"event_id" binding:"required"`
+	Seat     string   `json:"seat"`
+	Seats    []string `json:"seats"`
+	SeatIDs  []string `json:"seat_ids"`
+	TierID   string   `json:"tier_id"`
+	Quantity int      `json:"quantity"`
+}
+
+func (r *purchaseRequest) check() error {
+	given := 0
+	for _, ok := range []bool{r.Seat != "", len(r.Seats) > 0, len(r.SeatIDs) > 0, r.TierID != ""} {
+		if ok {
+			given++
+		}
+	}
+	if given != 1 {
+		return errors.New("exactly one of seat, seats, seat_ids or tier_id is required")
+	}
+	if r.TierID == "" && r.Quantity != 0 {
+		return errors.New("quantity needs a tier_id")
+	}
+	return nil
+}
+
+// seats lists the seat labels of a request naming seats, resolving venue
+// seat IDs to the labels of the event's tickets
+func (h *TicketHandler) seats(c *gin.Context, r *purchaseRequest) ([]string, error) {
+	switch {
+	case r.Seat != "":
+		return []string{r.Seat}, nil
+	case len(r.SeatIDs) > 0:
+		return h.ticketService.SeatLabels(c.Request.Context(), r.EventID, r.SeatIDs)
 	}
+	return r.Seats, nil
+}
+
+func reserveErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrNoSeats),
+		errors.Is(err, services.ErrTooManySeats),
+		errors.Is(err, domain.ErrInvalidTier):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrTicketNotFound),
+		errors.Is(err, domain.ErrEventNotFound),
+		errors.Is(err, domain.ErrTierNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrSeatTaken),
+		errors.Is(err, domain.ErrSoldOut),
+		errors.Is(err, domain.ErrHoldLimit),
+		errors.Is(err, domain.ErrPurchaseLimit):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
 
+// ConfirmPurchase sells what the caller reserved and paid for. A single seat
+// confirms the whole hold covering it; a list of seats or a tier and
+// quantity sell just those and release the rest of their hold.
+func (h *TicketHandler) ConfirmPurchase(c *gin.Context) {
+	var req purchaseRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := req.check(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.admitted(c, req.EventID) {
+		return
+	}
 
+	ctx := c.Request.Context()
 	userID := c.GetString("user_id")
-	err := h.ticketService.ConfirmPurchase(c.Request.Context(), req.EventID, req.Seat, userID)
+	var seats []string
+	var err error
+	switch {
+	case req.Seat != "":
+		seats, err = h.ticketService.ConfirmPurchase(ctx, req.EventID, req.Seat, userID)
+	case req.TierID != "":
+		seats, err = h.ticketService.ConfirmTier(ctx, req.EventID, req.TierID, req.Quantity, userID)
+	default:
+		if seats, err = h.seats(c, &req); err == nil {
+			seats, err = h.ticketService.ConfirmSeats(ctx, req.EventID, seats, userID)
+		}
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(confirmErrorStatus(err), gin.H{"error": err.Error(), "seats": seats})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Purchase confirmed", "seats": seats})
+}
+
+func confirmErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrNoSeats),
+		errors.Is(err, services.ErrTooManySeats),
+		errors.Is(err, services.ErrNotInHold):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrTicketNotFound),
+		errors.Is(err, domain.ErrEventNotFound),
+		errors.Is(err, domain.ErrTierNotFound),
+		errors.Is(err, domain.ErrHoldNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrPaymentRequired), errors.Is(err, domain.ErrPaymentFailed):
+		return http.StatusPaymentRequired
+	case errors.Is(err, domain.ErrSeatNotReserved),
+		errors.Is(err, domain.ErrSeatTaken),
+		errors.Is(err, domain.ErrReservationNotOwned),
+		errors.Is(err, domain.ErrReservationExpired),
+		errors.Is(err, domain.ErrStaleLockToken),
+		errors.Is(err, domain.ErrPurchaseLimit):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// GetEventSeats returns the public seat map of an event. It is safe to poll:
+// responses are shared between callers for about a second.
+func (h *TicketHandler) GetEventSeats(c *gin.Context) {
+	eventID := c.Param("id")
+	seats, err := h.ticketService.SeatMap(c.Request.Context(), eventID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, domain.ErrEventNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Purchase confirmed"})
+	c.Header("Cache-Control", "public, max-age=1")
+	c.JSON(http.StatusOK, gin.H{"event_id": eventID, "seats": seats})
+}
+
+// GetEventTiers returns the ticket tiers of an event with the tickets left
+// of each general-admission tier
+func (h *TicketHandler) GetEventTiers(c *gin.Context) {
+	tiers, err := h.ticketService.Tiers(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(tierErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"event_id": c.Param("id"), "tiers": tiers})
+}
+
+// StreamEventSeats pushes seat transitions of an event as Server-Sent
+// Events. When the client falls too far behind the server sends "resync"
+// and closes the stream; the client should reload the seat map and reconnect.
+func (h *TicketHandler) StreamEventSeats(c *gin.Context) {
+	eventID := c.Param("id")
+	updates, unsubscribe, err := h.ticketService.SubscribeSeatEvents(c.Request.Context(), eventID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, domain.ErrEventNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	defer unsubscribe()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no") // keep proxies from buffering the stream
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case update, ok := <-updates:
+			if !ok {
+				c.SSEvent("resync", gin.H{"event_id": eventID})
+				return false
+			}
+			c.SSEvent(update.Type, update)
+			return true
+		case <-heartbeat.C:
+			c.SSEvent("ping", gin.H{"at": time.Now()})
+			return true
+		}
+	})
+}
+
+// GetHold returns the caller's hold with its seats and remaining time
+func (h *TicketHandler) GetHold(c *gin.Context) {
+	hold, err := h.ticketService.GetHold(c.Request.Context(), c.Param("id"), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(holdErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, holdResponse(hold))
+}
+
+// ExtendHold pushes the caller's hold expiry back once
+func (h *TicketHandler) ExtendHold(c *gin.Context) {
+	hold, err := h.ticketService.ExtendHold(c.Request.Context(), c.Param("id"), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(holdErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, holdResponse(hold))
+}
+
+// StartPayment starts the payment for the caller's hold. The client
+// completes it with the provider using the returned client secret.
+func (h *TicketHandler) StartPayment(c *gin.Context) {
+	payment, err := h.ticketService.StartPayment(c.Request.Context(), c.Param("id"), c.GetString("user_id"))
+	if err != nil {
+		status := holdErrorStatus(err)
+		if errors.Is(err, domain.ErrSeatNotReserved) || errors.Is(err, domain.ErrPaymentConflict) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"payment": payment})
+}
+
+// ConfirmHold sells the seats of the caller's paid hold
+func (h *TicketHandler) ConfirmHold(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetString("user_id")
+	hold, err := h.ticketService.GetHold(ctx, c.Param("id"), userID)
+	if err != nil {
+		c.JSON(holdErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if !h.admitted(c, hold.EventID) {
+		return
+	}
+
+	seats, err := h.ticketService.ConfirmHold(ctx, hold.ID, userID)
+	if err != nil {
+		c.JSON(confirmErrorStatus(err), gin.H{"error": err.Error(), "seats": seats})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Purchase confirmed", "seats": seats})
+}
+
+// ReleaseHold cancels the caller's hold and frees its seats
+func (h *TicketHandler) ReleaseHold(c *gin.Context) {
+	err := h.ticketService.ReleaseHold(c.Request.Context(), c.Param("id"), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(holdErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Hold released"})
+}
+
+func holdResponse(hold *domain.Hold) gin.H {
+	remaining := int(time.Until(hold.ExpiresAt).Seconds())
+	if remaining < 0 {
+		remaining = 0
+	}
+	return gin.H{
+		"hold":                 hold,
+		"remaining_seconds":    remaining,
+		"extensions_remaining": max(services.MaxHoldExtensions-hold.Extensions, 0),
+	}
+}
+
+func holdErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrHoldNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrReservationExpired),
+		errors.Is(err, domain.ErrHoldExtensionLimit),
+		errors.Is(err, domain.ErrReservationNotOwned),
+		errors.Is(err, domain.ErrSeatTaken):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
 }
 
 type EventHandler struct {
-	eventRepo domain.EventRepository
+	eventRepo  domain.EventRepository
+	ticketRepo domain.TicketRepository
+	venueRepo  domain.VenueRepository
+	tierRepo   domain.TicketTierRepository
 }
 
-func NewEventHandler(eventRepo domain.EventRepository) *EventHandler {
-	return &EventHandler{eventRepo: eventRepo}
+func NewEventHandler(eventRepo domain.EventRepository, ticketRepo domain.TicketRepository, venueRepo domain.VenueRepository, tierRepo domain.TicketTierRepository) *EventHandler {
+	return &EventHandler{eventRepo: eventRepo, ticketRepo: ticketRepo, venueRepo: venueRepo, tierRepo: tierRepo}
 }
 
 func (h *EventHandler) GetEvents(c *gin.Context) {
@@ -77,18 +393,195 @@ func (h *EventHandler) GetEvents(c *gin.Context) {
 	c.JSON(http.StatusOK, events)
 }
 
+// createEventRequest is an event with, optionally, what its seats are
+// generated from: a layout, or section prices for the seat map of the
+// event's venue
+type createEventRequest struct {
+	domain.Event
+	Layout *domain.SeatLayout         `json:"layout"`
+	Prices map[string]decimal.Decimal `json:"prices"`
+}
+
 func (h *EventHandler) CreateEvent(c *gin.Context) {
-	var event domain.Event
-	if err := c.ShouldBindJSON(&event); err != nil {
+	var req createEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	event := req.Event
+	if event.Currency == "" {
+		event.Currency = domain.DefaultCurrency
+	}
+	currency, err := domain.NormalizeCurrency(event.Currency)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	event.Currency = currency
+	if event.RefundCutoffHours < 0 || event.RefundFeePerTicket.IsNegative() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refund_cutoff_hours and refund_fee_per_ticket must not be negative"})
+		return
+	}
+	event.RefundFeePerTicket = domain.RoundMoney(event.RefundFeePerTicket, event.Currency)
+
+	// Events at a venue take its name and, unless given, its capacity
+	var venue *domain.Venue
+	if event.VenueID != "" {
+		venue, err = h.venueRepo.GetByID(c.Request.Context(), event.VenueID)
+		if errors.Is(err, domain.ErrVenueNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if event.Venue == "" {
+			event.Venue = venue.Name
+		}
+		if event.Capacity == 0 {
+			event.Capacity = venue.Capacity
+		}
+	}
 
 	event.ID = uuid.New().String()
-	if err := h.eventRepo.Create(c.Request.Context(), &event); err != nil {
+	// The seats are checked before the event is stored
+	tickets, err := eventTickets(&event, event.Capacity, venue, req.Layout, req.Prices)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.eventRepo.CreateWithInventory(c.Request.Context(), &event, tickets); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusCreated, event)
 }
+
+// generateSeatsRequest is a layout or, for an event at a venue, the price
+// of every section of the venue
+type generateSeatsRequest struct {
+	domain.SeatLayout
+	Prices map[string]decimal.Decimal `json:"prices"`
+}
+
+// GenerateSeats creates the seats of an event that has none, which must
+// seat exactly the event's capacity less its general-admission tickets
+func (h *EventHandler) GenerateSeats(c *gin.Context) {
+	var req generateSeatsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, err := h.eventRepo.GetByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(inventoryErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	var venue *domain.Venue
+	if event.VenueID != "" {
+		if venue, err = h.venueRepo.GetByID(c.Request.Context(), event.VenueID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	tiers, err := h.tierRepo.ListByEvent(c.Request.Context(), event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var layout *domain.SeatLayout
+	if len(req.Sections) > 0 {
+		layout = &req.SeatLayout
+	}
+	tickets, err := eventTickets(event, domain.SeatedCapacity(event, tiers), venue, layout, req.Prices)
+	if err == nil && tickets == nil {
+		err = fmt.Errorf("%w: give a layout or section prices", domain.ErrInvalidLayout)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.ticketRepo.CreateInventory(c.Request.Context(), event.ID, tickets); err != nil {
+		c.JSON(inventoryErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"event_id": event.ID, "seats": len(tickets)})
+}
+
+// eventTickets generates the event's seats from a layout or, for an event
+// at a venue, from the venue's seat map priced per section; either must have
+// exactly seats seats. It returns no tickets when given neither.
+func eventTickets(event *domain.Event, seats int, venue *domain.Venue, layout *domain.SeatLayout, prices map[string]decimal.Decimal) ([]*domain.Ticket, error) {
+	switch {
+	case layout != nil && venue != nil:
+		return nil, fmt.Errorf("%w: events at a venue use its seat map, give section prices instead", domain.ErrInvalidLayout)
+	case layout != nil:
+		return layout.Tickets(event, seats)
+	case prices != nil && venue == nil:
+		return nil, fmt.Errorf("%w: section prices need a venue_id", domain.ErrInvalidLayout)
+	case prices != nil:
+		return venue.Tickets(event, seats, prices)
+	}
+	return nil, nil
+}
+
+// CreateTier adds a ticket tier to an event. A general-admission tier comes
+// with its tickets, which must fit into the event's capacity next to its
+// seats and other tiers; a reserved tier groups sections of the seat map.
+func (h *EventHandler) CreateTier(c *gin.Context) {
+	var tier domain.TicketTier
+	if err := c.ShouldBindJSON(&tier); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tier.ID = uuid.New().String()
+	tier.EventID = c.Param("id")
+	tier.Available = nil
+	if err := tier.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, err := h.eventRepo.GetByID(c.Request.Context(), tier.EventID)
+	if err != nil {
+		c.JSON(tierErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	var tickets []*domain.Ticket
+	if tier.GeneralAdmission() {
+		tier.Price = domain.RoundMoney(tier.Price, event.Currency)
+		tickets = tier.Tickets(event)
+	}
+	if err := h.tierRepo.Create(c.Request.Context(), &tier, tickets); err != nil {
+		c.JSON(tierErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"tier": tier})
+}
+
+func tierErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrEventNotFound), errors.Is(err, domain.ErrTierNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrInvalidTier):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrCapacityExceeded):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func inventoryErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrEventNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrInventoryExists):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/flashtix/server/internal/domain"
+	"github.com/flashtix/server/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type OrderHandler struct {
+	orderRepo domain.OrderRepository
+	refunds   *services.RefundService
+}
+
+func NewOrderHandler(orderRepo domain.OrderRepository, refunds *services.RefundService) *OrderHandler {
+	return &OrderHandler{orderRepo: orderRepo, refunds: refunds}
+}
+
+// ListOrders returns the caller's orders, newest first
+func (h *OrderHandler) ListOrders(c *gin.Context) {
+	orders, err := h.orderRepo.ListByUser(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"orders": orders})
+}
+
+// GetOrder returns one of the caller's orders. Orders of other users are
+// reported as not found so their IDs cannot be probed.
+func (h *OrderHandler) GetOrder(c *gin.Context) {
+	order, err := h.orderRepo.GetByID(c.Request.Context(), c.Param("id"))
+	if err == nil && order.UserID != c.GetString("user_id") {
+		err = domain.ErrOrderNotFound
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, domain.ErrOrderNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"order": order})
+}
+
+// RequestRefund refunds some or all tickets of one of the caller's orders,
+// within the event's refund policy. Without seats every ticket not yet
+// refunded is.
+func (h *OrderHandler) RequestRefund(c *gin.Context) {
+	var req struct {
+		Seats []string `json:"seats"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength != 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.refund(c, services.RefundRequest{
+		OrderID:     c.Param("id"),
+		Seats:       req.Seats,
+		RequestedBy: c.GetString("user_id"),
+	})
+}
+
+// AdminRefund refunds tickets of any order regardless of the refund window
+// and without fee. restock overrides whether the seats go back on sale.
+func (h *OrderHandler) AdminRefund(c *gin.Context) {
+	var req struct {
+		Seats   []string `json:"seats"`
+		Restock *bool    `json:"restock"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength != 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.refund(c, services.RefundRequest{
+		OrderID:     c.Param("id"),
+		Seats:       req.Seats,
+		RequestedBy: c.GetString("user_id"),
+		Admin:       true,
+		Restock:     req.Restock,
+	})
+}
+
+func (h *OrderHandler) refund(c *gin.Context, req services.RefundRequest) {
+	refund, err := h.refunds.RefundOrder(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(refundErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"refund": refund})
+}
+
+func refundErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrOrderNotFound),
+		errors.Is(err, domain.ErrEventNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrTicketNotFound):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrRefundNotAllowed),
+		errors.Is(err, domain.ErrRefundWindowClosed):
+		return http.StatusForbidden
+	case errors.Is(err, domain.ErrOrderNotRefundable),
+		errors.Is(err, domain.ErrAlreadyRefunded),
+		errors.Is(err, domain.ErrTicketNotRefundable):
+		return http.StatusConflict
+	case errors.Is(err, domain.ErrPaymentFailed):
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/flashtix/server/internal/domain"
+	"github.com/flashtix/server/internal/payments"
+	"github.com/flashtix/server/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// maxWebhookSize bounds the webhook body read before its signature is known
+// to be valid; Stripe events are far smaller
+const maxWebhookSize = 256 << 10
+
+// PaymentWebhookHandler receives the payment provider's webhook. Only
+// failures worth retrying get a non-2xx reply, since the provider redelivers
+// those.
+type PaymentWebhookHandler struct {
+	ticketService *services.TicketService
+	events        domain.PaymentEventRepository
+	secret        string
+}
+
+func NewPaymentWebhookHandler(ticketService *services.TicketService, events domain.PaymentEventRepository, secret string) *PaymentWebhookHandler {
+	return &PaymentWebhookHandler{ticketService: ticketService, events: events, secret: secret}
+}
+
+// Receive verifies the Stripe-Signature header, records the event and,
+// unless an earlier delivery was already processed, applies it to its hold
+func (h *PaymentWebhookHandler) Receive(c *gin.Context) {
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+	if err := payments.VerifySignature(payload, c.GetHeader("Stripe-Signature"), h.secret, payments.SignatureTolerance, time.Now()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	event, err := payments.ParseWebhookEvent(payload)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	pending, err := h.events.Record(ctx, event)
+	if err != nil {
+		log.Printf("Failed to record payment event %s: %v", event.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record event"})
+		return
+	}
+	if !pending {
+		c.JSON(http.StatusOK, gin.H{"received": true, "duplicate": true})
+		return
+	}
+
+	result, err := h.ticketService.HandlePaymentEvent(ctx, event)
+	if err != nil {
+		log.Printf("Failed to process payment event %s (%s): %v", event.ID, event.ProviderType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process event"})
+		return
+	}
+	if err := h.events.MarkProcessed(ctx, event.ID, result); err != nil {
+		log.Printf("Failed to mark payment event %s processed: %v", event.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record event"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"received": true, "result": result})
+}
@@ -0,0 +1,394 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/flashtix/server/internal/domain"
+	"github.com/flashtix/server/internal/middleware"
+	"github.com/flashtix/server/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	socketAuthTimeout  = 10 * time.Second
+	socketPingInterval = 30 * time.Second
+	socketReadTimeout  = 2 * socketPingInterval
+	socketWriteTimeout = 10 * time.Second
+	// maxHoverLocks caps the seats one connection hover locks at once
+	maxHoverLocks = 2
+)
+
+var errHoverLimit = fmt.Errorf("at most %d seats can be hover locked at once", maxHoverLocks)
+
+// socketCommand is a message from the seat picker. ID is optional and is
+// echoed in the reply so the client can match replies to commands.
+type socketCommand struct {
+	ID         string   `json:"id,omitempty"`
+	Type       string   `json:"type"` // auth, subscribe, lock, unlock, reserve, release
+	Token      string   `json:"token,omitempty"`
+	EventID    string   `json:"event_id,omitempty"`
+	QueueToken string   `json:"queue_token,omitempty"` // waiting room token, sent with subscribe
+	Seat       string   `json:"seat,omitempty"`
+	Seats      []string `json:"seats,omitempty"`
+}
+
+// socketMessage is a reply or push to the seat picker
+type socketMessage struct {
+	ID    string      `json:"id,omitempty"`
+	Type  string      `json:"type"` // authenticated, snapshot, seat, locked, unlocked, reserved, released, countdown, expired, error
+	Data  interface{} `json:"data,omitempty"`
+	Error string      `json:"error,omitempty"`
+	Seats []string    `json:"seats,omitempty"`
+}
+
+// SeatSocketHandler serves the seat picker's WebSocket. A connection
+// authenticates once, subscribes to one event at a time and then sends
+// commands without further HTTP round trips.
+type SeatSocketHandler struct {
+	ticketService *services.TicketService
+	waitingRoom   *services.WaitingRoom // nil when the waiting room is disabled
+	rateLimiter   domain.RateLimiter
+	reserveLimit  middleware.RateLimit // applied to lock and reserve, as on POST /tickets/reserve
+	secret        string
+	upgrader      websocket.Upgrader
+}
+
+func NewSeatSocketHandler(ticketService *services.TicketService, waitingRoom *services.WaitingRoom, rateLimiter domain.RateLimiter, reserveLimit middleware.RateLimit, secret string) *SeatSocketHandler {
+	return &SeatSocketHandler{
+		ticketService: ticketService,
+		waitingRoom:   waitingRoom,
+		rateLimiter:   rateLimiter,
+		reserveLimit:  reserveLimit,
+		secret:        secret,
+		upgrader: websocket.Upgrader{
+			// Same policy as CORSMiddleware; requests are authorized by JWT
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// seatSocket is the state of one connection. Everything but the read loop
+// runs on the goroutine serving the connection, so no locking is needed.
+type seatSocket struct {
+	conn         *websocket.Conn
+	service      *services.TicketService
+	waitingRoom  *services.WaitingRoom
+	rateLimiter  domain.RateLimiter
+	reserveLimit middleware.RateLimit
+	userID       string
+	eventID      string
+	queueToken   string
+	updates      <-chan domain.SeatEvent
+	unsubscribe  func()
+	holds        map[string]*socketHold
+	hoverLocks   map[string]time.Time // seat -> expiry
+}
+
+// socketHold is a hold made over the connection and the seats it still has
+type socketHold struct {
+	expiresAt time.Time
+	seats     []string
+}
+
+// Serve upgrades the request and runs the connection until either side
+// closes it
+func (h *SeatSocketHandler) Serve(c *gin.Context) {
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already written an HTTP error
+		return
+	}
+	defer conn.Close()
+
+	ctx := c.Request.Context()
+	userID, err := h.authenticate(conn)
+	if err != nil {
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, err.Error()))
+		return
+	}
+
+	socket := &seatSocket{
+		conn:         conn,
+		service:      h.ticketService,
+		waitingRoom:  h.waitingRoom,
+		rateLimiter:  h.rateLimiter,
+		reserveLimit: h.reserveLimit,
+		userID:       userID,
+		holds:        make(map[string]*socketHold),
+		hoverLocks:   make(map[string]time.Time),
+	}
+	defer socket.close()
+	socket.send(socketMessage{Type: "authenticated"})
+
+	socket.run(ctx)
+}
+
+// authenticate expects an auth command carrying the JWT as the first message
+func (h *SeatSocketHandler) authenticate(conn *websocket.Conn) (string, error) {
+	conn.SetReadDeadline(time.Now().Add(socketAuthTimeout))
+
+	var cmd socketCommand
+	if err := conn.ReadJSON(&cmd); err != nil {
+		return "", err
+	}
+	if cmd.Type != "auth" {
+		return "", errors.New("first message must be auth")
+	}
+
+	userID, err := middleware.ParseToken(cmd.Token, h.secret)
+	if err != nil || userID == "" {
+		return "", errors.New("invalid token")
+	}
+	return userID, nil
+}
+
+func (s *seatSocket) run(ctx context.Context) {
+	commands := make(chan socketCommand)
+	readErr := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go s.read(commands, readErr, done)
+
+	ping := time.NewTicker(socketPingInterval)
+	defer ping.Stop()
+	countdown := time.NewTicker(time.Second)
+	defer countdown.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-readErr:
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Println("Seat socket closed:", err)
+			}
+			return
+		case cmd := <-commands:
+			s.handle(ctx, cmd)
+		case update, ok := <-s.updates:
+			if !ok {
+				// Fell too far behind; start over from a fresh snapshot
+				s.subscribe(ctx, "", s.eventID)
+				continue
+			}
+			s.send(socketMessage{Type: "seat", Data: update})
+		case <-countdown.C:
+			s.countdown(ctx)
+		case <-ping.C:
+			if err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// read decodes commands until the connection fails or run returns
+func (s *seatSocket) read(commands chan<- socketCommand, readErr chan<- error, done <-chan struct{}) {
+	s.conn.SetReadDeadline(time.Now().Add(socketReadTimeout))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(socketReadTimeout))
+	})
+
+	for {
+		var cmd socketCommand
+		if err := s.conn.ReadJSON(&cmd); err != nil {
+			readErr <- err
+			return
+		}
+		select {
+		case commands <- cmd:
+		case <-done:
+			return
+		}
+	}
+}
+
+func (s *seatSocket) handle(ctx context.Context, cmd socketCommand) {
+	if cmd.Type == "subscribe" {
+		s.queueToken = cmd.QueueToken
+		s.subscribe(ctx, cmd.ID, cmd.EventID)
+		return
+	}
+	if s.eventID == "" {
+		s.send(socketMessage{ID: cmd.ID, Type: "error", Error: "subscribe to an event first"})
+		return
+	}
+	if cmd.Type == "lock" || cmd.Type == "reserve" {
+		if allowed, retryAfter := middleware.AllowUser(ctx, s.rateLimiter, s.reserveLimit, s.userID); !allowed {
+			s.send(socketMessage{ID: cmd.ID, Type: "error", Error: "Too many requests", Data: gin.H{"retry_after": retryAfter}})
+			return
+		}
+		if s.waitingRoom != nil {
+			if err := s.waitingRoom.Admit(ctx, s.eventID, s.userID, s.queueToken); err != nil {
+				s.sendError(cmd.ID, err)
+				return
+			}
+		}
+	}
+
+	switch cmd.Type {
+	case "lock":
+		if _, ok := s.hoverLocks[cmd.Seat]; !ok && len(s.hoverLocks) >= maxHoverLocks {
+			s.sendError(cmd.ID, errHoverLimit)
+			return
+		}
+		expiresAt, err := s.service.HoverLock(ctx, s.eventID, cmd.Seat, s.userID)
+		if err != nil {
+			s.sendError(cmd.ID, err)
+			return
+		}
+		if !expiresAt.IsZero() {
+			s.hoverLocks[cmd.Seat] = expiresAt
+		}
+		s.send(socketMessage{ID: cmd.ID, Type: "locked", Data: gin.H{"seat": cmd.Seat, "expires_at": expiresAt}})
+
+	case "unlock":
+		if err := s.service.ReleaseHoverLock(ctx, s.eventID, cmd.Seat, s.userID); err != nil {
+			s.sendError(cmd.ID, err)
+			return
+		}
+		delete(s.hoverLocks, cmd.Seat)
+		s.send(socketMessage{ID: cmd.ID, Type: "unlocked", Data: gin.H{"seat": cmd.Seat}})
+
+	case "reserve":
+		seats := cmd.Seats
+		if cmd.Seat != "" {
+			seats = append(seats, cmd.Seat)
+		}
+		hold, err := s.service.ReserveSeats(ctx, s.eventID, seats, s.userID)
+		if err != nil {
+			s.sendError(cmd.ID, err)
+			return
+		}
+		for _, seat := range hold.Seats {
+			delete(s.hoverLocks, seat)
+		}
+		s.holds[hold.ID] = &socketHold{expiresAt: hold.ExpiresAt, seats: hold.Seats}
+		s.send(socketMessage{ID: cmd.ID, Type: "reserved", Data: hold})
+
+	case "release":
+		if err := s.service.ReleaseSeat(ctx, s.eventID, cmd.Seat, s.userID); err != nil {
+			s.sendError(cmd.ID, err)
+			return
+		}
+		s.forgetSeat(cmd.Seat)
+		s.send(socketMessage{ID: cmd.ID, Type: "released", Data: gin.H{"seat": cmd.Seat}})
+
+	default:
+		s.send(socketMessage{ID: cmd.ID, Type: "error", Error: "unknown command " + cmd.Type})
+	}
+}
+
+// subscribe switches the connection to eventID and sends its seat map.
+// Updates are subscribed before the snapshot is taken so none are missed.
+func (s *seatSocket) subscribe(ctx context.Context, id, eventID string) {
+	if eventID != s.eventID {
+		s.releaseHoverLocks(ctx)
+	}
+	if s.unsubscribe != nil {
+		s.unsubscribe()
+		s.updates, s.unsubscribe = nil, nil
+	}
+
+	updates, unsubscribe, err := s.service.SubscribeSeatEvents(ctx, eventID)
+	if err != nil {
+		s.eventID = ""
+		s.sendError(id, err)
+		return
+	}
+	s.eventID, s.updates, s.unsubscribe = eventID, updates, unsubscribe
+
+	seats, err := s.service.SeatMap(ctx, eventID)
+	if err != nil {
+		s.sendError(id, err)
+		return
+	}
+	s.send(socketMessage{ID: id, Type: "snapshot", Data: gin.H{"event_id": eventID, "seats": seats}})
+}
+
+// forgetSeat drops a released seat from its hold, and the hold once it has
+// no seats left, so no more countdowns are sent for it
+func (s *seatSocket) forgetSeat(seat string) {
+	for holdID, hold := range s.holds {
+		seats := hold.seats[:0]
+		for _, held := range hold.seats {
+			if held != seat {
+				seats = append(seats, held)
+			}
+		}
+		hold.seats = seats
+		if len(seats) == 0 {
+			delete(s.holds, holdID)
+		}
+	}
+}
+
+// countdown reports the time left on the connection's holds and hover
+// locks, and announces the ones that have run out. Lapsed hover locks are
+// also announced to other buyers, since nothing else notices them.
+func (s *seatSocket) countdown(ctx context.Context) {
+	now := time.Now()
+	for holdID, hold := range s.holds {
+		if !now.Before(hold.expiresAt) {
+			delete(s.holds, holdID)
+			s.send(socketMessage{Type: "expired", Data: gin.H{"hold_id": holdID}})
+			continue
+		}
+		s.send(socketMessage{Type: "countdown", Data: gin.H{"hold_id": holdID, "remaining_seconds": int(hold.expiresAt.Sub(now).Seconds())}})
+	}
+	for seat, expiresAt := range s.hoverLocks {
+		if !now.Before(expiresAt) {
+			delete(s.hoverLocks, seat)
+			if err := s.service.HoverLockLapsed(ctx, s.eventID, seat, s.userID); err != nil {
+				log.Printf("Failed to end hover lock on %s/%s: %v", s.eventID, seat, err)
+			}
+			s.send(socketMessage{Type: "expired", Data: gin.H{"seat": seat}})
+			continue
+		}
+		s.send(socketMessage{Type: "countdown", Data: gin.H{"seat": seat, "remaining_seconds": int(expiresAt.Sub(now).Seconds())}})
+	}
+}
+
+func (s *seatSocket) send(msg socketMessage) {
+	s.conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+	if err := s.conn.WriteJSON(msg); err != nil {
+		// The read loop sees the broken connection and ends the session
+		s.conn.Close()
+	}
+}
+
+func (s *seatSocket) sendError(id string, err error) {
+	msg := socketMessage{ID: id, Type: "error", Error: err.Error()}
+	var conflict *domain.SeatConflictError
+	if errors.As(err, &conflict) {
+		msg.Seats = conflict.Seats
+	}
+	s.send(msg)
+}
+
+// close ends the subscription and gives up hover locks so other buyers do
+// not wait for them to expire
+func (s *seatSocket) close() {
+	if s.unsubscribe != nil {
+		s.unsubscribe()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), socketWriteTimeout)
+	defer cancel()
+	s.releaseHoverLocks(ctx)
+}
+
+func (s *seatSocket) releaseHoverLocks(ctx context.Context) {
+	for seat := range s.hoverLocks {
+		if err := s.service.ReleaseHoverLock(ctx, s.eventID, seat, s.userID); err != nil {
+			log.Printf("Failed to release hover lock on %s/%s: %v", s.eventID, seat, err)
+		}
+		delete(s.hoverLocks, seat)
+	}
+}
@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flashtix/server/internal/domain"
+	"github.com/flashtix/server/internal/middleware"
+	"github.com/flashtix/server/internal/repository/memory"
+	"github.com/flashtix/server/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+)
+
+const socketTestSecret = "test-secret"
+
+// socketEventRepo serves event1
+type socketEventRepo struct {
+	domain.EventRepository
+}
+
+func (r *socketEventRepo) GetByID(ctx context.Context, id string) (*domain.Event, error) {
+	if id != "event1" {
+		return nil, domain.ErrEventNotFound
+	}
+	return &domain.Event{ID: "event1", Currency: "USD"}, nil
+}
+
+// socketTicketRepo keeps the seats of event1 in memory. When release is
+// set, ReleaseSeat signals on releasing and waits for release to close.
+type socketTicketRepo struct {
+	domain.TicketRepository
+	mu        sync.Mutex
+	tickets   map[string]*domain.Ticket
+	releasing chan struct{}
+	release   chan struct{}
+}
+
+func newSocketTicketRepo(seats ...string) *socketTicketRepo {
+	repo := &socketTicketRepo{tickets: make(map[string]*domain.Ticket)}
+	for _, seat := range seats {
+		repo.tickets[seat] = &domain.Ticket{ID: "ticket-" + seat, EventID: "event1", Seat: seat, Status: domain.TicketAvailable}
+	}
+	return repo
+}
+
+func (r *socketTicketRepo) GetByEventID(ctx context.Context, eventID string) ([]*domain.Ticket, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tickets := make([]*domain.Ticket, 0, len(r.tickets))
+	for _, ticket := range r.tickets {
+		copied := *ticket
+		tickets = append(tickets, &copied)
+	}
+	return tickets, nil
+}
+
+func (r *socketTicketRepo) GetByEventAndSeat(ctx context.Context, eventID, seat string) (*domain.Ticket, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ticket, ok := r.tickets[seat]
+	if !ok {
+		return nil, domain.ErrTicketNotFound
+	}
+	copied := *ticket
+	return &copied, nil
+}
+
+func (r *socketTicketRepo) ReserveSeats(ctx context.Context, eventID string, seats []string, lockTokens []int64, userID, holdID string, duration time.Duration) (domain.SeatResult, []string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, seat := range seats {
+		if ticket, ok := r.tickets[seat]; !ok || ticket.Status != domain.TicketAvailable {
+			return domain.SeatTaken, []string{seat}, nil
+		}
+	}
+	for _, seat := range seats {
+		ticket := r.tickets[seat]
+		ticket.Status, ticket.UserID, ticket.HoldID = domain.TicketReserved, userID, holdID
+	}
+	return domain.SeatOK, nil, nil
+}
+
+func (r *socketTicketRepo) ReleaseSeat(ctx context.Context, eventID, seat string, userID string) (domain.SeatResult, error) {
+	if r.release != nil {
+		r.releasing <- struct{}{}
+		<-r.release
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ticket, ok := r.tickets[seat]
+	if !ok || ticket.Status != domain.TicketReserved {
+		return domain.SeatNotReserved, nil
+	}
+	if ticket.UserID != userID {
+		return domain.SeatTaken, nil
+	}
+	ticket.Status, ticket.UserID, ticket.HoldID = domain.TicketAvailable, "", ""
+	return domain.SeatOK, nil
+}
+
+// newSocketTest serves the seat socket for a ticket service on top of repo
+// and the memory seat locker
+func newSocketTest(t *testing.T, repo *socketTicketRepo) (*services.TicketService, *memory.SeatLockRepository, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	locks := memory.NewSeatLockRepository()
+	service := services.NewTicketService(repo, &socketEventRepo{}, nil, nil, nil, locks, nil, services.OrderPricing{})
+	limit := middleware.RateLimit{Name: "reserve", Requests: 100, Window: time.Minute}
+	handler := NewSeatSocketHandler(service, nil, memory.NewRateLimitRepository(), limit, socketTestSecret)
+
+	r := gin.New()
+	r.GET("/ws/seats", handler.Serve)
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return service, locks, "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/seats"
+}
+
+func socketToken(t *testing.T, userID string) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"user_id": userID}).SignedString([]byte(socketTestSecret))
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return token
+}
+
+func dialSocket(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// connectSocket authenticates as userID and subscribes to event1
+func connectSocket(t *testing.T, url, userID string) *websocket.Conn {
+	t.Helper()
+	conn := dialSocket(t, url)
+	send(t, conn, socketCommand{Type: "auth", Token: socketToken(t, userID)})
+	await(t, conn, func(msg socketMessage) bool { return msg.Type == "authenticated" })
+	send(t, conn, socketCommand{ID: "sub", Type: "subscribe", EventID: "event1"})
+	if msg := reply(t, conn, "sub"); msg.Type != "snapshot" {
+		t.Fatalf("Expected a snapshot, got %+v", msg)
+	}
+	return conn
+}
+
+func send(t *testing.T, conn *websocket.Conn, cmd socketCommand) {
+	t.Helper()
+	if err := conn.WriteJSON(cmd); err != nil {
+		t.Fatalf("Failed to send %s: %v", cmd.Type, err)
+	}
+}
+
+// await reads messages until one matches, skipping pushes such as seat
+// updates and countdowns
+func await(t *testing.T, conn *websocket.Conn, match func(socketMessage) bool) socketMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var msg socketMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("Failed to read message: %v", err)
+		}
+		if match(msg) {
+			return msg
+		}
+	}
+}
+
+// reply awaits the reply to the command with the given ID
+func reply(t *testing.T, conn *websocket.Conn, id string) socketMessage {
+	t.Helper()
+	return await(t, conn, func(msg socketMessage) bool { return msg.ID == id })
+}
+
+func TestSeatSocketAuth(t *testing.T) {
+	_, _, url := newSocketTest(t, newSocketTicketRepo("A1"))
+
+	for name, cmd := range map[string]socketCommand{
+		"not auth":      {Type: "subscribe", EventID: "event1"},
+		"invalid token": {Type: "auth", Token: "not-a-jwt"},
+	} {
+		conn := dialSocket(t, url)
+		send(t, conn, cmd)
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+			t.Errorf("%s: expected a policy violation close, got %v", name, err)
+		}
+	}
+
+	conn := connectSocket(t, url, "user123")
+	send(t, conn, socketCommand{ID: "other", Type: "subscribe", EventID: "event2"})
+	if msg := reply(t, conn, "other"); msg.Type != "error" {
+		t.Errorf("Expected an error for an unknown event, got %+v", msg)
+	}
+	send(t, conn, socketCommand{ID: "lock", Type: "lock", Seat: "A1"})
+	if msg := reply(t, conn, "lock"); msg.Type != "error" {
+		t.Errorf("Expected lock without a subscription to fail, got %+v", msg)
+	}
+}
+
+func TestSeatSocketHoverLocks(t *testing.T) {
+	repo := newSocketTicketRepo("A1", "A2", "A3")
+	_, locks, url := newSocketTest(t, repo)
+	ctx := context.Background()
+	conn := connectSocket(t, url, "user123")
+
+	for _, seat := range []string{"A1", "A2"} {
+		send(t, conn, socketCommand{ID: seat, Type: "lock", Seat: seat})
+		if msg := reply(t, conn, seat); msg.Type != "locked" {
+			t.Fatalf("Expected %s to be locked, got %+v", seat, msg)
+		}
+	}
+	send(t, conn, socketCommand{ID: "A3", Type: "lock", Seat: "A3"})
+	if msg := reply(t, conn, "A3"); msg.Type != "error" || msg.Error != errHoverLimit.Error() {
+		t.Errorf("Expected the hover limit, got %+v", msg)
+	}
+	if holder, _ := locks.IsSeatLocked(ctx, "event1", "A3"); holder != "" {
+		t.Errorf("Expected A3 to stay unlocked, got holder %q", holder)
+	}
+
+	// Other buyers see the seat held
+	other := connectSocket(t, url, "user456")
+	send(t, other, socketCommand{ID: "lock", Type: "lock", Seat: "A1"})
+	if msg := reply(t, other, "lock"); msg.Type != "error" {
+		t.Errorf("Expected a seat locked by someone else to be refused, got %+v", msg)
+	}
+
+	// Reserving a hover-locked seat frees a hover slot
+	send(t, conn, socketCommand{ID: "reserve", Type: "reserve", Seat: "A1"})
+	if msg := reply(t, conn, "reserve"); msg.Type != "reserved" {
+		t.Fatalf("Expected A1 to be reserved, got %+v", msg)
+	}
+	send(t, conn, socketCommand{ID: "A3", Type: "lock", Seat: "A3"})
+	if msg := reply(t, conn, "A3"); msg.Type != "locked" {
+		t.Errorf("Expected A3 to be locked, got %+v", msg)
+	}
+
+	// Closing the connection gives up the hover locks but not the reservation
+	conn.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		a2, _ := locks.IsSeatLocked(ctx, "event1", "A2")
+		a3, _ := locks.IsSeatLocked(ctx, "event1", "A3")
+		if a2 == "" && a3 == "" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected hover locks to be released on close, got A2 %q and A3 %q", a2, a3)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if holder, _ := locks.IsSeatLocked(ctx, "event1", "A1"); holder != "user123" {
+		t.Errorf("Expected the reserved seat to stay locked, got holder %q", holder)
+	}
+}
+
+func TestSeatSocketResyncsAfterFallingBehind(t *testing.T) {
+	repo := newSocketTicketRepo("A1")
+	service, _, url := newSocketTest(t, repo)
+	conn := connectSocket(t, url, "user123")
+	send(t, conn, socketCommand{ID: "reserve", Type: "reserve", Seat: "A1"})
+	reply(t, conn, "reserve")
+
+	// Stall the connection in a release while its updates overflow
+	repo.releasing, repo.release = make(chan struct{}), make(chan struct{})
+	send(t, conn, socketCommand{ID: "release", Type: "release", Seat: "A1"})
+	<-repo.releasing
+	seats := make([]string, 100)
+	for i := range seats {
+		seats[i] = "A1"
+	}
+	service.SeatEvents().Publish("event1", domain.SeatEventLocked, seats...)
+	close(repo.release)
+
+	msg := await(t, conn, func(msg socketMessage) bool { return msg.Type == "snapshot" })
+	if data, ok := msg.Data.(map[string]interface{}); !ok || data["event_id"] != "event1" {
+		t.Errorf("Expected a fresh snapshot of event1, got %+v", msg)
+	}
+}
+
+func TestSeatSocketCountdownExpiry(t *testing.T) {
+	repo := newSocketTicketRepo("A1")
+	ctx := context.Background()
+	locks := memory.NewSeatLockRepository()
+	service := services.NewTicketService(repo, &socketEventRepo{}, nil, nil, nil, locks, nil, services.OrderPricing{})
+	updates, unsubscribe := service.SeatEvents().Subscribe("event1")
+	defer unsubscribe()
+
+	// Hand the server side of a connection to the test
+	conns := make(chan *websocket.Conn, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err == nil {
+			conns <- conn
+		}
+	}))
+	defer server.Close()
+	client := dialSocket(t, "ws"+strings.TrimPrefix(server.URL, "http"))
+	conn := <-conns
+	defer conn.Close()
+
+	locks.LockSeat(ctx, "event1", "A1", "user123", time.Minute)
+	past := time.Now().Add(-time.Second)
+	socket := &seatSocket{
+		conn:       conn,
+		service:    service,
+		userID:     "user123",
+		eventID:    "event1",
+		holds:      map[string]*socketHold{"hold1": {expiresAt: past, seats: []string{"B1"}}},
+		hoverLocks: map[string]time.Time{"A1": past},
+	}
+	socket.countdown(ctx)
+
+	expired := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		msg := await(t, client, func(msg socketMessage) bool { return true })
+		data, _ := msg.Data.(map[string]interface{})
+		if msg.Type != "expired" || data == nil {
+			t.Fatalf("Expected an expiry, got %+v", msg)
+		}
+		if holdID, ok := data["hold_id"].(string); ok {
+			expired[holdID] = true
+		}
+		if seat, ok := data["seat"].(string); ok {
+			expired[seat] = true
+		}
+	}
+	if !expired["hold1"] || !expired["A1"] {
+		t.Errorf("Expected hold1 and A1 to expire, got %v", expired)
+	}
+	if len(socket.holds) != 0 || len(socket.hoverLocks) != 0 {
+		t.Errorf("Expected no more countdowns, got %d holds and %d hover locks", len(socket.holds), len(socket.hoverLocks))
+	}
+
+	// The lapsed hover lock is given up and announced to other buyers
+	if holder, _ := locks.IsSeatLocked(ctx, "event1", "A1"); holder != "" {
+		t.Errorf("Expected A1 to be unlocked, got holder %q", holder)
+	}
+	if update := <-updates; update.Type != domain.SeatEventReleased || update.Seat != "A1" {
+		t.Errorf("Expected A1 to be announced released, got %+v", update)
+	}
+}
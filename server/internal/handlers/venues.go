@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/flashtix/server/internal/domain"
+	"github.com/gin-gonic/gin"
+)
+
+type VenueHandler struct {
+	venueRepo domain.VenueRepository
+}
+
+func NewVenueHandler(venueRepo domain.VenueRepository) *VenueHandler {
+	return &VenueHandler{venueRepo: venueRepo}
+}
+
+// ListVenues returns the venues by name, without their seat maps
+func (h *VenueHandler) ListVenues(c *gin.Context) {
+	venues, err := h.venueRepo.GetAll(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"venues": venues})
+}
+
+// GetVenue returns a venue with its seat map
+func (h *VenueHandler) GetVenue(c *gin.Context) {
+	venue, err := h.venueRepo.GetByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(venueErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"venue": venue})
+}
+
+// CreateVenue stores a venue with its seat map. Seat labels and the
+// capacity are derived from the map.
+func (h *VenueHandler) CreateVenue(c *gin.Context) {
+	var venue domain.Venue
+	if err := c.ShouldBindJSON(&venue); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	venue.ID = ""
+	if err := venue.Prepare(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.venueRepo.Create(c.Request.Context(), &venue); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"venue": venue})
+}
+
+// UpdateVenue changes a venue's name and address. Seat maps cannot be
+// changed since tickets refer to their seats; create a new venue instead.
+func (h *VenueHandler) UpdateVenue(c *gin.Context) {
+	var req struct {
+		Name    string `json:"name" binding:"required"`
+		Address string `json:"address"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	venue := &domain.Venue{ID: c.Param("id"), Name: req.Name, Address: req.Address}
+	if err := h.venueRepo.Update(c.Request.Context(), venue); err != nil {
+		c.JSON(venueErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	venue, err := h.venueRepo.GetByID(c.Request.Context(), venue.ID)
+	if err != nil {
+		c.JSON(venueErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"venue": venue})
+}
+
+// DeleteVenue removes a venue that no event uses
+func (h *VenueHandler) DeleteVenue(c *gin.Context) {
+	if err := h.venueRepo.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		c.JSON(venueErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func venueErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrVenueNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrVenueInUse):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
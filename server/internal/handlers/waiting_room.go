@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/flashtix/server/internal/domain"
+	"github.com/flashtix/server/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type WaitingRoomHandler struct {
+	waitingRoom *services.WaitingRoom
+}
+
+func NewWaitingRoomHandler(waitingRoom *services.WaitingRoom) *WaitingRoomHandler {
+	return &WaitingRoomHandler{waitingRoom: waitingRoom}
+}
+
+// Join puts the caller in the event's waiting room and returns the position
+// token to send in the X-Queue-Token header
+func (h *WaitingRoomHandler) Join(c *gin.Context) {
+	token, status, err := h.waitingRoom.Join(c.Request.Context(), c.Param("id"), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": token, "status": status})
+}
+
+// Status reports the caller's position and estimated wait
+func (h *WaitingRoomHandler) Status(c *gin.Context) {
+	status, err := h.waitingRoom.Status(c.Request.Context(), c.Param("id"), c.GetString("user_id"), c.GetHeader(QueueTokenHeader))
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, domain.ErrInvalidQueueToken) {
+			code = http.StatusForbidden
+		}
+		c.JSON(code, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
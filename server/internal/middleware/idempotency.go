@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/flashtix/server/internal/domain"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// IdempotencyKeyHeader names the client-chosen key of a retryable request
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	maxIdempotencyKeyLength = 255
+	// idempotencyPendingTTL bounds how long a crashed request blocks its key
+	idempotencyPendingTTL = time.Minute
+)
+
+// retryableStatuses are the client errors a retry of the same request may
+// get past: the buyer pays, is admitted from the waiting room, the conflict
+// clears or the rate limit resets
+var retryableStatuses = map[int]bool{
+	http.StatusPaymentRequired: true,
+	http.StatusForbidden:       true,
+	http.StatusConflict:        true,
+	http.StatusTooManyRequests: true,
+}
+
+// capturingWriter keeps a copy of the response body for replay
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware makes mutating requests that carry an
+// Idempotency-Key safe to retry. The first response for a (user, key) pair
+// is stored for ttl and replayed on retries; a retry while the first request
+// is still running, or one with a different body, gets a 409. Responses
+// that may change on retry, a 5xx or one of retryableStatuses, are not
+// stored so the request can be retried. It must run after AuthMiddleware.
+func IdempotencyMiddleware(store domain.IdempotencyStore, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		fingerprint := requestFingerprint(c.Request.Method, c.Request.URL.Path, body)
+
+		ctx := c.Request.Context()
+		storeKey := c.GetString("user_id") + ":" + key
+		existing, claimed, err := store.Begin(ctx, storeKey, fingerprint, idempotencyPendingTTL)
+		if err != nil {
+			log.Println("Idempotency store unavailable:", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Idempotency-Key could not be checked, retry later"})
+			return
+		}
+
+		if !claimed {
+			switch {
+			case existing.Fingerprint != fingerprint:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "Idempotency-Key was already used for a different request"})
+			case !existing.Completed:
+				c.Header("Retry-After", "1")
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still in progress"})
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(existing.Status, existing.ContentType, existing.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		writer := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		if writer.Status() >= http.StatusInternalServerError || retryableStatuses[writer.Status()] {
+			if err := store.Abandon(ctx, storeKey); err != nil {
+				log.Println("Failed to release idempotency key:", err)
+			}
+			return
+		}
+
+		record := &domain.IdempotencyRecord{
+			Fingerprint: fingerprint,
+			Completed:   true,
+			Status:      writer.Status(),
+			ContentType: writer.Header().Get("Content-Type"),
+			Body:        writer.body.Bytes(),
+		}
+		if err := store.Complete(ctx, storeKey, record, ttl); err != nil {
+			log.Println("Failed to store idempotent response:", err)
+		}
+	}
+}
+
+// requestFingerprint hashes the route and body of a request. The same key may
+// be reused on another endpoint by mistake, so the route is included.
+func requestFingerprint(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
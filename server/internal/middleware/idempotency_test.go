@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flashtix/server/internal/repository/memory"
+	"github.com/gin-gonic/gin"
+)
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := memory.NewIdempotencyRepository()
+
+	calls := 0
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", c.GetHeader("X-User")) })
+	r.Use(IdempotencyMiddleware(store, time.Hour))
+	r.POST("/confirm", func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusCreated, gin.H{"call": calls})
+	})
+
+	request := func(userID, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/confirm", strings.NewReader(body))
+		req.Header.Set("X-User", userID)
+		req.Header.Set(IdempotencyKeyHeader, key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	first := request("user123", "key1", `{"seat":"A1"}`)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", first.Code)
+	}
+
+	retry := request("user123", "key1", `{"seat":"A1"}`)
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Errorf("Expected the first response to be replayed, got %d %s", retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("Expected replay to be marked")
+	}
+	if calls != 1 {
+		t.Errorf("Expected the handler to run once, ran %d times", calls)
+	}
+
+	if w := request("user123", "key1", `{"seat":"A2"}`); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a different body, got %d", w.Code)
+	}
+
+	// Keys are scoped to the user
+	if w := request("user456", "key1", `{"seat":"A1"}`); w.Code != http.StatusCreated || calls != 2 {
+		t.Errorf("Expected another user's key to run the handler, got %d", w.Code)
+	}
+
+	// A request still in flight holds its key
+	if _, claimed, _ := store.Begin(context.Background(), "user123:key2", requestFingerprint(http.MethodPost, "/confirm", []byte("{}")), time.Minute); !claimed {
+		t.Fatal("Expected key2 to be free")
+	}
+	w := request("user123", "key2", "{}")
+	if w.Code != http.StatusConflict || w.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 409 with Retry-After while in flight, got %d", w.Code)
+	}
+}
+
+func TestIdempotencyMiddlewareRetriesUnfinishedOutcomes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := memory.NewIdempotencyRepository()
+
+	paid := false
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", "user123") })
+	r.Use(IdempotencyMiddleware(store, time.Hour))
+	r.POST("/confirm", func(c *gin.Context) {
+		if !paid {
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": "payment required"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "confirmed"})
+	})
+	r.POST("/missing", func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	})
+
+	request := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader("{}"))
+		req.Header.Set(IdempotencyKeyHeader, "key"+path)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := request("/confirm"); w.Code != http.StatusPaymentRequired {
+		t.Fatalf("Expected 402, got %d", w.Code)
+	}
+	// Once paid, the retry with the same key runs again
+	paid = true
+	if w := request("/confirm"); w.Code != http.StatusOK || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("Expected the retry after paying to run, got %d", w.Code)
+	}
+
+	// A final client error is replayed
+	request("/missing")
+	if w := request("/missing"); w.Code != http.StatusNotFound || w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("Expected the 404 to be replayed, got %d", w.Code)
+	}
+}
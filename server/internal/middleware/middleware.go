@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strings"
@@ -14,7 +15,8 @@ func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, X-Queue-Token, Idempotency-Key")
+		c.Header("Access-Control-Expose-Headers", "Retry-After, Idempotent-Replayed, RateLimit-Policy, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -36,24 +38,58 @@ func AuthMiddleware(secret string) gin.HandlerFunc {
 		}
 
 		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(secret), nil
-		})
-
-		if err != nil || !token.Valid {
+		userID, err := ParseToken(tokenString, secret)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			c.Set("user_id", claims["user_id"])
+		c.Set("user_id", userID)
+		c.Next()
+	}
+}
+
+// AdminMiddleware lets only the given users through. It must run after
+// AuthMiddleware.
+func AdminMiddleware(adminIDs []string) gin.HandlerFunc {
+	admins := make(map[string]bool, len(adminIDs))
+	for _, id := range adminIDs {
+		if id = strings.TrimSpace(id); id != "" {
+			admins[id] = true
 		}
+	}
 
+	return func(c *gin.Context) {
+		if !admins[c.GetString("user_id")] {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
 
+// ParseToken validates a JWT the way AuthMiddleware does and returns its
+// user_id claim
+func ParseToken(tokenString, secret string) (string, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if !token.Valid {
+		return "", errors.New("invalid token")
+	}
+
+	userID := ""
+	if claims, ok := token.Claims.(jwt.MapClaims); ok {
+		userID, _ = claims["user_id"].(string)
+	}
+	return userID, nil
+}
+
 // LoggingMiddleware for logging requests
 func LoggingMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
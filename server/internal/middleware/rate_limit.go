@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flashtix/server/internal/domain"
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit allows Requests per sliding Window. Name separates the counters
+// of different route groups.
+type RateLimit struct {
+	Name     string
+	Requests int
+	Window   time.Duration
+}
+
+// ParseRateLimit parses a limit written as "<requests>/<window>", for
+// example "10/1m"
+func ParseRateLimit(name, value string) (RateLimit, error) {
+	requests, window, ok := strings.Cut(value, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q, want <requests>/<window>", value)
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n < 1 {
+		return RateLimit{}, fmt.Errorf("invalid request count in rate limit %q", value)
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d < time.Second {
+		return RateLimit{}, fmt.Errorf("invalid window in rate limit %q", value)
+	}
+	return RateLimit{Name: name, Requests: n, Window: d}, nil
+}
+
+// RateLimitMiddleware throttles clients to limit. Requests are counted per
+// user_id when AuthMiddleware ran before it and per client IP otherwise.
+// Every response carries RateLimit-* headers; rejected requests get a 429
+// with Retry-After. If the limiter is unavailable requests are let through.
+func RateLimitMiddleware(limiter domain.RateLimiter, limit RateLimit) gin.HandlerFunc {
+	policy := fmt.Sprintf("%d;w=%d", limit.Requests, int(limit.Window.Seconds()))
+
+	return func(c *gin.Context) {
+		key := limit.Name + ":ip:" + c.ClientIP()
+		if userID := c.GetString("user_id"); userID != "" {
+			key = limit.Name + ":user:" + userID
+		}
+
+		window, err := limiter.Hit(c.Request.Context(), key, limit.Requests, limit.Window)
+		if err != nil {
+			log.Println("Rate limiter unavailable:", err)
+			c.Next()
+			return
+		}
+
+		// Share of the previous window still inside the sliding window
+		overlap := float64(limit.Window-window.Elapsed) / float64(limit.Window)
+		used := int(math.Ceil(float64(window.Previous)*overlap)) + window.Current
+		reset := seconds(limit.Window - window.Elapsed)
+
+		c.Header("RateLimit-Policy", policy)
+		c.Header("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		c.Header("RateLimit-Remaining", strconv.Itoa(max(limit.Requests-used, 0)))
+		c.Header("RateLimit-Reset", strconv.Itoa(reset))
+
+		if !window.Allowed {
+			c.Header("Retry-After", strconv.Itoa(retryAfter(limit, window)))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// AllowUser counts a request of userID made outside of HTTP, e.g. a
+// WebSocket command, against limit. It shares the counter RateLimitMiddleware
+// keeps for the user, so switching transport does not reset the limit. A
+// refused request gets the seconds to wait; if the limiter is unavailable
+// the request is let through.
+func AllowUser(ctx context.Context, limiter domain.RateLimiter, limit RateLimit, userID string) (bool, int) {
+	window, err := limiter.Hit(ctx, limit.Name+":user:"+userID, limit.Requests, limit.Window)
+	if err != nil {
+		log.Println("Rate limiter unavailable:", err)
+		return true, 0
+	}
+	if !window.Allowed {
+		return false, retryAfter(limit, window)
+	}
+	return true, 0
+}
+
+// retryAfter estimates how many seconds until the sliding window has room
+// for another request: either enough of the previous window has slid out,
+// or the current window ends
+func retryAfter(limit RateLimit, window domain.RateLimitWindow) int {
+	wait := limit.Window - window.Elapsed
+	if window.Previous > 0 && window.Current < limit.Requests {
+		// The estimate drops below the limit once the previous window's
+		// weight falls under (limit - current) / previous
+		weight := float64(limit.Requests-window.Current) / float64(window.Previous)
+		at := time.Duration((1 - weight) * float64(limit.Window))
+		if at > window.Elapsed {
+			wait = at - window.Elapsed
+		}
+	}
+	return seconds(wait)
+}
+
+// seconds rounds d up to whole seconds, at least one
+func seconds(d time.Duration) int {
+	return max(int(math.Ceil(d.Seconds())), 1)
+}
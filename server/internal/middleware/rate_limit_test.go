@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flashtix/server/internal/repository/memory"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limit, err := ParseRateLimit("reserve", "2/1m")
+	if err != nil {
+		t.Fatalf("ParseRateLimit failed: %v", err)
+	}
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if userID := c.GetHeader("X-User"); userID != "" {
+			c.Set("user_id", userID)
+		}
+	})
+	limiter := memory.NewRateLimitRepository()
+	r.Use(RateLimitMiddleware(limiter, limit))
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	request := func(userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-User", userID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i, remaining := range []string{"1", "0"} {
+		w := request("user123")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected 200, got %d", i+1, w.Code)
+		}
+		if got := w.Header().Get("RateLimit-Remaining"); got != remaining {
+			t.Errorf("Request %d: expected %s remaining, got %s", i+1, remaining, got)
+		}
+	}
+
+	w := request("user123")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d", w.Code)
+	}
+	retry := w.Header().Get("Retry-After")
+	if retry == "" || retry == "0" {
+		t.Errorf("Expected a Retry-After header, got %q", retry)
+	}
+
+	// Other users and anonymous clients have their own counters
+	if w := request("user456"); w.Code != http.StatusOK {
+		t.Errorf("Expected another user to pass, got %d", w.Code)
+	}
+	if w := request(""); w.Code != http.StatusOK {
+		t.Errorf("Expected an anonymous client to pass, got %d", w.Code)
+	}
+
+	// Requests outside of HTTP share the user's counter
+	if allowed, retry := AllowUser(context.Background(), limiter, limit, "user123"); allowed || retry < 1 {
+		t.Errorf("Expected AllowUser to refuse user123 with a retry delay, got %v and %d", allowed, retry)
+	}
+	if allowed, _ := AllowUser(context.Background(), limiter, limit, "user789"); !allowed {
+		t.Errorf("Expected AllowUser to let a fresh user through")
+	}
+
+	if _, err := ParseRateLimit("api", "10/100ms"); err == nil {
+		t.Errorf("Expected windows under %s to be rejected", time.Second)
+	}
+}
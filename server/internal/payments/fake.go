@@ -0,0 +1,156 @@
+package payments
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/flashtix/server/internal/domain"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// FakeProvider is an in-memory PaymentProvider for local development and
+// tests. Payments are authorized as soon as they are created, as if the
+// buyer paid with a test card; Decline simulates a refused card.
+type FakeProvider struct {
+	mu       sync.Mutex
+	payments map[string]*domain.Payment
+	byHold   map[string]string
+	refunded map[string]decimal.Decimal
+	refunds  map[string]string
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{
+		payments: make(map[string]*domain.Payment),
+		byHold:   make(map[string]string),
+		refunded: make(map[string]decimal.Decimal),
+		refunds:  make(map[string]string),
+	}
+}
+
+func (p *FakeProvider) CreatePayment(ctx context.Context, holdID string, amount decimal.Decimal, currency string) (*domain.Payment, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if id, ok := p.byHold[holdID]; ok {
+		payment := *p.payments[id]
+		return &payment, nil
+	}
+
+	id := "fake_" + uuid.New().String()
+	payment := &domain.Payment{
+		ID:           id,
+		HoldID:       holdID,
+		Amount:       amount,
+		Currency:     currency,
+		Status:       domain.PaymentAuthorized,
+		ClientSecret: id + "_secret",
+	}
+	p.payments[id] = payment
+	p.byHold[holdID] = id
+
+	result := *payment
+	return &result, nil
+}
+
+func (p *FakeProvider) GetPayment(ctx context.Context, id string) (*domain.Payment, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[id]
+	if !ok {
+		return nil, domain.ErrPaymentNotFound
+	}
+	result := *payment
+	return &result, nil
+}
+
+func (p *FakeProvider) CapturePayment(ctx context.Context, id string, amount decimal.Decimal) (*domain.Payment, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[id]
+	if !ok {
+		return nil, domain.ErrPaymentNotFound
+	}
+	if payment.Status != domain.PaymentAuthorized {
+		return nil, fmt.Errorf("cannot capture a %s payment", payment.Status)
+	}
+	if amount.GreaterThan(payment.Amount) {
+		return nil, fmt.Errorf("cannot capture %s of an authorization of %s", amount, payment.Amount)
+	}
+	payment.Amount = amount
+	payment.Status = domain.PaymentCaptured
+
+	result := *payment
+	return &result, nil
+}
+
+func (p *FakeProvider) VoidPayment(ctx context.Context, id string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[id]
+	if !ok {
+		return domain.ErrPaymentNotFound
+	}
+	switch payment.Status {
+	case domain.PaymentCaptured:
+		return fmt.Errorf("cannot void a captured payment")
+	case domain.PaymentVoided:
+		return nil
+	}
+	payment.Status = domain.PaymentVoided
+	return nil
+}
+
+func (p *FakeProvider) RefundPayment(ctx context.Context, id string, amount decimal.Decimal, refundID string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if ref, ok := p.refunds[refundID]; ok {
+		return ref, nil
+	}
+	payment, ok := p.payments[id]
+	if !ok {
+		return "", domain.ErrPaymentNotFound
+	}
+	if payment.Status != domain.PaymentCaptured {
+		return "", fmt.Errorf("cannot refund a %s payment", payment.Status)
+	}
+	refunded := p.refunded[id].Add(amount)
+	if refunded.GreaterThan(payment.Amount) {
+		return "", fmt.Errorf("cannot refund %s of a payment of %s", refunded, payment.Amount)
+	}
+	p.refunded[id] = refunded
+
+	ref := "fake_re_" + uuid.New().String()
+	p.refunds[refundID] = ref
+	return ref, nil
+}
+
+// Refunded returns how much of a payment has been refunded
+func (p *FakeProvider) Refunded(id string) decimal.Decimal {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.refunded[id]
+}
+
+// Decline marks an uncaptured payment as refused by the card issuer
+func (p *FakeProvider) Decline(id string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[id]
+	if !ok {
+		return domain.ErrPaymentNotFound
+	}
+	if payment.Status == domain.PaymentCaptured || payment.Status == domain.PaymentVoided {
+		return fmt.Errorf("cannot decline a %s payment", payment.Status)
+	}
+	payment.Status = domain.PaymentFailed
+	return nil
+}
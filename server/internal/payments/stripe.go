@@ -0,0 +1,244 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/flashtix/server/internal/domain"
+	"github.com/shopspring/decimal"
+)
+
+const stripeAPIURL = "https://api.stripe.com/v1"
+
+// stripeZeroDecimal lists the currencies Stripe takes in whole units. Every
+// other currency, rupiah included, is sent in hundredths.
+var stripeZeroDecimal = map[string]bool{
+	"bif": true, "clp": true, "djf": true, "gnf": true, "jpy": true, "kmf": true,
+	"krw": true, "mga": true, "pyg": true, "rwf": true, "ugx": true, "vnd": true,
+	"vuv": true, "xaf": true, "xof": true, "xpf": true,
+}
+
+// stripeAmount converts an amount to the integer Stripe expects for the
+// currency
+func stripeAmount(amount decimal.Decimal, currency string) (string, error) {
+	if !stripeZeroDecimal[strings.ToLower(currency)] {
+		amount = amount.Shift(2)
+	}
+	if !amount.IsInteger() {
+		return "", fmt.Errorf("amount %s is finer than the smallest %s unit", amount, strings.ToUpper(currency))
+	}
+	return amount.String(), nil
+}
+
+// StripeProvider takes payments with Stripe PaymentIntents. Intents are
+// created with manual capture: the buyer authorizes the payment with the
+// client secret in the browser and the server captures it once the seats
+// are sold, or cancels it.
+type StripeProvider struct {
+	secretKey string
+	baseURL   string
+	client    *http.Client
+}
+
+func NewStripeProvider(secretKey string) *StripeProvider {
+	return &StripeProvider{
+		secretKey: secretKey,
+		baseURL:   stripeAPIURL,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// stripeIntent is the part of a PaymentIntent the provider reads
+type stripeIntent struct {
+	ID               string            `json:"id"`
+	Amount           int64             `json:"amount"`
+	Currency         string            `json:"currency"`
+	Status           string            `json:"status"`
+	ClientSecret     string            `json:"client_secret"`
+	Metadata         map[string]string `json:"metadata"`
+	LastPaymentError *struct {
+		Message string `json:"message"`
+	} `json:"last_payment_error"`
+}
+
+type stripeErrorResponse struct {
+	Error struct {
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// CreatePayment creates a PaymentIntent for the hold. The hold ID is the
+// idempotency key, so retries return the same intent.
+func (p *StripeProvider) CreatePayment(ctx context.Context, holdID string, amount decimal.Decimal, currency string) (*domain.Payment, error) {
+	value, err := stripeAmount(amount, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("amount", value)
+	form.Set("currency", strings.ToLower(currency))
+	form.Set("capture_method", "manual")
+	form.Set("automatic_payment_methods[enabled]", "true")
+	form.Set("metadata[hold_id]", holdID)
+
+	var intent stripeIntent
+	if err := p.request(ctx, http.MethodPost, "/payment_intents", form, "hold-"+holdID, &intent); err != nil {
+		return nil, err
+	}
+	return intent.payment(), nil
+}
+
+func (p *StripeProvider) GetPayment(ctx context.Context, id string) (*domain.Payment, error) {
+	var intent stripeIntent
+	if err := p.request(ctx, http.MethodGet, "/payment_intents/"+url.PathEscape(id), nil, "", &intent); err != nil {
+		return nil, err
+	}
+	return intent.payment(), nil
+}
+
+// CapturePayment looks the intent up first, since the amount to capture
+// is sent in units of its currency
+func (p *StripeProvider) CapturePayment(ctx context.Context, id string, amount decimal.Decimal) (*domain.Payment, error) {
+	payment, err := p.GetPayment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	value, err := stripeAmount(amount, payment.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("amount_to_capture", value)
+
+	var intent stripeIntent
+	if err := p.request(ctx, http.MethodPost, "/payment_intents/"+url.PathEscape(id)+"/capture", form, "capture-"+id, &intent); err != nil {
+		return nil, err
+	}
+	return intent.payment(), nil
+}
+
+func (p *StripeProvider) VoidPayment(ctx context.Context, id string) error {
+	payment, err := p.GetPayment(ctx, id)
+	if err != nil {
+		return err
+	}
+	if payment.Status == domain.PaymentVoided {
+		return nil
+	}
+
+	var intent stripeIntent
+	return p.request(ctx, http.MethodPost, "/payment_intents/"+url.PathEscape(id)+"/cancel", url.Values{}, "cancel-"+id, &intent)
+}
+
+// stripeRefund is the part of a Refund the provider reads
+type stripeRefund struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// RefundPayment refunds part or all of a captured intent. Stripe settles
+// most refunds asynchronously, so a pending refund counts as made; only a
+// refund Stripe already reports as failed is an error.
+func (p *StripeProvider) RefundPayment(ctx context.Context, id string, amount decimal.Decimal, refundID string) (string, error) {
+	payment, err := p.GetPayment(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	value, err := stripeAmount(amount, payment.Currency)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("payment_intent", id)
+	form.Set("amount", value)
+	form.Set("metadata[refund_id]", refundID)
+
+	var refund stripeRefund
+	if err := p.request(ctx, http.MethodPost, "/refunds", form, "refund-"+refundID, &refund); err != nil {
+		return "", err
+	}
+	if refund.Status == "failed" || refund.Status == "canceled" {
+		return "", fmt.Errorf("%w: refund %s is %s", domain.ErrPaymentFailed, refund.ID, refund.Status)
+	}
+	return refund.ID, nil
+}
+
+// request calls the Stripe API with a form-encoded body and decodes the
+// JSON reply into out
+func (p *StripeProvider) request(ctx context.Context, method, path string, form url.Values, idempotencyKey string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.SetBasicAuth(p.secretKey, "")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var stripeErr stripeErrorResponse
+		json.NewDecoder(resp.Body).Decode(&stripeErr)
+		if resp.StatusCode == http.StatusNotFound {
+			return domain.ErrPaymentNotFound
+		}
+		if stripeErr.Error.Type == "card_error" {
+			return fmt.Errorf("%w: %s", domain.ErrPaymentFailed, stripeErr.Error.Message)
+		}
+		return fmt.Errorf("stripe request failed with status %d: %s", resp.StatusCode, stripeErr.Error.Message)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// payment maps the intent to a domain payment. Stripe sends a declined
+// intent back to requires_payment_method with the decline recorded as its
+// last error, so the buyer may try another card.
+func (i *stripeIntent) payment() *domain.Payment {
+	payment := &domain.Payment{
+		ID:           i.ID,
+		HoldID:       i.Metadata["hold_id"],
+		Amount:       decimal.NewFromInt(i.Amount),
+		Currency:     strings.ToUpper(i.Currency),
+		ClientSecret: i.ClientSecret,
+		Status:       domain.PaymentPending,
+	}
+	if !stripeZeroDecimal[i.Currency] {
+		payment.Amount = payment.Amount.Shift(-2)
+	}
+
+	switch i.Status {
+	case "requires_payment_method":
+		if i.LastPaymentError != nil {
+			payment.Status = domain.PaymentFailed
+		}
+	case "requires_capture":
+		payment.Status = domain.PaymentAuthorized
+	case "succeeded":
+		payment.Status = domain.PaymentCaptured
+	case "canceled":
+		payment.Status = domain.PaymentVoided
+	}
+	return payment
+}
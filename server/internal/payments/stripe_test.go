@@ -0,0 +1,123 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flashtix/server/internal/domain"
+	"github.com/shopspring/decimal"
+)
+
+func TestStripeProvider(t *testing.T) {
+	ctx := context.Background()
+	intent := map[string]interface{}{
+		"id":            "pi_123",
+		"amount":        5000,
+		"currency":      "usd",
+		"status":        "requires_payment_method",
+		"client_secret": "pi_123_secret",
+		"metadata":      map[string]string{"hold_id": "hold1"},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key, _, _ := r.BasicAuth(); key != "sk_test" {
+			t.Errorf("Expected the secret key as basic auth user, got %q", key)
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "POST /payment_intents":
+			r.ParseForm()
+			if r.Form.Get("capture_method") != "manual" || r.Form.Get("amount") != "5000" {
+				t.Errorf("Unexpected intent parameters %v", r.Form)
+			}
+			if r.Header.Get("Idempotency-Key") != "hold-hold1" {
+				t.Errorf("Expected the hold as idempotency key, got %q", r.Header.Get("Idempotency-Key"))
+			}
+		case "GET /payment_intents/pi_123":
+		case "POST /payment_intents/pi_123/capture":
+			r.ParseForm()
+			if r.Form.Get("amount_to_capture") != "5000" {
+				t.Errorf("Expected 5000 cents to be captured, got %q", r.Form.Get("amount_to_capture"))
+			}
+			intent["status"] = "succeeded"
+		case "POST /refunds":
+			r.ParseForm()
+			if r.Form.Get("payment_intent") != "pi_123" || r.Form.Get("amount") != "1250" {
+				t.Errorf("Unexpected refund parameters %v", r.Form)
+			}
+			if r.Header.Get("Idempotency-Key") != "refund-refund1" {
+				t.Errorf("Expected the refund as idempotency key, got %q", r.Header.Get("Idempotency-Key"))
+			}
+			json.NewEncoder(w).Encode(map[string]string{"id": "re_123", "status": "pending"})
+			return
+		case "GET /payment_intents/pi_404":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"type":"invalid_request_error","message":"No such payment_intent"}}`))
+			return
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(intent)
+	}))
+	defer server.Close()
+
+	provider := NewStripeProvider("sk_test")
+	provider.baseURL = server.URL
+
+	payment, err := provider.CreatePayment(ctx, "hold1", decimal.RequireFromString("50.00"), "USD")
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if payment.Status != domain.PaymentPending || payment.HoldID != "hold1" || payment.ClientSecret == "" || !payment.Amount.Equal(decimal.NewFromInt(50)) {
+		t.Errorf("Expected a pending payment for hold1, got %+v", payment)
+	}
+
+	intent["last_payment_error"] = map[string]string{"message": "Your card was declined."}
+	if payment, _ := provider.GetPayment(ctx, "pi_123"); payment.Status != domain.PaymentFailed {
+		t.Errorf("Expected a declined intent to be failed, got %s", payment.Status)
+	}
+
+	delete(intent, "last_payment_error")
+	intent["status"] = "requires_capture"
+	if payment, _ := provider.GetPayment(ctx, "pi_123"); payment.Status != domain.PaymentAuthorized {
+		t.Errorf("Expected an uncaptured intent to be authorized, got %s", payment.Status)
+	}
+
+	payment, err = provider.CapturePayment(ctx, "pi_123", decimal.NewFromInt(50))
+	if err != nil || payment.Status != domain.PaymentCaptured {
+		t.Errorf("Expected the payment to be captured, got %+v, %v", payment, err)
+	}
+
+	ref, err := provider.RefundPayment(ctx, "pi_123", decimal.RequireFromString("12.50"), "refund1")
+	if err != nil || ref != "re_123" {
+		t.Errorf("Expected refund re_123, got %q, %v", ref, err)
+	}
+
+	if _, err := provider.GetPayment(ctx, "pi_404"); !errors.Is(err, domain.ErrPaymentNotFound) {
+		t.Errorf("Expected ErrPaymentNotFound, got %v", err)
+	}
+}
+
+func TestStripeAmount(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		expected string
+	}{
+		{"49.99", "USD", "4999"},
+		{"150000", "IDR", "15000000"},
+		{"1500", "JPY", "1500"},
+	}
+	for _, tt := range tests {
+		value, err := stripeAmount(decimal.RequireFromString(tt.amount), tt.currency)
+		if err != nil || value != tt.expected {
+			t.Errorf("Expected %s %s to be sent as %s, got %s, %v", tt.amount, tt.currency, tt.expected, value, err)
+		}
+	}
+	if _, err := stripeAmount(decimal.RequireFromString("10.5"), "JPY"); err == nil {
+		t.Error("Expected an error for a fractional yen amount")
+	}
+}
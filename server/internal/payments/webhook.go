@@ -0,0 +1,124 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flashtix/server/internal/domain"
+)
+
+// SignatureTolerance is how old a signed webhook may be before it is
+// refused as a possible replay
+const SignatureTolerance = 5 * time.Minute
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// VerifySignature checks a Stripe-Signature header of the form
+// "t=<unix time>,v1=<hex HMAC-SHA256>[,v1=...]" against payload. The HMAC is
+// taken over "<t>.<payload>" with the endpoint secret, and t must be within
+// tolerance of now. Several v1 signatures are sent while a secret is rolled.
+func VerifySignature(payload []byte, header, secret string, tolerance time.Duration, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: timestamp outside the tolerance window", ErrInvalidSignature)
+	}
+
+	expected := signatureMAC(payload, timestamp, secret)
+	for _, signature := range signatures {
+		mac, err := hex.DecodeString(signature)
+		if err == nil && hmac.Equal(mac, expected) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// SignPayload builds a Stripe-Signature header for payload, as Stripe does
+// when it delivers a webhook
+func SignPayload(payload []byte, secret string, at time.Time) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(signatureMAC(payload, timestamp, secret))
+}
+
+func signatureMAC(payload []byte, timestamp, secret string) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp))
+	h.Write([]byte("."))
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+// stripeEvent is the envelope of a Stripe webhook delivery
+type stripeEvent struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Created int64  `json:"created"`
+	Data    struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// stripeEventTypes maps the PaymentIntent events the server acts on. With
+// manual capture an intent becomes capturable once the buyer paid.
+var stripeEventTypes = map[string]string{
+	"payment_intent.amount_capturable_updated": domain.PaymentEventAuthorized,
+	"payment_intent.succeeded":                 domain.PaymentEventCaptured,
+	"payment_intent.payment_failed":            domain.PaymentEventFailed,
+	"payment_intent.canceled":                  domain.PaymentEventVoided,
+}
+
+// ParseWebhookEvent decodes a verified Stripe webhook payload
+func ParseWebhookEvent(payload []byte) (*domain.PaymentEvent, error) {
+	var envelope stripeEvent
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %w", err)
+	}
+	if envelope.ID == "" {
+		return nil, errors.New("invalid webhook payload: missing event id")
+	}
+
+	event := &domain.PaymentEvent{
+		ID:           envelope.ID,
+		Type:         stripeEventTypes[envelope.Type],
+		ProviderType: envelope.Type,
+		Payload:      payload,
+		CreatedAt:    time.Unix(envelope.Created, 0).UTC(),
+	}
+	if event.Type != "" {
+		var intent stripeIntent
+		if err := json.Unmarshal(envelope.Data.Object, &intent); err != nil {
+			return nil, fmt.Errorf("invalid payment intent in webhook: %w", err)
+		}
+		event.PaymentID = intent.ID
+		event.HoldID = intent.Metadata["hold_id"]
+	}
+	return event, nil
+}
@@ -0,0 +1,69 @@
+package payments
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/flashtix/server/internal/domain"
+)
+
+func TestVerifySignature(t *testing.T) {
+	payload, err := os.ReadFile("testdata/webhooks/01_amount_capturable_updated.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1792224000, 0)
+	header := SignPayload(payload, "whsec_test", now)
+
+	if err := VerifySignature(payload, header, "whsec_test", SignatureTolerance, now.Add(time.Minute)); err != nil {
+		t.Errorf("Expected a valid signature, got %v", err)
+	}
+	// A rolled secret sends one signature per secret
+	if err := VerifySignature(payload, "v1=00ff,"+header, "whsec_test", SignatureTolerance, now); err != nil {
+		t.Errorf("Expected any matching v1 signature to pass, got %v", err)
+	}
+
+	cases := map[string]struct {
+		payload []byte
+		header  string
+		secret  string
+		at      time.Time
+	}{
+		"wrong secret":     {payload, header, "whsec_other", now},
+		"tampered payload": {append([]byte(" "), payload...), header, "whsec_test", now},
+		"too old":          {payload, header, "whsec_test", now.Add(SignatureTolerance + time.Second)},
+		"missing header":   {payload, "", "whsec_test", now},
+	}
+	for name, tc := range cases {
+		if err := VerifySignature(tc.payload, tc.header, tc.secret, SignatureTolerance, tc.at); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: expected ErrInvalidSignature, got %v", name, err)
+		}
+	}
+}
+
+func TestParseWebhookEvent(t *testing.T) {
+	expected := map[string]string{
+		"testdata/webhooks/01_amount_capturable_updated.json": domain.PaymentEventAuthorized,
+		"testdata/webhooks/02_payment_failed.json":            domain.PaymentEventFailed,
+	}
+	for file, eventType := range expected {
+		payload, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		event, err := ParseWebhookEvent(payload)
+		if err != nil {
+			t.Fatalf("%s: ParseWebhookEvent failed: %v", file, err)
+		}
+		if event.Type != eventType || event.PaymentID != "pi_3QfT2bExample" || event.HoldID == "" {
+			t.Errorf("%s: unexpected event %+v", file, event)
+		}
+	}
+
+	event, err := ParseWebhookEvent([]byte(`{"id":"evt_1","type":"customer.created","data":{"object":{}}}`))
+	if err != nil || event.Type != "" {
+		t.Errorf("Expected unhandled events to parse without a type, got %+v, %v", event, err)
+	}
+}
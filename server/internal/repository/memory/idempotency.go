@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/flashtix/server/internal/domain"
+)
+
+type idempotencyEntry struct {
+	record    domain.IdempotencyRecord
+	expiresAt time.Time
+}
+
+// IdempotencyRepository implements domain.IdempotencyStore in process
+// memory. Records are not shared between replicas, so it is only suitable
+// for tests and single-node development.
+type IdempotencyRepository struct {
+	mu      sync.Mutex
+	entries map[string]idempotencyEntry
+	now     func() time.Time
+}
+
+func NewIdempotencyRepository() *IdempotencyRepository {
+	return &IdempotencyRepository{
+		entries: make(map[string]idempotencyEntry),
+		now:     time.Now,
+	}
+}
+
+// Begin claims key for the request unless it is already claimed
+func (r *IdempotencyRepository) Begin(ctx context.Context, key, fingerprint string, ttl time.Duration) (*domain.IdempotencyRecord, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	if entry, ok := r.entries[key]; ok && now.Before(entry.expiresAt) {
+		record := entry.record
+		return &record, false, nil
+	}
+	r.prune(now)
+	r.entries[key] = idempotencyEntry{
+		record:    domain.IdempotencyRecord{Fingerprint: fingerprint},
+		expiresAt: now.Add(ttl),
+	}
+	return nil, true, nil
+}
+
+// Complete stores the response of a finished request
+func (r *IdempotencyRepository) Complete(ctx context.Context, key string, record *domain.IdempotencyRecord, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries[key] = idempotencyEntry{record: *record, expiresAt: r.now().Add(ttl)}
+	return nil
+}
+
+// Abandon releases a claim so the request can be retried
+func (r *IdempotencyRepository) Abandon(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.entries, key)
+	return nil
+}
+
+// prune drops expired records. The caller must hold r.mu.
+func (r *IdempotencyRepository) prune(now time.Time) {
+	for key, entry := range r.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(r.entries, key)
+		}
+	}
+}
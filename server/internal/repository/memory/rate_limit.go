@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/flashtix/server/internal/domain"
+)
+
+// maxRateWindows is the number of tracked keys above which stale counters
+// are pruned
+const maxRateWindows = 10000
+
+type rateWindow struct {
+	index    int64
+	current  int
+	previous int
+}
+
+// RateLimitRepository implements domain.RateLimiter in process memory.
+// Counters are not shared between replicas, so it is only suitable for
+// tests and single-node development.
+type RateLimitRepository struct {
+	mu      sync.Mutex
+	windows map[string]*rateWindow
+	now     func() time.Time
+}
+
+func NewRateLimitRepository() *RateLimitRepository {
+	return &RateLimitRepository{
+		windows: make(map[string]*rateWindow),
+		now:     time.Now,
+	}
+}
+
+// Hit counts a request for key unless that would exceed limit within window
+func (r *RateLimitRepository) Hit(ctx context.Context, key string, limit int, window time.Duration) (domain.RateLimitWindow, error) {
+	now := r.now()
+	index := now.UnixMilli() / window.Milliseconds()
+	elapsed := time.Duration(now.UnixMilli()%window.Milliseconds()) * time.Millisecond
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.windows) >= maxRateWindows {
+		r.prune(index)
+	}
+
+	w, ok := r.windows[key]
+	switch {
+	case !ok:
+		w = &rateWindow{index: index}
+		r.windows[key] = w
+	case w.index == index-1:
+		w.index, w.previous, w.current = index, w.current, 0
+	case w.index < index-1:
+		w.index, w.previous, w.current = index, 0, 0
+	}
+
+	overlap := float64(window-elapsed) / float64(window)
+	state := domain.RateLimitWindow{Current: w.current, Previous: w.previous, Elapsed: elapsed}
+	if float64(w.previous)*overlap+float64(w.current) >= float64(limit) {
+		return state, nil
+	}
+	w.current++
+	state.Allowed, state.Current = true, w.current
+	return state, nil
+}
+
+// prune drops counters that no longer affect any limit. The caller must
+// hold r.mu.
+func (r *RateLimitRepository) prune(index int64) {
+	for key, w := range r.windows {
+		if w.index < index-1 {
+			delete(r.windows, key)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flashtix/server/internal/domain"
+)
+
+type seatLock struct {
+	userID    string
+	token     int64
+	expiresAt time.Time
+	held      bool // taken by LockSeats, so it counts toward the hold limit
+}
+
+// tierStock counts the unclaimed tickets of a general-admission tier
+type tierStock struct {
+	eventID   string
+	available int
+	claims    map[string]tierClaim
+}
+
+type tierClaim struct {
+	userID    string
+	quantity  int
+	expiresAt time.Time
+}
+
+// SeatLockRepository implements domain.SeatLocker in process memory. Locks
+// are not shared between replicas, so it is only suitable for tests and
+// single-node development.
+type SeatLockRepository struct {
+	mu     sync.Mutex
+	locks  map[string]seatLock
+	fences map[string]int64
+	stocks map[string]*tierStock
+	now    func() time.Time
+}
+
+func NewSeatLockRepository() *SeatLockRepository {
+	return &SeatLockRepository{
+		locks:  make(map[string]seatLock),
+		fences: make(map[string]int64),
+		stocks: make(map[string]*tierStock),
+		now:    time.Now,
+	}
+}
+
+// LockSeat locks a seat for a user unless someone else holds it and returns
+// the lock's fencing token. The holder gets its existing token back with the
+// expiration refreshed.
+func (r *SeatLockRepository) LockSeat(ctx context.Context, eventID, seat string, userID string, expiration time.Duration) (int64, bool, error) {
+	key := fmt.Sprintf("seat_lock:%s:%s", eventID, seat)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if lock, ok := r.lookup(key); ok && lock.userID != userID {
+		return 0, false, nil
+	}
+	return r.acquire(key, userID, expiration), true, nil
+}
+
+// LockSeats locks all seats for a user or none of them. When any seat is
+// held by someone else it returns those seats and leaves every lock as is.
+func (r *SeatLockRepository) LockSeats(ctx context.Context, eventID string, seats []string, userID string, expiration time.Duration, maxHeld int) ([]int64, []string, error) {
+	keys := make([]string, len(seats))
+	for i, seat := range seats {
+		keys[i] = fmt.Sprintf("seat_lock:%s:%s", eventID, seat)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var conflicts []string
+	for i, key := range keys {
+		if lock, ok := r.lookup(key); ok && lock.userID != userID {
+			conflicts = append(conflicts, seats[i])
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, conflicts, nil
+	}
+	if maxHeld > 0 && r.heldAfter(eventID, userID, keys) > maxHeld {
+		return nil, nil, domain.ErrHoldLimit
+	}
+
+	tokens := make([]int64, len(keys))
+	for i, key := range keys {
+		tokens[i] = r.acquire(key, userID, expiration)
+		lock := r.locks[key]
+		lock.held = true
+		r.locks[key] = lock
+	}
+	return tokens, nil, nil
+}
+
+// heldAfter counts the seats of the event the user would hold after also
+// holding keys. The caller must hold r.mu.
+func (r *SeatLockRepository) heldAfter(eventID, userID string, keys []string) int {
+	requested := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		requested[key] = true
+	}
+
+	held := len(keys) + r.claimed(eventID, userID)
+	prefix := fmt.Sprintf("seat_lock:%s:", eventID)
+	for key := range r.locks {
+		if !strings.HasPrefix(key, prefix) || requested[key] {
+			continue
+		}
+		if lock, ok := r.lookup(key); ok && lock.held && lock.userID == userID {
+			held++
+		}
+	}
+	return held
+}
+
+// UnlockSeat unlocks a seat if it is held by userID
+func (r *SeatLockRepository) UnlockSeat(ctx context.Context, eventID, seat string, userID string) (bool, error) {
+	key := fmt.Sprintf("seat_lock:%s:%s", eventID, seat)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lock, ok := r.lookup(key)
+	if !ok || lock.userID != userID {
+		return false, nil
+	}
+	delete(r.locks, key)
+	return true, nil
+}
+
+// IsSeatLocked checks if a seat is locked and by whom
+func (r *SeatLockRepository) IsSeatLocked(ctx context.Context, eventID, seat string) (string, error) {
+	key := fmt.Sprintf("seat_lock:%s:%s", eventID, seat)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lock, _ := r.lookup(key)
+	return lock.userID, nil
+}
+
+// ExtendLock extends the lock expiration if it is held by userID
+func (r *SeatLockRepository) ExtendLock(ctx context.Context, eventID, seat string, userID string, expiration time.Duration) (bool, error) {
+	key := fmt.Sprintf("seat_lock:%s:%s", eventID, seat)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lock, ok := r.lookup(key)
+	if !ok || lock.userID != userID {
+		return false, nil
+	}
+	lock.expiresAt = r.expiry(expiration)
+	r.locks[key] = lock
+	return true, nil
+}
+
+// LockHolders returns for each seat the user holding its lock, or ""
+func (r *SeatLockRepository) LockHolders(ctx context.Context, eventID string, seats []string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	holders := make([]string, len(seats))
+	for i, seat := range seats {
+		lock, _ := r.lookup(fmt.Sprintf("seat_lock:%s:%s", eventID, seat))
+		holders[i] = lock.userID
+	}
+	return holders, nil
+}
+
+// SeedTierStock starts counting the tier's stock unless it is counted already
+func (r *SeatLockRepository) SeedTierStock(ctx context.Context, eventID, tierID string, available int) error {
+	key := fmt.Sprintf("tier_stock:%s:%s", eventID, tierID)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.stocks[key]; !ok {
+		r.stocks[key] = &tierStock{eventID: eventID, available: available, claims: make(map[string]tierClaim)}
+	}
+	return nil
+}
+
+// TierStock returns the tickets of the tier left to claim
+func (r *SeatLockRepository) TierStock(ctx context.Context, eventID, tierID string) (int, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stock, ok := r.stocks[fmt.Sprintf("tier_stock:%s:%s", eventID, tierID)]
+	if !ok {
+		return 0, false, nil
+	}
+	r.reclaim(stock)
+	return stock.available, true, nil
+}
+
+// ClaimTierStock takes quantity tickets off the tier's stock for the claim
+func (r *SeatLockRepository) ClaimTierStock(ctx context.Context, eventID, tierID, claimID, userID string, quantity int, expiration time.Duration, maxHeld int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stock, ok := r.stocks[fmt.Sprintf("tier_stock:%s:%s", eventID, tierID)]
+	if !ok {
+		return domain.ErrTierStockUnknown
+	}
+	r.reclaim(stock)
+	if maxHeld > 0 && r.heldAfter(eventID, userID, nil)+quantity > maxHeld {
+		return domain.ErrHoldLimit
+	}
+	if stock.available < quantity {
+		return domain.ErrSoldOut
+	}
+	stock.available -= quantity
+	stock.claims[claimID] = tierClaim{userID: userID, quantity: quantity, expiresAt: r.expiry(expiration)}
+	return nil
+}
+
+// ExtendTierClaim moves the claim's expiry if it has not lapsed
+func (r *SeatLockRepository) ExtendTierClaim(ctx context.Context, eventID, tierID, claimID, userID string, expiration time.Duration) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stock, ok := r.stocks[fmt.Sprintf("tier_stock:%s:%s", eventID, tierID)]
+	if !ok {
+		return false, nil
+	}
+	r.reclaim(stock)
+	claim, ok := stock.claims[claimID]
+	if !ok || claim.userID != userID {
+		return false, nil
+	}
+	claim.expiresAt = r.expiry(expiration)
+	stock.claims[claimID] = claim
+	return true, nil
+}
+
+// SettleTierClaim ends the claim and returns its unsold tickets to the stock
+func (r *SeatLockRepository) SettleTierClaim(ctx context.Context, eventID, tierID, claimID, userID string, sold int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stock, ok := r.stocks[fmt.Sprintf("tier_stock:%s:%s", eventID, tierID)]
+	if !ok {
+		return false, nil
+	}
+	r.reclaim(stock)
+	claim, ok := stock.claims[claimID]
+	if !ok || claim.userID != userID {
+		return false, nil
+	}
+	delete(stock.claims, claimID)
+	stock.available += max(claim.quantity-sold, 0)
+	return true, nil
+}
+
+// ReturnTierStock puts tickets back on a counted tier's stock
+func (r *SeatLockRepository) ReturnTierStock(ctx context.Context, eventID, tierID string, quantity int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if stock, ok := r.stocks[fmt.Sprintf("tier_stock:%s:%s", eventID, tierID)]; ok {
+		stock.available += quantity
+	}
+	return nil
+}
+
+// reclaim returns the tickets of lapsed claims to the stock. The caller
+// must hold r.mu.
+func (r *SeatLockRepository) reclaim(stock *tierStock) {
+	now := r.now()
+	for id, claim := range stock.claims {
+		if !claim.expiresAt.IsZero() && !now.Before(claim.expiresAt) {
+			stock.available += claim.quantity
+			delete(stock.claims, id)
+		}
+	}
+}
+
+// claimed counts the tier tickets the user has claimed for the event. The
+// caller must hold r.mu.
+func (r *SeatLockRepository) claimed(eventID, userID string) int {
+	claimed := 0
+	for _, stock := range r.stocks {
+		if stock.eventID != eventID {
+			continue
+		}
+		r.reclaim(stock)
+		for _, claim := range stock.claims {
+			if claim.userID == userID {
+				claimed += claim.quantity
+			}
+		}
+	}
+	return claimed
+}
+
+// acquire takes or refreshes the lock on key for userID and returns its
+// fencing token. The caller must hold r.mu and have checked that the lock
+// is free or already held by userID.
+func (r *SeatLockRepository) acquire(key, userID string, expiration time.Duration) int64 {
+	if lock, ok := r.lookup(key); ok {
+		lock.expiresAt = r.expiry(expiration)
+		r.locks[key] = lock
+		return lock.token
+	}
+
+	r.fences[key]++
+	token := r.fences[key]
+	r.locks[key] = seatLock{userID: userID, token: token, expiresAt: r.expiry(expiration)}
+	return token
+}
+
+// lookup returns the live lock for key, evicting it if it has expired.
+// The caller must hold r.mu.
+func (r *SeatLockRepository) lookup(key string) (seatLock, bool) {
+	lock, ok := r.locks[key]
+	if !ok {
+		return seatLock{}, false
+	}
+	if !lock.expiresAt.IsZero() && !r.now().Before(lock.expiresAt) {
+		delete(r.locks, key)
+		return seatLock{}, false
+	}
+	return lock, true
+}
+
+func (r *SeatLockRepository) expiry(expiration time.Duration) time.Time {
+	if expiration <= 0 {
+		return time.Time{}
+	}
+	return r.now().Add(expiration)
+}
@@ -0,0 +1,149 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flashtix/server/internal/domain"
+)
+
+func TestSeatLockRepository(t *testing.T) {
+	repo := NewSeatLockRepository()
+	now := time.Now()
+	repo.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	eventID := "event1"
+	seat := "A1"
+
+	first, acquired, _ := repo.LockSeat(ctx, eventID, seat, "user123", 30*time.Second)
+	if !acquired {
+		t.Fatalf("Expected lock to be acquired")
+	}
+
+	if token, acquired, _ := repo.LockSeat(ctx, eventID, seat, "user123", 30*time.Second); !acquired || token != first {
+		t.Errorf("Expected holder to keep token %d, got %d", first, token)
+	}
+
+	if _, acquired, _ = repo.LockSeat(ctx, eventID, seat, "user456", 30*time.Second); acquired {
+		t.Errorf("Expected lock held by user123 to be kept")
+	}
+
+	if unlocked, _ := repo.UnlockSeat(ctx, eventID, seat, "user456"); unlocked {
+		t.Errorf("Expected unlock by non-owner to fail")
+	}
+
+	if extended, _ := repo.ExtendLock(ctx, eventID, seat, "user123", 60*time.Second); !extended {
+		t.Errorf("Expected owner to extend lock")
+	}
+
+	now = now.Add(59 * time.Second)
+	if lockedBy, _ := repo.IsSeatLocked(ctx, eventID, seat); lockedBy != "user123" {
+		t.Errorf("Expected user123, got %q", lockedBy)
+	}
+
+	now = now.Add(time.Second)
+	if lockedBy, _ := repo.IsSeatLocked(ctx, eventID, seat); lockedBy != "" {
+		t.Errorf("Expected expired lock, got %q", lockedBy)
+	}
+
+	second, acquired, _ := repo.LockSeat(ctx, eventID, seat, "user456", 30*time.Second)
+	if !acquired {
+		t.Errorf("Expected lock to be acquired after expiry")
+	}
+	if second <= first {
+		t.Errorf("Expected fencing token to increase, got %d after %d", second, first)
+	}
+
+	if unlocked, _ := repo.UnlockSeat(ctx, eventID, seat, "user456"); !unlocked {
+		t.Errorf("Expected owner to unlock seat")
+	}
+}
+
+func TestSeatLockRepositoryLockSeats(t *testing.T) {
+	repo := NewSeatLockRepository()
+	ctx := context.Background()
+
+	repo.LockSeat(ctx, "event1", "A2", "user456", time.Minute)
+
+	tokens, conflicts, _ := repo.LockSeats(ctx, "event1", []string{"A1", "A2", "A3"}, "user123", time.Minute, 0)
+	if tokens != nil || len(conflicts) != 1 || conflicts[0] != "A2" {
+		t.Fatalf("Expected conflict on A2, got tokens %v conflicts %v", tokens, conflicts)
+	}
+	if lockedBy, _ := repo.IsSeatLocked(ctx, "event1", "A1"); lockedBy != "" {
+		t.Errorf("Expected A1 to stay unlocked after a failed batch, got %q", lockedBy)
+	}
+
+	tokens, conflicts, _ = repo.LockSeats(ctx, "event1", []string{"A1", "A3"}, "user123", time.Minute, 0)
+	if len(conflicts) != 0 || len(tokens) != 2 {
+		t.Fatalf("Expected both seats locked, got tokens %v conflicts %v", tokens, conflicts)
+	}
+	for _, seat := range []string{"A1", "A3"} {
+		if lockedBy, _ := repo.IsSeatLocked(ctx, "event1", seat); lockedBy != "user123" {
+			t.Errorf("Expected %s locked by user123, got %q", seat, lockedBy)
+		}
+	}
+
+	// Seats already held count once against the limit
+	if _, _, err := repo.LockSeats(ctx, "event1", []string{"A3", "A4"}, "user123", time.Minute, 2); !errors.Is(err, domain.ErrHoldLimit) {
+		t.Errorf("Expected ErrHoldLimit, got %v", err)
+	}
+	if _, _, err := repo.LockSeats(ctx, "event1", []string{"A3"}, "user123", time.Minute, 2); err != nil {
+		t.Errorf("Expected re-locking a held seat within the limit, got %v", err)
+	}
+}
+
+func TestSeatLockRepositoryTierStock(t *testing.T) {
+	repo := NewSeatLockRepository()
+	now := time.Now()
+	repo.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if err := repo.ClaimTierStock(ctx, "event1", "floor", "hold1", "user123", 2, time.Minute, 0); !errors.Is(err, domain.ErrTierStockUnknown) {
+		t.Fatalf("Expected ErrTierStockUnknown before seeding, got %v", err)
+	}
+	repo.SeedTierStock(ctx, "event1", "floor", 5)
+	repo.SeedTierStock(ctx, "event1", "floor", 100)
+
+	if err := repo.ClaimTierStock(ctx, "event1", "floor", "hold1", "user123", 3, time.Minute, 0); err != nil {
+		t.Fatalf("Expected claim of 3, got %v", err)
+	}
+	if err := repo.ClaimTierStock(ctx, "event1", "floor", "hold2", "user456", 3, time.Minute, 0); !errors.Is(err, domain.ErrSoldOut) {
+		t.Errorf("Expected ErrSoldOut when claiming past the stock, got %v", err)
+	}
+	// Claimed tickets and locked seats share the hold limit
+	repo.LockSeats(ctx, "event1", []string{"A1"}, "user456", time.Minute, 0)
+	if err := repo.ClaimTierStock(ctx, "event1", "floor", "hold2", "user456", 2, time.Minute, 2); !errors.Is(err, domain.ErrHoldLimit) {
+		t.Errorf("Expected ErrHoldLimit, got %v", err)
+	}
+	if err := repo.ClaimTierStock(ctx, "event1", "floor", "hold2", "user456", 2, 2*time.Minute, 0); err != nil {
+		t.Fatalf("Expected claim of the last 2, got %v", err)
+	}
+
+	// Selling 2 of 3 returns one ticket
+	if settled, _ := repo.SettleTierClaim(ctx, "event1", "floor", "hold1", "user123", 2); !settled {
+		t.Errorf("Expected hold1 to be settled")
+	}
+	if available, _, _ := repo.TierStock(ctx, "event1", "floor"); available != 1 {
+		t.Errorf("Expected 1 ticket left, got %d", available)
+	}
+
+	// A lapsed claim gives its tickets back once and cannot be extended
+	now = now.Add(2 * time.Minute)
+	if extended, _ := repo.ExtendTierClaim(ctx, "event1", "floor", "hold2", "user456", time.Minute); extended {
+		t.Errorf("Expected a lapsed claim not to be extended")
+	}
+	if settled, _ := repo.SettleTierClaim(ctx, "event1", "floor", "hold2", "user456", 0); settled {
+		t.Errorf("Expected a lapsed claim to be gone")
+	}
+	repo.ReturnTierStock(ctx, "event1", "floor", 1)
+	repo.ReturnTierStock(ctx, "event1", "untracked", 1)
+	if available, _, _ := repo.TierStock(ctx, "event1", "floor"); available != 4 {
+		t.Errorf("Expected 4 tickets left, got %d", available)
+	}
+	if _, tracked, _ := repo.TierStock(ctx, "event1", "untracked"); tracked {
+		t.Errorf("Expected returning stock not to start counting a tier")
+	}
+}
@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"context"
+	"sync"
+)
+
+type waitingRoom struct {
+	users    map[string]int64
+	last     int64
+	admitted int64
+	tick     int64
+}
+
+// WaitingRoomRepository implements domain.WaitingRoomStore in process
+// memory. Queues are not shared between replicas, so it is only suitable
+// for tests and single-node development.
+type WaitingRoomRepository struct {
+	mu    sync.Mutex
+	rooms map[string]*waitingRoom
+}
+
+func NewWaitingRoomRepository() *WaitingRoomRepository {
+	return &WaitingRoomRepository{rooms: make(map[string]*waitingRoom)}
+}
+
+// Join returns the user's queue number, assigning the next one on first join
+func (r *WaitingRoomRepository) Join(ctx context.Context, eventID, userID string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	room, ok := r.rooms[eventID]
+	if !ok {
+		room = &waitingRoom{users: make(map[string]int64)}
+		r.rooms[eventID] = room
+	}
+	if number, ok := room.users[userID]; ok {
+		return number, nil
+	}
+	room.last++
+	room.users[userID] = room.last
+	return room.last, nil
+}
+
+// Admitted returns the highest queue number allowed to buy
+func (r *WaitingRoomRepository) Admitted(ctx context.Context, eventID string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if room, ok := r.rooms[eventID]; ok {
+		return room.admitted, nil
+	}
+	return 0, nil
+}
+
+// Advance admits up to count more buyers unless tick was already applied
+func (r *WaitingRoomRepository) Advance(ctx context.Context, eventID string, tick, count int64) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	room, ok := r.rooms[eventID]
+	if !ok {
+		return 0, nil
+	}
+	if room.tick != tick {
+		room.tick = tick
+		room.admitted = min(room.admitted+count, room.last)
+	}
+	return room.admitted, nil
+}
+
+// Events lists the events with buyers still waiting
+func (r *WaitingRoomRepository) Events(ctx context.Context) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var events []string
+	for eventID, room := range r.rooms {
+		if room.admitted < room.last {
+			events = append(events, eventID)
+		}
+	}
+	return events, nil
+}
@@ -2,10 +2,19 @@ package postgres
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/flashtix/server/db"
 	"github.com/flashtix/server/internal/domain"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/steebchen/prisma-client-go/runtime/raw"
 )
 
 type eventRepository struct {
@@ -17,33 +26,71 @@ func NewEventRepository(client *db.PrismaClient) domain.EventRepository {
 }
 
 func (r *eventRepository) Create(ctx context.Context, event *domain.Event) error {
-	_, err := r.client.Event.CreateOne(
+	return r.CreateWithInventory(ctx, event, nil)
+}
+
+// CreateWithInventory stores the event and its tickets in one transaction,
+// so a failed insert leaves no event without seats behind
+func (r *eventRepository) CreateWithInventory(ctx context.Context, event *domain.Event, tickets []*domain.Ticket) error {
+	create := r.client.Event.CreateOne(
 		db.Event.Name.Set(event.Name),
 		db.Event.Description.Set(event.Description),
 		db.Event.Date.Set(event.Date),
 		db.Event.Venue.Set(event.Venue),
 		db.Event.Capacity.Set(event.Capacity),
-	).Exec(ctx)
-	return err
+		db.Event.ID.Set(event.ID),
+		db.Event.VenueID.SetOptional(optionalString(event.VenueID)),
+		db.Event.MaxHeldPerUser.Set(event.MaxHeldPerUser),
+		db.Event.MaxTicketsPerUser.Set(event.MaxTicketsPerUser),
+		db.Event.Currency.Set(event.Currency),
+		db.Event.Refundable.Set(event.Refundable),
+		db.Event.RefundCutoffHours.Set(event.RefundCutoffHours),
+		db.Event.RefundFeePerTicket.Set(event.RefundFeePerTicket),
+		db.Event.RestockRefunds.Set(event.RestockRefunds),
+	)
+	if len(tickets) == 0 {
+		_, err := create.Exec(ctx)
+		return err
+	}
+
+	txs, inserts, err := inventoryTxs(r.client, event.ID, tickets, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	err = r.client.Prisma.Transaction(append([]db.PrismaTransaction{create.Tx()}, txs...)...).Exec(ctx)
+	return inventoryResult(err, inserts, len(tickets))
 }
 
+// GetByID returns domain.ErrEventNotFound when the event does not exist
 func (r *eventRepository) GetByID(ctx context.Context, id string) (*domain.Event, error) {
 	event, err := r.client.Event.FindUnique(
 		db.Event.ID.Equals(id),
 	).Exec(ctx)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, domain.ErrEventNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	venueID, _ := event.VenueID()
 	return &domain.Event{
-		ID:          event.ID,
-		Name:        event.Name,
-		Description: event.Description,
-		Date:        event.Date,
-		Venue:       event.Venue,
-		Capacity:    event.Capacity,
-		CreatedAt:   event.CreatedAt,
-		UpdatedAt:   event.UpdatedAt,
+		ID:                 event.ID,
+		Name:               event.Name,
+		Description:        event.Description,
+		Date:               event.Date,
+		Venue:              event.Venue,
+		VenueID:            venueID,
+		Capacity:           event.Capacity,
+		MaxHeldPerUser:     event.MaxHeldPerUser,
+		MaxTicketsPerUser:  event.MaxTicketsPerUser,
+		Currency:           event.Currency,
+		Refundable:         event.Refundable,
+		RefundCutoffHours:  event.RefundCutoffHours,
+		RefundFeePerTicket: event.RefundFeePerTicket,
+		RestockRefunds:     event.RestockRefunds,
+		CreatedAt:          event.CreatedAt,
+		UpdatedAt:          event.UpdatedAt,
 	}, nil
 }
 
@@ -55,15 +102,24 @@ func (r *eventRepository) GetAll(ctx context.Context) ([]*domain.Event, error) {
 
 	var result []*domain.Event
 	for _, event := range events {
+		venueID, _ := event.VenueID()
 		result = append(result, &domain.Event{
-			ID:          event.ID,
-			Name:        event.Name,
-			Description: event.Description,
-			Date:        event.Date,
-			Venue:       event.Venue,
-			Capacity:    event.Capacity,
-			CreatedAt:   event.CreatedAt,
-			UpdatedAt:   event.UpdatedAt,
+			ID:                 event.ID,
+			Name:               event.Name,
+			Description:        event.Description,
+			Date:               event.Date,
+			Venue:              event.Venue,
+			VenueID:            venueID,
+			Capacity:           event.Capacity,
+			MaxHeldPerUser:     event.MaxHeldPerUser,
+			MaxTicketsPerUser:  event.MaxTicketsPerUser,
+			Currency:           event.Currency,
+			Refundable:         event.Refundable,
+			RefundCutoffHours:  event.RefundCutoffHours,
+			RefundFeePerTicket: event.RefundFeePerTicket,
+			RestockRefunds:     event.RestockRefunds,
+			CreatedAt:          event.CreatedAt,
+			UpdatedAt:          event.UpdatedAt,
 		})
 	}
 	return result, nil
@@ -78,6 +134,14 @@ func (r *eventRepository) Update(ctx context.Context, event *domain.Event) error
 		db.Event.Date.Set(event.Date),
 		db.Event.Venue.Set(event.Venue),
 		db.Event.Capacity.Set(event.Capacity),
+		db.Event.VenueID.SetOptional(optionalString(event.VenueID)),
+		db.Event.MaxHeldPerUser.Set(event.MaxHeldPerUser),
+		db.Event.MaxTicketsPerUser.Set(event.MaxTicketsPerUser),
+		db.Event.Currency.Set(event.Currency),
+		db.Event.Refundable.Set(event.Refundable),
+		db.Event.RefundCutoffHours.Set(event.RefundCutoffHours),
+		db.Event.RefundFeePerTicket.Set(event.RefundFeePerTicket),
+		db.Event.RestockRefunds.Set(event.RestockRefunds),
 	).Exec(ctx)
 	return err
 }
@@ -98,141 +162,337 @@ func NewTicketRepository(client *db.PrismaClient) domain.TicketRepository {
 }
 
 func (r *ticketRepository) Create(ctx context.Context, ticket *domain.Ticket) error {
-	status := db.TicketStatusAvailable
-	switch ticket.Status {
-	case "reserved":
-		status = db.TicketStatusReserved
-	case "sold":
-		status = db.TicketStatusSold
+	if ticket.Status == "" {
+		ticket.Status = domain.TicketAvailable
+	}
+	if err := domain.CheckNewTicket(ticket); err != nil {
+		return err
 	}
 
-	params := []db.TicketSetParam{
+	_, err := r.client.Ticket.CreateOne(
+		db.Ticket.Seat.Set(ticket.Seat),
+		db.Ticket.Event.Link(db.Event.ID.Equals(ticket.EventID)),
 		db.Ticket.ID.Set(ticket.ID),
 		db.Ticket.EventID.Set(ticket.EventID),
-		db.Ticket.Status.Set(status),
+		db.Ticket.Status.Set(db.TicketStatusAvailable),
 		db.Ticket.Price.Set(ticket.Price),
+		db.Ticket.LockToken.Set(db.BigInt(ticket.LockToken)),
+		db.Ticket.SeatID.SetOptional(optionalString(ticket.SeatID)),
+		db.Ticket.TierID.SetOptional(optionalString(ticket.TierID)),
+	).Exec(ctx)
+	return err
+}
+
+// GetByID returns domain.ErrTicketNotFound when the ticket does not exist
+func (r *ticketRepository) GetByID(ctx context.Context, id string) (*domain.Ticket, error) {
+	ticket, err := r.client.Ticket.FindUnique(
+		db.Ticket.ID.Equals(id),
+	).Exec(ctx)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, domain.ErrTicketNotFound
+	}
+	if err != nil {
+		return nil, err
 	}
+	return toDomainTicket(ticket), nil
+}
 
-	if ticket.UserID != "" {
-		params = append(params, db.Ticket.UserID.SetOptional(&ticket.UserID))
+func (r *ticketRepository) GetByEventID(ctx context.Context, eventID string) ([]*domain.Ticket, error) {
+	tickets, err := r.client.Ticket.FindMany(
+		db.Ticket.EventID.Equals(eventID),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
 	}
-	if ticket.ReservedUntil != nil {
-		params = append(params, db.Ticket.ReservedUntil.SetOptional(ticket.ReservedUntil))
+
+	var result []*domain.Ticket
+	for i := range tickets {
+		result = append(result, toDomainTicket(&tickets[i]))
 	}
+	return result, nil
+}
 
-	_, err := r.client.Ticket.CreateOne(
-		db.Ticket.Seat.Set(ticket.Seat),
-		db.Ticket.Event.Link(db.Event.ID.Equals(ticket.EventID)),
-		params...,
+// GetByEventAndSeat looks a ticket up by its unique (event, seat) pair and
+// returns domain.ErrTicketNotFound when the seat does not exist
+func (r *ticketRepository) GetByEventAndSeat(ctx context.Context, eventID, seat string) (*domain.Ticket, error) {
+	ticket, err := r.client.Ticket.FindUnique(
+		db.Ticket.EventIDSeat(
+			db.Ticket.EventID.Equals(eventID),
+			db.Ticket.Seat.Equals(seat),
+		),
 	).Exec(ctx)
-	return err
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, domain.ErrTicketNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toDomainTicket(ticket), nil
 }
 
-func (r *ticketRepository) GetByID(ctx context.Context, id string) (*domain.Ticket, error) {
+// GetByEventAndSeatID looks the ticket of a venue seat up by its unique
+// (event, seat_id) pair and returns domain.ErrTicketNotFound when the event
+// has no ticket for that seat
+func (r *ticketRepository) GetByEventAndSeatID(ctx context.Context, eventID, seatID string) (*domain.Ticket, error) {
 	ticket, err := r.client.Ticket.FindUnique(
-		db.Ticket.ID.Equals(id),
+		db.Ticket.EventIDSeatID(
+			db.Ticket.EventID.Equals(eventID),
+			db.Ticket.SeatID.Equals(seatID),
+		),
 	).Exec(ctx)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, domain.ErrTicketNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
+	return toDomainTicket(ticket), nil
+}
 
-	status := "available"
-	switch ticket.Status {
-	case db.TicketStatusReserved:
-		status = "reserved"
-	case db.TicketStatusSold:
-		status = "sold"
+// Update writes the ticket if the lifecycle allows moving it from its stored
+// status, see domain.CheckTransition. The write only applies while the
+// stored status is unchanged and the row carries no newer lock token;
+// otherwise it returns a *domain.TransitionError or
+// domain.ErrStaleLockToken.
+func (r *ticketRepository) Update(ctx context.Context, ticket *domain.Ticket) error {
+	current, err := r.GetByID(ctx, ticket.ID)
+	if err != nil {
+		return err
+	}
+	if err := domain.CheckTransition(current.Status, ticket, time.Now()); err != nil {
+		return err
+	}
+	from, err := dbTicketStatus(current.Status)
+	if err != nil {
+		return err
+	}
+	status, err := dbTicketStatus(ticket.Status)
+	if err != nil {
+		return err
 	}
 
-	userID, _ := ticket.UserID()
-	reservedUntil, reservedUntilOk := ticket.ReservedUntil()
+	// Holder fields are cleared when empty, since an available ticket has none
+	result, err := r.client.Ticket.FindMany(
+		db.Ticket.ID.Equals(ticket.ID),
+		db.Ticket.Status.Equals(from),
+		db.Ticket.LockToken.Lte(db.BigInt(ticket.LockToken)),
+	).Update(
+		db.Ticket.Status.Set(status),
+		db.Ticket.Price.Set(ticket.Price),
+		db.Ticket.LockToken.Set(db.BigInt(ticket.LockToken)),
+		db.Ticket.UserID.SetOptional(optionalString(ticket.UserID)),
+		db.Ticket.HoldID.SetOptional(optionalString(ticket.HoldID)),
+		db.Ticket.ReservedUntil.SetOptional(ticket.ReservedUntil),
+	).Exec(ctx)
+	if err != nil {
+		return ticketWriteError(err)
+	}
+	if result.Count == 0 {
+		// The ticket is gone, moved on or a newer lock holder has written it
+		latest, err := r.GetByID(ctx, ticket.ID)
+		if err != nil {
+			return err
+		}
+		if latest.Status != current.Status {
+			return &domain.TransitionError{Seat: ticket.Seat, From: latest.Status, To: ticket.Status, Reason: "the ticket changed concurrently"}
+		}
+		return domain.ErrStaleLockToken
+	}
+	return nil
+}
+
+// ticketStatuses maps lifecycle statuses to the database enum
+var ticketStatuses = map[domain.TicketStatus]db.TicketStatus{
+	domain.TicketAvailable: db.TicketStatusAvailable,
+	domain.TicketReserved:  db.TicketStatusReserved,
+	domain.TicketSold:      db.TicketStatusSold,
+	domain.TicketRefunded:  db.TicketStatusRefunded,
+	domain.TicketCheckedIn: db.TicketStatusCheckedIn,
+}
+
+func dbTicketStatus(status domain.TicketStatus) (db.TicketStatus, error) {
+	dbStatus, ok := ticketStatuses[status]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown ticket status %q", domain.ErrIllegalTransition, status)
+	}
+	return dbStatus, nil
+}
+
+// domainTicketStatus maps the database enum back to a lifecycle status,
+// available for an unknown value
+func domainTicketStatus(status db.TicketStatus) domain.TicketStatus {
+	for domainStatus, dbStatus := range ticketStatuses {
+		if dbStatus == status {
+			return domainStatus
+		}
+	}
+	return domain.TicketAvailable
+}
 
-	var reservedUntilPtr *time.Time
-	if reservedUntilOk {
-		t := time.Time(reservedUntil)
-		reservedUntilPtr = &t
+func toDomainTicket(ticket *db.TicketModel) *domain.Ticket {
+	status := domainTicketStatus(ticket.Status)
+
+	userID, _ := ticket.UserID()
+	seatID, _ := ticket.SeatID()
+	tierID, _ := ticket.TierID()
+	holdID, _ := ticket.HoldID()
+	var reservedUntil *time.Time
+	if until, ok := ticket.ReservedUntil(); ok {
+		t := time.Time(until)
+		reservedUntil = &t
 	}
 
 	return &domain.Ticket{
 		ID:            ticket.ID,
 		EventID:       ticket.EventID,
 		UserID:        userID,
+		SeatID:        seatID,
+		TierID:        tierID,
 		Seat:          ticket.Seat,
 		Status:        status,
 		Price:         ticket.Price,
-		ReservedUntil: reservedUntilPtr,
+		HoldID:        holdID,
+		ReservedUntil: reservedUntil,
+		LockToken:     int64(ticket.LockToken),
 		CreatedAt:     ticket.CreatedAt,
 		UpdatedAt:     ticket.UpdatedAt,
-	}, nil
+	}
 }
 
-func (r *ticketRepository) GetByEventID(ctx context.Context, eventID string) ([]*domain.Ticket, error) {
-	tickets, err := r.client.Ticket.FindMany(
-		db.Ticket.EventID.Equals(eventID),
-	).Exec(ctx)
-	if err != nil {
-		return nil, err
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
 	}
+	return &s
+}
 
-	var result []*domain.Ticket
-	for _, ticket := range tickets {
-		status := "available"
-		switch ticket.Status {
-		case db.TicketStatusReserved:
-			status = "reserved"
-		case db.TicketStatusSold:
-			status = "sold"
-		}
-
-		userID, _ := ticket.UserID()
-		reservedUntil, reservedUntilOk := ticket.ReservedUntil()
+// illegalTransitionCode is the SQLSTATE the check_ticket_transition
+// trigger raises
+const illegalTransitionCode = "FT001"
 
-		var reservedUntilPtr *time.Time
-		if reservedUntilOk {
-			t := time.Time(reservedUntil)
-			reservedUntilPtr = &t
-		}
+// illegalTransitionMessage picks the statuses and seat out of the
+// trigger's message, which the engine may quote
+var illegalTransitionMessage = regexp.MustCompile("illegal ticket transition from ([A-Z_]+) to ([A-Z_]+) of seat ([^\\s'\"`]+)")
 
-		result = append(result, &domain.Ticket{
-			ID:            ticket.ID,
-			EventID:       ticket.EventID,
-			UserID:        userID,
-			Seat:          ticket.Seat,
-			Status:        status,
-			Price:         ticket.Price,
-			ReservedUntil: reservedUntilPtr,
-			CreatedAt:     ticket.CreatedAt,
-			UpdatedAt:     ticket.UpdatedAt,
-		})
+// ticketWriteError turns a write refused by the check_ticket_transition
+// trigger into a *domain.TransitionError
+func ticketWriteError(err error) error {
+	if err == nil || !strings.Contains(err.Error(), illegalTransitionCode) {
+		return err
+	}
+	match := illegalTransitionMessage.FindStringSubmatch(err.Error())
+	if match == nil {
+		return fmt.Errorf("%w: %v", domain.ErrIllegalTransition, err)
+	}
+	return &domain.TransitionError{
+		Seat: match[3],
+		From: domainTicketStatus(db.TicketStatus(match[1])),
+		To:   domainTicketStatus(db.TicketStatus(match[2])),
 	}
-	return result, nil
 }
 
-func (r *ticketRepository) Update(ctx context.Context, ticket *domain.Ticket) error {
-	status := db.TicketStatusAvailable
-	switch ticket.Status {
-	case "reserved":
-		status = db.TicketStatusReserved
-	case "sold":
-		status = db.TicketStatusSold
+// inventoryChunkSize is how many tickets one INSERT of CreateInventory
+// carries
+const inventoryChunkSize = 5000
+
+// lockEventQuery locks event $1 so inventory for it is created by one
+// transaction at a time
+const lockEventQuery = `UPDATE "events" SET "updated_at" = $2 WHERE "id" = $1`
+
+// insertInventoryQuery inserts the tickets in $2 for event $1, stamped $3,
+// unless the event has seats other than those stamped $3. General-admission
+// tickets are not seats.
+const insertInventoryQuery = `INSERT INTO "tickets" ("id", "event_id", "seat_id", "seat", "status", "price", "lock_token", "created_at", "updated_at")
+SELECT ticket."id", $1, NULLIF(ticket."seat_id", ''), ticket."seat", 'AVAILABLE', ticket."price", 0, $3, $3
+FROM jsonb_to_recordset($2::jsonb) AS ticket("id" text, "seat_id" text, "seat" text, "price" numeric)
+WHERE NOT EXISTS (
+	SELECT 1 FROM "tickets" t
+	LEFT JOIN "ticket_tiers" tt ON tt."id" = t."tier_id"
+	WHERE t."event_id" = $1 AND t."created_at" <> $3 AND tt."kind" IS DISTINCT FROM 'GENERAL_ADMISSION'
+)`
+
+// assignTierSeatsQuery puts the seats of event $1 without a tier into the
+// reserved tier listing their section. A seat of section S is labelled S, a
+// dash, and a row name and number without a dash, see domain.SeatLayout.
+const assignTierSeatsQuery = `UPDATE "tickets" t
+SET "tier_id" = tt."id", "updated_at" = $2
+FROM "ticket_tiers" tt, unnest(tt."sections") AS section("name")
+WHERE t."event_id" = $1 AND t."tier_id" IS NULL AND tt."event_id" = $1 AND tt."kind" = 'RESERVED'
+	AND left(t."seat", length(section."name") + 1) = section."name" || '-'
+	AND strpos(substr(t."seat", length(section."name") + 2), '-') = 0`
+
+// CreateInventory inserts the tickets in chunks within one transaction and
+// puts them into the event's reserved tiers. The event row is locked first,
+// so a concurrent call waits and then finds the tickets this one created.
+func (r *ticketRepository) CreateInventory(ctx context.Context, eventID string, tickets []*domain.Ticket) error {
+	now := time.Now().UTC()
+	lock := r.client.Prisma.ExecuteRaw(lockEventQuery, eventID, now).Tx()
+	txs, inserts, err := inventoryTxs(r.client, eventID, tickets, now)
+	if err != nil {
+		return err
 	}
 
-	params := []db.TicketSetParam{
-		db.Ticket.Status.Set(status),
-		db.Ticket.Price.Set(ticket.Price),
+	err = r.client.Prisma.Transaction(append([]db.PrismaTransaction{lock}, txs...)...).Exec(ctx)
+	if err == nil && lock.Result().Count == 0 {
+		return domain.ErrEventNotFound
 	}
+	return inventoryResult(err, inserts, len(tickets))
+}
 
-	if ticket.UserID != "" {
-		params = append(params, db.Ticket.UserID.SetOptional(&ticket.UserID))
+// inventoryTxs builds the statements that insert the tickets of event
+// eventID in chunks, stamped now, and put them into its reserved tiers.
+// The results of the inserts are returned for inventoryResult.
+func inventoryTxs(client *db.PrismaClient, eventID string, tickets []*domain.Ticket, now time.Time) ([]db.PrismaTransaction, []raw.TxExecuteResult, error) {
+	type ticketRow struct {
+		ID     string          `json:"id"`
+		SeatID string          `json:"seat_id"`
+		Seat   string          `json:"seat"`
+		Price  decimal.Decimal `json:"price"`
 	}
-	if ticket.ReservedUntil != nil {
-		params = append(params, db.Ticket.ReservedUntil.SetOptional(ticket.ReservedUntil))
+	var txs []db.PrismaTransaction
+	var inserts []raw.TxExecuteResult
+	for start := 0; start < len(tickets); start += inventoryChunkSize {
+		chunk := tickets[start:min(start+inventoryChunkSize, len(tickets))]
+		rows := make([]ticketRow, 0, len(chunk))
+		for _, ticket := range chunk {
+			if err := domain.CheckNewTicket(ticket); err != nil {
+				return nil, nil, err
+			}
+			if ticket.ID == "" {
+				ticket.ID = uuid.New().String()
+			}
+			rows = append(rows, ticketRow{ID: ticket.ID, SeatID: ticket.SeatID, Seat: ticket.Seat, Price: ticket.Price})
+		}
+		rowsJSON, err := json.Marshal(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		insert := client.Prisma.ExecuteRaw(insertInventoryQuery, eventID, string(rowsJSON), now).Tx()
+		inserts = append(inserts, insert)
+		txs = append(txs, insert)
 	}
+	txs = append(txs, client.Prisma.ExecuteRaw(assignTierSeatsQuery, eventID, now).Tx())
+	return txs, inserts, nil
+}
 
-	_, err := r.client.Ticket.FindUnique(
-		db.Ticket.ID.Equals(ticket.ID),
-	).Update(params...).Exec(ctx)
-	return err
+// inventoryResult maps the outcome of a transaction with the inserts of
+// inventoryTxs to domain.ErrInventoryExists when not all of want tickets
+// were inserted
+func inventoryResult(err error, inserts []raw.TxExecuteResult, want int) error {
+	if err != nil {
+		if strings.Contains(err.Error(), "tickets_event_id_seat_key") {
+			return domain.ErrInventoryExists
+		}
+		return err
+	}
+	inserted := 0
+	for _, insert := range inserts {
+		inserted += insert.Result().Count
+	}
+	if inserted < want {
+		return domain.ErrInventoryExists
+	}
+	return nil
 }
 
 func (r *ticketRepository) Delete(ctx context.Context, id string) error {
@@ -242,95 +502,1510 @@ func (r *ticketRepository) Delete(ctx context.Context, id string) error {
 	return err
 }
 
-func (r *ticketRepository) ReserveSeat(ctx context.Context, eventID, seat string, userID string, duration time.Duration) error {
-	reservedUntil := time.Now().Add(duration)
-	_, err := r.client.Ticket.FindMany(
-		db.Ticket.EventID.Equals(eventID),
-		db.Ticket.Seat.Equals(seat),
-		db.Ticket.Status.Equals(db.TicketStatusAvailable),
-	).Update(
-		db.Ticket.UserID.SetOptional(&userID),
-		db.Ticket.Status.Set(db.TicketStatusReserved),
-		db.Ticket.ReservedUntil.SetOptional(&reservedUntil),
-	).Exec(ctx)
-	return err
+// seatUpdateQuery applies a conditional UPDATE to one seat and reports the
+// row as it was before the update, all in one statement. Postgres runs the
+// statement in its own transaction, and FOR UPDATE makes concurrent callers
+// queue on the row so each one evaluates its condition against the latest
+// committed state.
+const seatUpdateQuery = `WITH target AS (
+	SELECT "id", "status", "user_id", "reserved_until", "lock_token"
+	FROM "tickets"
+	WHERE "event_id" = $1 AND "seat" = $2
+	FOR UPDATE
+), updated AS (
+	UPDATE "tickets" t
+	SET %s
+	FROM target
+	WHERE t."id" = target."id" AND %s
+	RETURNING t."id"
+)
+SELECT target."status"::text AS "status", target."user_id", target."reserved_until", target."lock_token",
+	(SELECT COUNT(*) FROM updated)::int AS "updated"
+FROM target`
+
+// seatUpdateRow is the row returned by seatUpdateQuery
+type seatUpdateRow struct {
+	Status        db.RawString    `json:"status"`
+	UserID        *db.RawString   `json:"user_id"`
+	ReservedUntil *db.RawDateTime `json:"reserved_until"`
+	LockToken     db.RawBigInt    `json:"lock_token"`
+	Updated       db.RawInt       `json:"updated"`
 }
 
-func (r *ticketRepository) ReleaseSeat(ctx context.Context, eventID, seat string) error {
-	_, err := r.client.Ticket.FindMany(
-		db.Ticket.EventID.Equals(eventID),
-		db.Ticket.Seat.Equals(seat),
-	).Update(
-		db.Ticket.UserID.SetOptional(nil),
-		db.Ticket.Status.Set(db.TicketStatusAvailable),
-		db.Ticket.ReservedUntil.SetOptional(nil),
-	).Exec(ctx)
-	return err
+func (r *ticketRepository) updateSeat(ctx context.Context, set, where string, params ...interface{}) (*seatUpdateRow, error) {
+	var rows []seatUpdateRow
+	query := fmt.Sprintf(seatUpdateQuery, set, where)
+	if err := r.client.Prisma.QueryRaw(query, params...).Exec(ctx, &rows); err != nil {
+		return nil, ticketWriteError(err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	return &rows[0], nil
 }
 
-type userRepository struct {
-	client *db.PrismaClient
+// reserveSeatsQuery reserves every seat in the JSON array $2 and inserts
+// hold $4 covering them, or does neither. A seat whose reservation has
+// lapsed counts as available, and the current holder may reserve again. $6
+// maps each seat to its lock's fencing token. Rows are locked in id order so
+// overlapping batches cannot deadlock.
+const reserveSeatsQuery = `WITH target AS (
+	SELECT "id", "seat", "status", "user_id", "reserved_until", "lock_token"
+	FROM "tickets"
+	WHERE "event_id" = $1 AND "seat" IN (SELECT jsonb_array_elements_text($2::jsonb))
+	ORDER BY "id"
+	FOR UPDATE
+), free AS (
+	SELECT "id" FROM target
+	WHERE ("status" = 'AVAILABLE' OR ("status" = 'RESERVED' AND ("reserved_until" <= $7 OR "user_id" = $3)))
+		AND "lock_token" <= (($6::jsonb) ->> "seat")::bigint
+), hold AS (
+	INSERT INTO "holds" ("id", "event_id", "user_id", "expires_at", "extensions", "created_at", "updated_at")
+	SELECT $4, $1, $3, $5, 0, $7, $7
+	WHERE (SELECT COUNT(*) FROM free) = jsonb_array_length($2::jsonb)
+	RETURNING "id"
+), updated AS (
+	UPDATE "tickets" t
+	SET "status" = 'RESERVED', "user_id" = $3, "hold_id" = hold."id", "reserved_until" = $5,
+		"lock_token" = (($6::jsonb) ->> target."seat")::bigint, "updated_at" = $7
+	FROM target, hold
+	WHERE t."id" = target."id"
+	RETURNING t."id"
+)
+SELECT target."seat", target."id" IN (SELECT "id" FROM free) AS "free",
+	(SELECT COUNT(*) FROM updated)::int AS "updated"
+FROM target`
+
+// ReserveSeats reserves all seats for the user under a new hold, or none. On
+// failure it returns SeatNotFound with the seats that do not exist, or
+// SeatTaken with the seats held or sold by someone else.
+func (r *ticketRepository) ReserveSeats(ctx context.Context, eventID string, seats []string, lockTokens []int64, userID, holdID string, duration time.Duration) (domain.SeatResult, []string, error) {
+	tokens := make(map[string]int64, len(seats))
+	for i, seat := range seats {
+		tokens[seat] = lockTokens[i]
+	}
+	seatsJSON, err := json.Marshal(seats)
+	if err != nil {
+		return domain.SeatNotFound, nil, err
+	}
+	tokensJSON, err := json.Marshal(tokens)
+	if err != nil {
+		return domain.SeatNotFound, nil, err
+	}
+
+	var rows []struct {
+		Seat    db.RawString  `json:"seat"`
+		Free    db.RawBoolean `json:"free"`
+		Updated db.RawInt     `json:"updated"`
+	}
+	now := time.Now().UTC()
+	err = r.client.Prisma.QueryRaw(reserveSeatsQuery,
+		eventID, string(seatsJSON), userID, holdID, now.Add(duration), string(tokensJSON), now,
+	).Exec(ctx, &rows)
+	if err != nil {
+		return domain.SeatNotFound, nil, ticketWriteError(err)
+	}
+
+	if len(rows) > 0 && rows[0].Updated > 0 {
+		return domain.SeatOK, nil, nil
+	}
+
+	found := make(map[string]bool, len(rows))
+	var taken []string
+	for _, row := range rows {
+		found[string(row.Seat)] = true
+		if !row.Free {
+			taken = append(taken, string(row.Seat))
+		}
+	}
+	var missing []string
+	for _, seat := range seats {
+		if !found[seat] {
+			missing = append(missing, seat)
+		}
+	}
+	if len(missing) > 0 {
+		return domain.SeatNotFound, missing, nil
+	}
+	return domain.SeatTaken, taken, nil
 }
 
-func NewUserRepository(client *db.PrismaClient) domain.UserRepository {
-	return &userRepository{client: client}
+// confirmSeatQuery sells seat $2 to user $3 unless they already bought
+// $6 tickets for the event ($6 = 0 means no limit). Besides the seat it
+// locks the user's reserved and sold rows, in id order, so concurrent
+// confirms by the same user queue up and each counts the others' sales.
+const confirmSeatQuery = `WITH locked AS (
+	SELECT "id", "seat", "status", "user_id", "reserved_until", "lock_token"
+	FROM "tickets"
+	WHERE "event_id" = $1 AND ("seat" = $2 OR ("user_id" = $3 AND "status" IN ('RESERVED', 'SOLD', 'CHECKED_IN')))
+	ORDER BY "id"
+	FOR UPDATE
+), target AS (
+	SELECT * FROM locked WHERE "seat" = $2
+), sold AS (
+	SELECT COUNT(*) AS "count" FROM locked
+	WHERE "seat" <> $2 AND "user_id" = $3 AND "status" IN ('SOLD', 'CHECKED_IN')
+), updated AS (
+	UPDATE "tickets" t
+	SET "status" = 'SOLD', "lock_token" = $4::bigint, "updated_at" = $5
+	FROM target
+	WHERE t."id" = target."id" AND target."status" = 'RESERVED' AND target."user_id" = $3
+		AND target."reserved_until" > $5 AND target."lock_token" <= $4::bigint
+		AND ($6 = 0 OR (SELECT "count" FROM sold) < $6)
+	RETURNING t."id"
+)
+SELECT target."status"::text AS "status", target."user_id", target."reserved_until", target."lock_token",
+	(SELECT COUNT(*) FROM updated)::int AS "updated", (SELECT "count" FROM sold)::int AS "sold"
+FROM target`
+
+// ConfirmSeat marks the seat SOLD if the user holds an unexpired
+// reservation, the lock token is not older than the one recorded, and the
+// user has bought fewer than maxTickets tickets for the event (0 for no
+// limit). It returns the reason when the seat cannot be confirmed.
+func (r *ticketRepository) ConfirmSeat(ctx context.Context, eventID, seat string, userID string, lockToken int64, maxTickets int) (domain.SeatResult, error) {
+	var rows []struct {
+		seatUpdateRow
+		Sold db.RawInt `json:"sold"`
+	}
+	now := time.Now().UTC()
+	if err := r.client.Prisma.QueryRaw(confirmSeatQuery, eventID, seat, userID, lockToken, now, maxTickets).Exec(ctx, &rows); err != nil {
+		return domain.SeatNotFound, ticketWriteError(err)
+	}
+	if len(rows) == 0 {
+		return domain.SeatNotFound, nil
+	}
+
+	row := rows[0]
+	switch {
+	case row.Updated == 1:
+		return domain.SeatOK, nil
+	case row.Status != "RESERVED":
+		return domain.SeatNotReserved, nil
+	case row.UserID == nil || string(*row.UserID) != userID:
+		return domain.SeatTaken, nil
+	case row.ReservedUntil == nil || !row.ReservedUntil.After(now):
+		return domain.SeatExpired, nil
+	case maxTickets > 0 && int(row.Sold) >= maxTickets:
+		return domain.SeatLimitReached, nil
+	}
+	return domain.SeatTaken, nil
 }
 
-func (r *userRepository) Create(ctx context.Context, user *domain.User) error {
-	_, err := r.client.User.CreateOne(
-		db.User.Email.Set(user.Email),
-		db.User.Name.Set(user.Name),
-	).Exec(ctx)
-	return err
+// CountSold returns how many tickets of the event the user has bought and
+// not had refunded
+func (r *ticketRepository) CountSold(ctx context.Context, eventID, userID string) (int, error) {
+	var rows []struct {
+		Count db.RawInt `json:"count"`
+	}
+	query := `SELECT COUNT(*)::int AS "count" FROM "tickets" WHERE "user_id" = $1 AND "status" IN ('SOLD', 'CHECKED_IN') AND "event_id" = $2`
+	if err := r.client.Prisma.QueryRaw(query, userID, eventID).Exec(ctx, &rows); err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	return int(rows[0].Count), nil
 }
 
-func (r *userRepository) GetByID(ctx context.Context, id string) (*domain.User, error) {
-	user, err := r.client.User.FindUnique(
-		db.User.ID.Equals(id),
-	).Exec(ctx)
+// ReleaseSeat returns a seat reserved by the user to inventory. The lock
+// token is kept so older lock holders stay fenced off.
+func (r *ticketRepository) ReleaseSeat(ctx context.Context, eventID, seat string, userID string) (domain.SeatResult, error) {
+	row, err := r.updateSeat(ctx,
+		`"status" = 'AVAILABLE', "user_id" = NULL, "hold_id" = NULL, "reserved_until" = NULL, "updated_at" = $4`,
+		`target."status" = 'RESERVED' AND target."user_id" = $3`,
+		eventID, seat, userID, time.Now().UTC(),
+	)
 	if err != nil {
-		return nil, err
+		return domain.SeatNotFound, err
+	}
+	switch {
+	case row == nil:
+		return domain.SeatNotFound, nil
+	case row.Updated == 1:
+		return domain.SeatOK, nil
+	case row.Status != "RESERVED":
+		return domain.SeatNotReserved, nil
 	}
+	return domain.SeatTaken, nil
+}
 
-	return &domain.User{
-		ID:        user.ID,
-		Email:     user.Email,
-		Name:      user.Name,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
-	}, nil
+// reserveTierQuery reserves $3 tickets of general-admission tier $2 of
+// event $1 and inserts hold $5 of the tier covering them, or does neither. A
+// ticket whose reservation has lapsed counts as available. SKIP LOCKED lets
+// concurrent buyers pick different tickets instead of queueing on the same
+// ones.
+const reserveTierQuery = `WITH picked AS (
+	SELECT "id" FROM "tickets"
+	WHERE "event_id" = $1 AND "tier_id" = $2
+		AND ("status" = 'AVAILABLE' OR ("status" = 'RESERVED' AND "reserved_until" <= $7))
+	LIMIT $3
+	FOR UPDATE SKIP LOCKED
+), hold AS (
+	INSERT INTO "holds" ("id", "event_id", "user_id", "tier_id", "expires_at", "extensions", "created_at", "updated_at")
+	SELECT $5, $1, $4, $2, $6, 0, $7, $7
+	WHERE (SELECT COUNT(*) FROM picked) = $3
+	RETURNING "id"
+)
+UPDATE "tickets" t
+SET "status" = 'RESERVED', "user_id" = $4, "hold_id" = hold."id", "reserved_until" = $6, "updated_at" = $7
+FROM picked, hold
+WHERE t."id" = picked."id"
+RETURNING t."seat"`
+
+// ReserveTier reserves general-admission tickets without seat locks; their
+// lock token stays 0, which ConfirmSeat accepts with a token of 0
+func (r *ticketRepository) ReserveTier(ctx context.Context, eventID, tierID string, quantity int, userID, holdID string, duration time.Duration) ([]string, error) {
+	var rows []struct {
+		Seat db.RawString `json:"seat"`
+	}
+	now := time.Now().UTC()
+	err := r.client.Prisma.QueryRaw(reserveTierQuery,
+		eventID, tierID, quantity, userID, holdID, now.Add(duration), now,
+	).Exec(ctx, &rows)
+	if err != nil {
+		return nil, ticketWriteError(err)
+	}
+	if len(rows) < quantity {
+		return nil, domain.ErrSoldOut
+	}
+
+	seats := make([]string, 0, len(rows))
+	for _, row := range rows {
+		seats = append(seats, string(row.Seat))
+	}
+	sort.Strings(seats)
+	return seats, nil
 }
 
-func (r *userRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
-	user, err := r.client.User.FindUnique(
-		db.User.Email.Equals(email),
+// releaseExpiredQuery returns up to $2 lapsed reservations to inventory.
+// SKIP LOCKED lets several replicas sweep at once without blocking on or
+// double-releasing the same rows.
+const releaseExpiredQuery = `WITH expired AS (
+	SELECT "id", "event_id", "seat", "user_id"
+	FROM "tickets"
+	WHERE "status" = 'RESERVED' AND "reserved_until" <= $1
+	ORDER BY "reserved_until"
+	LIMIT $2
+	FOR UPDATE SKIP LOCKED
+)
+UPDATE "tickets" t
+SET "status" = 'AVAILABLE', "user_id" = NULL, "hold_id" = NULL, "reserved_until" = NULL, "updated_at" = $1
+FROM expired
+WHERE t."id" = expired."id"
+RETURNING expired."event_id", expired."seat", expired."user_id"`
+
+// ReleaseExpired releases at most limit reservations that lapsed before now
+func (r *ticketRepository) ReleaseExpired(ctx context.Context, now time.Time, limit int) ([]domain.ExpiredReservation, error) {
+	var rows []struct {
+		EventID db.RawString  `json:"event_id"`
+		Seat    db.RawString  `json:"seat"`
+		UserID  *db.RawString `json:"user_id"`
+	}
+	if err := r.client.Prisma.QueryRaw(releaseExpiredQuery, now.UTC(), limit).Exec(ctx, &rows); err != nil {
+		return nil, ticketWriteError(err)
+	}
+
+	result := make([]domain.ExpiredReservation, 0, len(rows))
+	for _, row := range rows {
+		reservation := domain.ExpiredReservation{
+			EventID: string(row.EventID),
+			Seat:    string(row.Seat),
+		}
+		if row.UserID != nil {
+			reservation.UserID = string(*row.UserID)
+		}
+		result = append(result, reservation)
+	}
+	return result, nil
+}
+
+type holdRepository struct {
+	client *db.PrismaClient
+}
+
+func NewHoldRepository(client *db.PrismaClient) domain.HoldRepository {
+	return &holdRepository{client: client}
+}
+
+// GetByID returns the hold with the seats it still reserves and those sold
+// under it, or domain.ErrHoldNotFound
+func (r *holdRepository) GetByID(ctx context.Context, id string) (*domain.Hold, error) {
+	hold, err := r.client.Hold.FindUnique(
+		db.Hold.ID.Equals(id),
 	).Exec(ctx)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, domain.ErrHoldNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return &domain.User{
-		ID:        user.ID,
-		Email:     user.Email,
-		Name:      user.Name,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
+	tickets, err := r.client.Ticket.FindMany(
+		db.Ticket.HoldID.Equals(id),
+	).OrderBy(
+		db.Ticket.Seat.Order(db.SortOrderAsc),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seats := make([]string, 0, len(tickets))
+	var sold []string
+	for _, ticket := range tickets {
+		switch status := domainTicketStatus(ticket.Status); {
+		case status == domain.TicketReserved:
+			seats = append(seats, ticket.Seat)
+		case status.Purchased():
+			sold = append(sold, ticket.Seat)
+		}
+	}
+
+	paymentID, _ := hold.PaymentID()
+	tierID, _ := hold.TierID()
+	return &domain.Hold{
+		ID:         hold.ID,
+		EventID:    hold.EventID,
+		UserID:     hold.UserID,
+		TierID:     tierID,
+		Seats:      seats,
+		Sold:       sold,
+		ExpiresAt:  hold.ExpiresAt,
+		Extensions: hold.Extensions,
+		PaymentID:  paymentID,
+		CreatedAt:  hold.CreatedAt,
+		UpdatedAt:  hold.UpdatedAt,
 	}, nil
 }
 
-func (r *userRepository) Update(ctx context.Context, user *domain.User) error {
-	_, err := r.client.User.FindUnique(
-		db.User.ID.Equals(user.ID),
-	).Update(
-		db.User.Email.Set(user.Email),
-		db.User.Name.Set(user.Name),
-	).Exec(ctx)
-	return err
+// findTierHoldQuery finds user $2's newest hold of tier $1 that has not
+// lapsed by $3 and still reserves tickets
+const findTierHoldQuery = `SELECT h."id" FROM "holds" h
+WHERE h."tier_id" = $1 AND h."user_id" = $2 AND h."expires_at" > $3
+	AND EXISTS (SELECT 1 FROM "tickets" t WHERE t."hold_id" = h."id" AND t."status" = 'RESERVED')
+ORDER BY h."created_at" DESC
+LIMIT 1`
+
+func (r *holdRepository) FindByTier(ctx context.Context, tierID, userID string) (*domain.Hold, error) {
+	var rows []struct {
+		ID db.RawString `json:"id"`
+	}
+	if err := r.client.Prisma.QueryRaw(findTierHoldQuery, tierID, userID, time.Now().UTC()).Exec(ctx, &rows); err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, domain.ErrHoldNotFound
+	}
+	return r.GetByID(ctx, string(rows[0].ID))
 }
 
-func (r *userRepository) Delete(ctx context.Context, id string) error {
-	_, err := r.client.User.FindUnique(
-		db.User.ID.Equals(id),
+// extendHoldQuery moves hold $1 and its reserved seats to expiry $3 if $2
+// owns it, it has not lapsed and it has been extended fewer than $4 times
+const extendHoldQuery = `WITH target AS (
+	SELECT "id", "user_id", "expires_at", "extensions"
+	FROM "holds"
+	WHERE "id" = $1
+	FOR UPDATE
+), extended AS (
+	UPDATE "holds" h
+	SET "expires_at" = $3, "extensions" = target."extensions" + 1, "updated_at" = $5
+	FROM target
+	WHERE h."id" = target."id" AND target."user_id" = $2 AND target."expires_at" > $5 AND target."extensions" < $4
+	RETURNING h."id"
+), seats AS (
+	UPDATE "tickets"
+	SET "reserved_until" = $3, "updated_at" = $5
+	WHERE "hold_id" IN (SELECT "id" FROM extended) AND "status" = 'RESERVED'
+	RETURNING "id"
+)
+SELECT target."user_id", target."expires_at", target."extensions",
+	(SELECT COUNT(*) FROM extended)::int AS "updated"
+FROM target`
+
+// Extend returns domain.ErrHoldNotFound when the hold does not exist or is
+// owned by someone else, ErrReservationExpired when it has lapsed and
+// ErrHoldExtensionLimit when it has been extended maxExtensions times
+func (r *holdRepository) Extend(ctx context.Context, id, userID string, expiresAt time.Time, maxExtensions int) error {
+	var rows []struct {
+		UserID     db.RawString   `json:"user_id"`
+		ExpiresAt  db.RawDateTime `json:"expires_at"`
+		Extensions db.RawInt      `json:"extensions"`
+		Updated    db.RawInt      `json:"updated"`
+	}
+	now := time.Now().UTC()
+	err := r.client.Prisma.QueryRaw(extendHoldQuery, id, userID, expiresAt.UTC(), maxExtensions, now).Exec(ctx, &rows)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(rows) == 0 || string(rows[0].UserID) != userID:
+		return domain.ErrHoldNotFound
+	case rows[0].Updated > 0:
+		return nil
+	case !rows[0].ExpiresAt.After(now):
+		return domain.ErrReservationExpired
+	}
+	return domain.ErrHoldExtensionLimit
+}
+
+func (r *holdRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.client.Hold.FindUnique(
+		db.Hold.ID.Equals(id),
 	).Delete().Exec(ctx)
 	return err
 }
+
+// setPaymentQuery points hold $1 at payment $2 unless it already has
+// another one, and returns the payment it ends up with
+const setPaymentQuery = `WITH target AS (
+	SELECT "id", "payment_id" FROM "holds" WHERE "id" = $1 FOR UPDATE
+), updated AS (
+	UPDATE "holds" h
+	SET "payment_id" = $2, "updated_at" = $3
+	FROM target
+	WHERE h."id" = target."id" AND target."payment_id" IS NULL
+	RETURNING h."id"
+)
+SELECT COALESCE(target."payment_id", $2) AS "payment_id" FROM target`
+
+func (r *holdRepository) SetPayment(ctx context.Context, id, paymentID string) error {
+	var rows []struct {
+		PaymentID db.RawString `json:"payment_id"`
+	}
+	if err := r.client.Prisma.QueryRaw(setPaymentQuery, id, paymentID, time.Now().UTC()).Exec(ctx, &rows); err != nil {
+		return err
+	}
+	switch {
+	case len(rows) == 0:
+		return domain.ErrHoldNotFound
+	case string(rows[0].PaymentID) != paymentID:
+		return domain.ErrPaymentConflict
+	}
+	return nil
+}
+
+// deleteExpiredHoldsQuery removes up to $2 holds that lapsed before $1 and no
+// longer reserve any seat, and returns the payments of those that sold none.
+// A hold that sold seats but whose order is still pending stays, so the
+// capture of its payment can be retried.
+const deleteExpiredHoldsQuery = `WITH expired AS (
+	SELECT h."id", h."payment_id",
+		EXISTS (SELECT 1 FROM "tickets" t WHERE t."hold_id" = h."id" AND t."status" IN ('SOLD', 'REFUNDED', 'CHECKED_IN')) AS "sold"
+	FROM "holds" h
+	WHERE h."expires_at" <= $1
+		AND NOT EXISTS (SELECT 1 FROM "tickets" t WHERE t."hold_id" = h."id" AND t."status" = 'RESERVED')
+		AND NOT (
+			EXISTS (SELECT 1 FROM "tickets" t WHERE t."hold_id" = h."id" AND t."status" IN ('SOLD', 'REFUNDED', 'CHECKED_IN'))
+			AND EXISTS (SELECT 1 FROM "orders" o WHERE o."hold_id" = h."id" AND o."status" = 'PENDING')
+		)
+	LIMIT $2
+	FOR UPDATE OF h SKIP LOCKED
+), deleted AS (
+	DELETE FROM "holds" WHERE "id" IN (SELECT "id" FROM expired)
+)
+SELECT "payment_id" FROM expired WHERE "payment_id" IS NOT NULL AND NOT "sold"`
+
+// DeleteExpired removes up to limit holds that lapsed before now and no
+// longer reserve any seat
+func (r *holdRepository) DeleteExpired(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	var rows []struct {
+		PaymentID db.RawString `json:"payment_id"`
+	}
+	if err := r.client.Prisma.QueryRaw(deleteExpiredHoldsQuery, now.UTC(), limit).Exec(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	payments := make([]string, 0, len(rows))
+	for _, row := range rows {
+		payments = append(payments, string(row.PaymentID))
+	}
+	return payments, nil
+}
+
+type orderRepository struct {
+	client *db.PrismaClient
+}
+
+func NewOrderRepository(client *db.PrismaClient) domain.OrderRepository {
+	return &orderRepository{client: client}
+}
+
+// createOrderQuery inserts order $1 and the items in $11 unless hold $4
+// already has an order, and returns whether it did. Amounts are passed as
+// text so they reach the numeric columns without a round trip through
+// floating point.
+const createOrderQuery = `WITH created AS (
+	INSERT INTO "orders" ("id", "user_id", "event_id", "hold_id", "status", "subtotal", "fees", "taxes", "total", "currency", "payment_id", "created_at", "updated_at")
+	VALUES ($1, $2, $3, NULLIF($4, ''), $5::"OrderStatus", $6::numeric, $7::numeric, $8::numeric, $9::numeric, $10, NULLIF($12, ''), $13, $13)
+	ON CONFLICT ("hold_id") DO NOTHING
+	RETURNING "id"
+), items AS (
+	INSERT INTO "order_items" ("id", "order_id", "ticket_id", "seat", "price", "created_at")
+	SELECT item."id", created."id", item."ticket_id", item."seat", item."price", $13
+	FROM created, jsonb_to_recordset($11::jsonb) AS item("id" text, "ticket_id" text, "seat" text, "price" numeric)
+)
+SELECT (SELECT COUNT(*) FROM created)::int AS "created"`
+
+func (r *orderRepository) Create(ctx context.Context, order *domain.Order) error {
+	type itemRow struct {
+		ID       string          `json:"id"`
+		TicketID string          `json:"ticket_id"`
+		Seat     string          `json:"seat"`
+		Price    decimal.Decimal `json:"price"`
+	}
+	items := make([]itemRow, 0, len(order.Items))
+	for _, item := range order.Items {
+		items = append(items, itemRow{ID: uuid.New().String(), TicketID: item.TicketID, Seat: item.Seat, Price: item.Price})
+	}
+	itemsJSON, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+
+	var rows []struct {
+		Created db.RawInt `json:"created"`
+	}
+	now := time.Now().UTC()
+	err = r.client.Prisma.QueryRaw(createOrderQuery,
+		order.ID, order.UserID, order.EventID, order.HoldID, string(orderStatus(order.Status)),
+		order.Subtotal.String(), order.Fees.String(), order.Taxes.String(), order.Total.String(), order.Currency,
+		string(itemsJSON), order.PaymentID, now,
+	).Exec(ctx, &rows)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 || rows[0].Created == 0 {
+		return domain.ErrOrderExists
+	}
+	return nil
+}
+
+func (r *orderRepository) GetByID(ctx context.Context, id string) (*domain.Order, error) {
+	order, err := r.client.Order.FindUnique(
+		db.Order.ID.Equals(id),
+	).Exec(ctx)
+	return r.withItems(ctx, order, err)
+}
+
+func (r *orderRepository) GetByHoldID(ctx context.Context, holdID string) (*domain.Order, error) {
+	order, err := r.client.Order.FindUnique(
+		db.Order.HoldID.Equals(holdID),
+	).Exec(ctx)
+	return r.withItems(ctx, order, err)
+}
+
+func (r *orderRepository) ListByUser(ctx context.Context, userID string) ([]*domain.Order, error) {
+	orders, err := r.client.Order.FindMany(
+		db.Order.UserID.Equals(userID),
+	).OrderBy(
+		db.Order.CreatedAt.Order(db.SortOrderDesc),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return []*domain.Order{}, nil
+	}
+
+	ids := make([]string, 0, len(orders))
+	for _, order := range orders {
+		ids = append(ids, order.ID)
+	}
+	items, err := r.items(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*domain.Order, 0, len(orders))
+	for i := range orders {
+		result = append(result, toDomainOrder(&orders[i], items[orders[i].ID]))
+	}
+	return result, nil
+}
+
+// updateOrderQuery stores the status, amounts and payment of order $1 and
+// deletes its items whose tickets are not in $8
+const updateOrderQuery = `WITH updated AS (
+	UPDATE "orders"
+	SET "status" = $2::"OrderStatus", "subtotal" = $3::numeric, "fees" = $4::numeric, "taxes" = $5::numeric, "total" = $6::numeric,
+		"payment_id" = NULLIF($7, ''), "updated_at" = $9
+	WHERE "id" = $1
+	RETURNING "id"
+), removed AS (
+	DELETE FROM "order_items"
+	WHERE "order_id" IN (SELECT "id" FROM updated)
+		AND NOT ("ticket_id" IN (SELECT jsonb_array_elements_text($8::jsonb)))
+)
+SELECT COUNT(*)::int AS "updated" FROM updated`
+
+func (r *orderRepository) Update(ctx context.Context, order *domain.Order) error {
+	ticketIDs := make([]string, 0, len(order.Items))
+	for _, item := range order.Items {
+		ticketIDs = append(ticketIDs, item.TicketID)
+	}
+	ticketsJSON, err := json.Marshal(ticketIDs)
+	if err != nil {
+		return err
+	}
+
+	var rows []struct {
+		Updated db.RawInt `json:"updated"`
+	}
+	err = r.client.Prisma.QueryRaw(updateOrderQuery,
+		order.ID, string(orderStatus(order.Status)),
+		order.Subtotal.String(), order.Fees.String(), order.Taxes.String(), order.Total.String(),
+		order.PaymentID, string(ticketsJSON), time.Now().UTC(),
+	).Exec(ctx, &rows)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 || rows[0].Updated == 0 {
+		return domain.ErrOrderNotFound
+	}
+	return nil
+}
+
+func (r *orderRepository) CancelPending(ctx context.Context, paymentID string) error {
+	_, err := r.client.Order.FindMany(
+		db.Order.PaymentID.Equals(paymentID),
+		db.Order.Status.Equals(db.OrderStatusPending),
+	).Update(
+		db.Order.Status.Set(db.OrderStatusCancelled),
+	).Exec(ctx)
+	return err
+}
+
+// withItems loads the items of an order just fetched
+func (r *orderRepository) withItems(ctx context.Context, order *db.OrderModel, err error) (*domain.Order, error) {
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, domain.ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := r.items(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	return toDomainOrder(order, items[order.ID]), nil
+}
+
+// items returns the items of the orders by order ID, in seat order
+func (r *orderRepository) items(ctx context.Context, orderIDs ...string) (map[string][]domain.OrderItem, error) {
+	rows, err := r.client.OrderItem.FindMany(
+		db.OrderItem.OrderID.In(orderIDs),
+	).OrderBy(
+		db.OrderItem.Seat.Order(db.SortOrderAsc),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make(map[string][]domain.OrderItem, len(orderIDs))
+	for _, row := range rows {
+		refundID, _ := row.RefundID()
+		items[row.OrderID] = append(items[row.OrderID], domain.OrderItem{
+			TicketID: row.TicketID,
+			Seat:     row.Seat,
+			Price:    row.Price,
+			RefundID: refundID,
+		})
+	}
+	return items, nil
+}
+
+func toDomainOrder(order *db.OrderModel, items []domain.OrderItem) *domain.Order {
+	status := domain.OrderPending
+	switch order.Status {
+	case db.OrderStatusPaid:
+		status = domain.OrderPaid
+	case db.OrderStatusCancelled:
+		status = domain.OrderCancelled
+	case db.OrderStatusRefunded:
+		status = domain.OrderRefunded
+	}
+	if items == nil {
+		items = []domain.OrderItem{}
+	}
+
+	holdID, _ := order.HoldID()
+	paymentID, _ := order.PaymentID()
+	return &domain.Order{
+		ID:        order.ID,
+		UserID:    order.UserID,
+		EventID:   order.EventID,
+		HoldID:    holdID,
+		Items:     items,
+		Subtotal:  order.Subtotal,
+		Fees:      order.Fees,
+		Taxes:     order.Taxes,
+		Total:     order.Total,
+		Refunded:  order.Refunded,
+		Currency:  order.Currency,
+		PaymentID: paymentID,
+		Status:    status,
+		CreatedAt: order.CreatedAt,
+		UpdatedAt: order.UpdatedAt,
+	}
+}
+
+func orderStatus(status string) db.OrderStatus {
+	switch status {
+	case domain.OrderPaid:
+		return db.OrderStatusPaid
+	case domain.OrderCancelled:
+		return db.OrderStatusCancelled
+	case domain.OrderRefunded:
+		return db.OrderStatusRefunded
+	}
+	return db.OrderStatusPending
+}
+
+type refundRepository struct {
+	client *db.PrismaClient
+}
+
+func NewRefundRepository(client *db.PrismaClient) domain.RefundRepository {
+	return &refundRepository{client: client}
+}
+
+// createRefundQuery inserts pending refund $1 of order $2 and claims the
+// order's items for the tickets in $3, or does neither when any of them is
+// missing, already claimed or no longer sold, e.g. checked in. It returns
+// how many of the tickets are not sold.
+const createRefundQuery = `WITH target AS (
+	SELECT oi."id", oi."refund_id", t."status" AS "ticket_status"
+	FROM "order_items" oi
+	JOIN "tickets" t ON t."id" = oi."ticket_id"
+	WHERE oi."order_id" = $2 AND oi."ticket_id" IN (SELECT jsonb_array_elements_text($3::jsonb))
+	ORDER BY oi."id"
+	FOR UPDATE OF oi, t
+), refund AS (
+	INSERT INTO "refunds" ("id", "order_id", "payment_id", "amount", "fee", "currency", "restock", "requested_by", "status", "created_at", "updated_at")
+	SELECT $1, $2, $4, $5::numeric, $6::numeric, $7, $8, $9, 'PENDING', $10, $10
+	WHERE (SELECT COUNT(*) FROM target WHERE "refund_id" IS NULL AND "ticket_status" = 'SOLD') = jsonb_array_length($3::jsonb)
+	RETURNING "id"
+), claimed AS (
+	UPDATE "order_items" oi
+	SET "refund_id" = refund."id"
+	FROM target, refund
+	WHERE oi."id" = target."id"
+	RETURNING oi."id"
+)
+SELECT (SELECT COUNT(*) FROM refund)::int AS "created",
+	(SELECT COUNT(*) FROM target WHERE "ticket_status" <> 'SOLD')::int AS "unsold"`
+
+func (r *refundRepository) Create(ctx context.Context, refund *domain.Refund) error {
+	ticketIDs := make([]string, 0, len(refund.Items))
+	for _, item := range refund.Items {
+		ticketIDs = append(ticketIDs, item.TicketID)
+	}
+	ticketsJSON, err := json.Marshal(ticketIDs)
+	if err != nil {
+		return err
+	}
+
+	var rows []struct {
+		Created db.RawInt `json:"created"`
+		Unsold  db.RawInt `json:"unsold"`
+	}
+	now := time.Now().UTC()
+	err = r.client.Prisma.QueryRaw(createRefundQuery,
+		refund.ID, refund.OrderID, string(ticketsJSON), refund.PaymentID,
+		refund.Amount.String(), refund.Fee.String(), refund.Currency, refund.Restock, refund.RequestedBy, now,
+	).Exec(ctx, &rows)
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(rows) > 0 && rows[0].Created > 0:
+	case len(rows) > 0 && rows[0].Unsold > 0:
+		return domain.ErrTicketNotRefundable
+	default:
+		return domain.ErrAlreadyRefunded
+	}
+	refund.CreatedAt = now
+	refund.UpdatedAt = now
+	return nil
+}
+
+func (r *refundRepository) ListByOrder(ctx context.Context, orderID string) ([]*domain.Refund, error) {
+	refunds, err := r.client.Refund.FindMany(
+		db.Refund.OrderID.Equals(orderID),
+	).OrderBy(
+		db.Refund.CreatedAt.Order(db.SortOrderAsc),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(refunds) == 0 {
+		return []*domain.Refund{}, nil
+	}
+
+	ids := make([]string, 0, len(refunds))
+	for _, refund := range refunds {
+		ids = append(ids, refund.ID)
+	}
+	rows, err := r.client.OrderItem.FindMany(
+		db.OrderItem.RefundID.In(ids),
+	).OrderBy(
+		db.OrderItem.Seat.Order(db.SortOrderAsc),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[string][]domain.OrderItem, len(refunds))
+	for _, row := range rows {
+		refundID, _ := row.RefundID()
+		items[refundID] = append(items[refundID], domain.OrderItem{
+			TicketID: row.TicketID,
+			Seat:     row.Seat,
+			Price:    row.Price,
+			RefundID: refundID,
+		})
+	}
+
+	result := make([]*domain.Refund, 0, len(refunds))
+	for _, refund := range refunds {
+		status := domain.RefundPending
+		switch refund.Status {
+		case db.RefundStatusSucceeded:
+			status = domain.RefundSucceeded
+		case db.RefundStatusFailed:
+			status = domain.RefundFailed
+		}
+		providerRefundID, _ := refund.ProviderRefundID()
+		refundItems := items[refund.ID]
+		if refundItems == nil {
+			refundItems = []domain.OrderItem{}
+		}
+
+		result = append(result, &domain.Refund{
+			ID:               refund.ID,
+			OrderID:          refund.OrderID,
+			PaymentID:        refund.PaymentID,
+			ProviderRefundID: providerRefundID,
+			Items:            refundItems,
+			Amount:           refund.Amount,
+			Fee:              refund.Fee,
+			Currency:         refund.Currency,
+			Restock:          refund.Restock,
+			RequestedBy:      refund.RequestedBy,
+			Status:           status,
+			CreatedAt:        refund.CreatedAt,
+			UpdatedAt:        refund.UpdatedAt,
+		})
+	}
+	return result, nil
+}
+
+// completeRefundQuery settles pending refund $1: it adds the amount to the
+// order's refunded total, marks the order refunded once every item belongs
+// to a succeeded refund, and moves the sold tickets to REFUNDED. Items of
+// another refund still pending keep the order paid, so they can be refunded
+// again if that refund fails. The lock token is kept
+// so older seat locks stay fenced off.
+const completeRefundQuery = `WITH refund AS (
+	UPDATE "refunds"
+	SET "status" = 'SUCCEEDED', "provider_refund_id" = NULLIF($2, ''), "updated_at" = $3
+	WHERE "id" = $1 AND "status" = 'PENDING'
+	RETURNING "id", "order_id", "amount", "restock"
+), refunded_order AS (
+	UPDATE "orders" o
+	SET "refunded" = o."refunded" + refund."amount",
+		"status" = CASE
+			WHEN EXISTS (
+				SELECT 1 FROM "order_items" oi
+				LEFT JOIN "refunds" r ON r."id" = oi."refund_id"
+				WHERE oi."order_id" = o."id" AND oi."refund_id" IS DISTINCT FROM refund."id"
+					AND r."status" IS DISTINCT FROM 'SUCCEEDED'
+			) THEN o."status"
+			ELSE 'REFUNDED'::"OrderStatus"
+		END,
+		"updated_at" = $3
+	FROM refund
+	WHERE o."id" = refund."order_id"
+	RETURNING o."id"
+), tickets AS (
+	UPDATE "tickets" t
+	SET "status" = 'REFUNDED', "reserved_until" = NULL, "updated_at" = $3
+	FROM refund, "order_items" oi
+	WHERE oi."refund_id" = refund."id" AND t."id" = oi."ticket_id" AND t."status" = 'SOLD'
+	RETURNING t."id"
+)
+SELECT "id" FROM refund`
+
+// restockRefundQuery puts the refunded tickets of succeeded restocking
+// refund $1 back on sale and returns them. It runs after completeRefundQuery
+// in the same transaction, which has moved the tickets to REFUNDED.
+const restockRefundQuery = `UPDATE "tickets" t
+SET "status" = 'AVAILABLE', "user_id" = NULL, "hold_id" = NULL, "reserved_until" = NULL, "updated_at" = $2
+FROM "refunds" r, "order_items" oi
+WHERE r."id" = $1 AND r."restock" AND r."status" = 'SUCCEEDED' AND oi."refund_id" = r."id" AND t."id" = oi."ticket_id" AND t."status" = 'REFUNDED'
+RETURNING t."id", t."event_id", t."seat", t."tier_id"`
+
+// Complete settles the refund and restocks its seats in one transaction, so
+// a refund never succeeds with its seats left off sale
+func (r *refundRepository) Complete(ctx context.Context, id, providerRefundID string) ([]*domain.Ticket, error) {
+	now := time.Now().UTC()
+	complete := r.client.Prisma.QueryRaw(completeRefundQuery, id, providerRefundID, now).Tx()
+	restock := r.client.Prisma.QueryRaw(restockRefundQuery, id, now).Tx()
+	if err := r.client.Prisma.Transaction(complete, restock).Exec(ctx); err != nil {
+		return nil, ticketWriteError(err)
+	}
+
+	var rows []struct {
+		ID      db.RawString  `json:"id"`
+		EventID db.RawString  `json:"event_id"`
+		Seat    db.RawString  `json:"seat"`
+		TierID  *db.RawString `json:"tier_id"`
+	}
+	if err := restock.Into(&rows); err != nil {
+		return nil, err
+	}
+	tickets := make([]*domain.Ticket, 0, len(rows))
+	for _, row := range rows {
+		ticket := &domain.Ticket{
+			ID:      string(row.ID),
+			EventID: string(row.EventID),
+			Seat:    string(row.Seat),
+			Status:  domain.TicketAvailable,
+		}
+		if row.TierID != nil {
+			ticket.TierID = string(*row.TierID)
+		}
+		tickets = append(tickets, ticket)
+	}
+	return tickets, nil
+}
+
+// failRefundQuery marks pending refund $1 failed and releases its items
+const failRefundQuery = `WITH failed AS (
+	UPDATE "refunds"
+	SET "status" = 'FAILED', "updated_at" = $2
+	WHERE "id" = $1 AND "status" = 'PENDING'
+	RETURNING "id"
+)
+UPDATE "order_items" SET "refund_id" = NULL
+WHERE "refund_id" IN (SELECT "id" FROM failed)`
+
+func (r *refundRepository) Fail(ctx context.Context, id string) error {
+	_, err := r.client.Prisma.ExecuteRaw(failRefundQuery, id, time.Now().UTC()).Exec(ctx)
+	return err
+}
+
+type paymentEventRepository struct {
+	client *db.PrismaClient
+}
+
+func NewPaymentEventRepository(client *db.PrismaClient) domain.PaymentEventRepository {
+	return &paymentEventRepository{client: client}
+}
+
+// recordPaymentEventQuery stores webhook event $1, or counts another
+// delivery of it, and reports whether it has not been processed yet
+const recordPaymentEventQuery = `INSERT INTO "payment_events"
+	("id", "type", "provider_type", "payment_id", "hold_id", "payload", "created_at", "received_at")
+VALUES ($1, NULLIF($2, ''), $3, NULLIF($4, ''), NULLIF($5, ''), $6::jsonb, $7, $8)
+ON CONFLICT ("id") DO UPDATE SET "deliveries" = "payment_events"."deliveries" + 1
+RETURNING "processed_at" IS NULL AS "pending"`
+
+func (r *paymentEventRepository) Record(ctx context.Context, event *domain.PaymentEvent) (bool, error) {
+	var rows []struct {
+		Pending db.RawBoolean `json:"pending"`
+	}
+	err := r.client.Prisma.QueryRaw(recordPaymentEventQuery,
+		event.ID, event.Type, event.ProviderType, event.PaymentID, event.HoldID,
+		string(event.Payload), event.CreatedAt.UTC(), time.Now().UTC(),
+	).Exec(ctx, &rows)
+	if err != nil {
+		return false, err
+	}
+	if len(rows) == 0 {
+		return false, fmt.Errorf("payment event %s was not recorded", event.ID)
+	}
+	return bool(rows[0].Pending), nil
+}
+
+func (r *paymentEventRepository) MarkProcessed(ctx context.Context, id, result string) error {
+	_, err := r.client.Prisma.ExecuteRaw(
+		`UPDATE "payment_events" SET "result" = $2, "processed_at" = $3 WHERE "id" = $1`,
+		id, result, time.Now().UTC(),
+	).Exec(ctx)
+	return err
+}
+
+type userRepository struct {
+	client *db.PrismaClient
+}
+
+func NewUserRepository(client *db.PrismaClient) domain.UserRepository {
+	return &userRepository{client: client}
+}
+
+func (r *userRepository) Create(ctx context.Context, user *domain.User) error {
+	_, err := r.client.User.CreateOne(
+		db.User.Email.Set(user.Email),
+		db.User.Name.Set(user.Name),
+	).Exec(ctx)
+	return err
+}
+
+func (r *userRepository) GetByID(ctx context.Context, id string) (*domain.User, error) {
+	user, err := r.client.User.FindUnique(
+		db.User.ID.Equals(id),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.User{
+		ID:        user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}, nil
+}
+
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	user, err := r.client.User.FindUnique(
+		db.User.Email.Equals(email),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.User{
+		ID:        user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}, nil
+}
+
+func (r *userRepository) Update(ctx context.Context, user *domain.User) error {
+	_, err := r.client.User.FindUnique(
+		db.User.ID.Equals(user.ID),
+	).Update(
+		db.User.Email.Set(user.Email),
+		db.User.Name.Set(user.Name),
+	).Exec(ctx)
+	return err
+}
+
+func (r *userRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.client.User.FindUnique(
+		db.User.ID.Equals(id),
+	).Delete().Exec(ctx)
+	return err
+}
+
+type venueRepository struct {
+	client *db.PrismaClient
+}
+
+func NewVenueRepository(client *db.PrismaClient) domain.VenueRepository {
+	return &venueRepository{client: client}
+}
+
+// Seat map inserts take their rows as jsonb, like createOrderQuery
+const (
+	createVenueQuery = `INSERT INTO "venues" ("id", "name", "address", "created_at", "updated_at") VALUES ($1, $2, $3, $4, $4)`
+
+	createVenueSectionsQuery = `INSERT INTO "venue_sections" ("id", "venue_id", "name", "position")
+SELECT section."id", $1, section."name", section."position"
+FROM jsonb_to_recordset($2::jsonb) AS section("id" text, "name" text, "position" int)`
+
+	createVenueRowsQuery = `INSERT INTO "venue_rows" ("id", "section_id", "name", "position")
+SELECT venue_row."id", venue_row."section_id", venue_row."name", venue_row."position"
+FROM jsonb_to_recordset($1::jsonb) AS venue_row("id" text, "section_id" text, "name" text, "position" int)`
+
+	createVenueSeatsQuery = `INSERT INTO "venue_seats" ("id", "row_id", "number", "label", "x", "y", "accessible", "restricted_view", "aisle")
+SELECT seat."id", seat."row_id", seat."number", seat."label", seat."x", seat."y", seat."accessible", seat."restricted_view", seat."aisle"
+FROM jsonb_to_recordset($1::jsonb) AS seat("id" text, "row_id" text, "number" int, "label" text, "x" float8, "y" float8, "accessible" bool, "restricted_view" bool, "aisle" bool)`
+)
+
+// Create inserts the venue and its seat map in one transaction, the seats in
+// chunks of inventoryChunkSize
+func (r *venueRepository) Create(ctx context.Context, venue *domain.Venue) error {
+	type sectionRow struct {
+		ID       string `json:"id"`
+		Name     string `json:"name"`
+		Position int    `json:"position"`
+	}
+	type rowRow struct {
+		ID        string `json:"id"`
+		SectionID string `json:"section_id"`
+		Name      string `json:"name"`
+		Position  int    `json:"position"`
+	}
+	type seatRow struct {
+		ID             string  `json:"id"`
+		RowID          string  `json:"row_id"`
+		Number         int     `json:"number"`
+		Label          string  `json:"label"`
+		X              float64 `json:"x"`
+		Y              float64 `json:"y"`
+		Accessible     bool    `json:"accessible"`
+		RestrictedView bool    `json:"restricted_view"`
+		Aisle          bool    `json:"aisle"`
+	}
+
+	if venue.ID == "" {
+		venue.ID = uuid.New().String()
+	}
+	var sections []sectionRow
+	var rows []rowRow
+	var seats []seatRow
+	for i := range venue.Sections {
+		section := &venue.Sections[i]
+		section.ID = uuid.New().String()
+		sections = append(sections, sectionRow{ID: section.ID, Name: section.Name, Position: i})
+		for j := range section.Rows {
+			row := &section.Rows[j]
+			row.ID = uuid.New().String()
+			rows = append(rows, rowRow{ID: row.ID, SectionID: section.ID, Name: row.Name, Position: j})
+			for k := range row.Seats {
+				seat := &row.Seats[k]
+				seat.ID = uuid.New().String()
+				seats = append(seats, seatRow{
+					ID: seat.ID, RowID: row.ID, Number: seat.Number, Label: seat.Label, X: seat.X, Y: seat.Y,
+					Accessible: seat.Accessible, RestrictedView: seat.RestrictedView, Aisle: seat.Aisle,
+				})
+			}
+		}
+	}
+
+	sectionsJSON, err := json.Marshal(sections)
+	if err != nil {
+		return err
+	}
+	rowsJSON, err := json.Marshal(rows)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	txs := []db.PrismaTransaction{
+		r.client.Prisma.ExecuteRaw(createVenueQuery, venue.ID, venue.Name, venue.Address, now).Tx(),
+		r.client.Prisma.ExecuteRaw(createVenueSectionsQuery, venue.ID, string(sectionsJSON)).Tx(),
+		r.client.Prisma.ExecuteRaw(createVenueRowsQuery, string(rowsJSON)).Tx(),
+	}
+	for start := 0; start < len(seats); start += inventoryChunkSize {
+		seatsJSON, err := json.Marshal(seats[start:min(start+inventoryChunkSize, len(seats))])
+		if err != nil {
+			return err
+		}
+		txs = append(txs, r.client.Prisma.ExecuteRaw(createVenueSeatsQuery, string(seatsJSON)).Tx())
+	}
+	if err := r.client.Prisma.Transaction(txs...).Exec(ctx); err != nil {
+		return err
+	}
+
+	venue.Capacity = len(seats)
+	venue.CreatedAt = now
+	venue.UpdatedAt = now
+	return nil
+}
+
+// venueSeatsQuery returns the seat map of venue $1 in drawing order
+const venueSeatsQuery = `SELECT s."id" AS "section_id", s."name" AS "section", r."id" AS "row_id", r."name" AS "row",
+	seat."id", seat."number", seat."label", seat."x", seat."y", seat."accessible", seat."restricted_view", seat."aisle"
+FROM "venue_sections" s
+JOIN "venue_rows" r ON r."section_id" = s."id"
+JOIN "venue_seats" seat ON seat."row_id" = r."id"
+WHERE s."venue_id" = $1
+ORDER BY s."position", r."position", seat."number"`
+
+// GetByID returns domain.ErrVenueNotFound when the venue does not exist
+func (r *venueRepository) GetByID(ctx context.Context, id string) (*domain.Venue, error) {
+	model, err := r.client.Venue.FindUnique(
+		db.Venue.ID.Equals(id),
+	).Exec(ctx)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, domain.ErrVenueNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		SectionID      db.RawString  `json:"section_id"`
+		Section        db.RawString  `json:"section"`
+		RowID          db.RawString  `json:"row_id"`
+		Row            db.RawString  `json:"row"`
+		ID             db.RawString  `json:"id"`
+		Number         db.RawInt     `json:"number"`
+		Label          db.RawString  `json:"label"`
+		X              db.RawFloat   `json:"x"`
+		Y              db.RawFloat   `json:"y"`
+		Accessible     db.RawBoolean `json:"accessible"`
+		RestrictedView db.RawBoolean `json:"restricted_view"`
+		Aisle          db.RawBoolean `json:"aisle"`
+	}
+	if err := r.client.Prisma.QueryRaw(venueSeatsQuery, id).Exec(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	venue := toDomainVenue(model)
+	venue.Capacity = len(rows)
+	for _, row := range rows {
+		if n := len(venue.Sections); n == 0 || venue.Sections[n-1].ID != string(row.SectionID) {
+			venue.Sections = append(venue.Sections, domain.VenueSection{ID: string(row.SectionID), Name: string(row.Section)})
+		}
+		section := &venue.Sections[len(venue.Sections)-1]
+		if n := len(section.Rows); n == 0 || section.Rows[n-1].ID != string(row.RowID) {
+			section.Rows = append(section.Rows, domain.VenueRow{ID: string(row.RowID), Name: string(row.Row)})
+		}
+		venueRow := &section.Rows[len(section.Rows)-1]
+		venueRow.Seats = append(venueRow.Seats, domain.VenueSeat{
+			ID:             string(row.ID),
+			Number:         int(row.Number),
+			Label:          string(row.Label),
+			X:              float64(row.X),
+			Y:              float64(row.Y),
+			Accessible:     bool(row.Accessible),
+			RestrictedView: bool(row.RestrictedView),
+			Aisle:          bool(row.Aisle),
+		})
+	}
+	return venue, nil
+}
+
+// venueCapacitiesQuery counts the seats of every venue
+const venueCapacitiesQuery = `SELECT s."venue_id", COUNT(*)::int AS "capacity"
+FROM "venue_sections" s
+JOIN "venue_rows" r ON r."section_id" = s."id"
+JOIN "venue_seats" seat ON seat."row_id" = r."id"
+GROUP BY s."venue_id"`
+
+func (r *venueRepository) GetAll(ctx context.Context) ([]*domain.Venue, error) {
+	venues, err := r.client.Venue.FindMany().OrderBy(
+		db.Venue.Name.Order(db.SortOrderAsc),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var capacities []struct {
+		VenueID  db.RawString `json:"venue_id"`
+		Capacity db.RawInt    `json:"capacity"`
+	}
+	if err := r.client.Prisma.QueryRaw(venueCapacitiesQuery).Exec(ctx, &capacities); err != nil {
+		return nil, err
+	}
+	capacity := make(map[string]int, len(capacities))
+	for _, row := range capacities {
+		capacity[string(row.VenueID)] = int(row.Capacity)
+	}
+
+	result := make([]*domain.Venue, 0, len(venues))
+	for i := range venues {
+		venue := toDomainVenue(&venues[i])
+		venue.Capacity = capacity[venue.ID]
+		result = append(result, venue)
+	}
+	return result, nil
+}
+
+func (r *venueRepository) Update(ctx context.Context, venue *domain.Venue) error {
+	_, err := r.client.Venue.FindUnique(
+		db.Venue.ID.Equals(venue.ID),
+	).Update(
+		db.Venue.Name.Set(venue.Name),
+		db.Venue.Address.Set(venue.Address),
+	).Exec(ctx)
+	if errors.Is(err, db.ErrNotFound) {
+		return domain.ErrVenueNotFound
+	}
+	return err
+}
+
+func (r *venueRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.client.Venue.FindUnique(
+		db.Venue.ID.Equals(id),
+	).Delete().Exec(ctx)
+	if errors.Is(err, db.ErrNotFound) {
+		return domain.ErrVenueNotFound
+	}
+	if err != nil && strings.Contains(err.Error(), "events_venue_id_fkey") {
+		return domain.ErrVenueInUse
+	}
+	return err
+}
+
+func toDomainVenue(venue *db.VenueModel) *domain.Venue {
+	return &domain.Venue{
+		ID:        venue.ID,
+		Name:      venue.Name,
+		Address:   venue.Address,
+		CreatedAt: venue.CreatedAt,
+		UpdatedAt: venue.UpdatedAt,
+	}
+}
+
+type ticketTierRepository struct {
+	client *db.PrismaClient
+}
+
+func NewTicketTierRepository(client *db.PrismaClient) domain.TicketTierRepository {
+	return &ticketTierRepository{client: client}
+}
+
+// tierKinds maps tier kinds to the database enum
+var tierKinds = map[string]db.TierKind{
+	domain.TierReserved:         db.TierKindReserved,
+	domain.TierGeneralAdmission: db.TierKindGeneralAdmission,
+}
+
+// createTierQuery inserts tier $1 of event $2 unless its $6 tickets would
+// take the event's tickets past its capacity. $7 is a JSON array of the
+// tier's sections.
+const createTierQuery = `INSERT INTO "ticket_tiers" ("id", "event_id", "name", "kind", "price", "quantity", "sections", "created_at")
+SELECT $1, e."id", $3, $4::"TierKind", $5, $6, ARRAY(SELECT jsonb_array_elements_text($7::jsonb)), $8
+FROM "events" e
+WHERE e."id" = $2 AND e."capacity" >= $6 + (SELECT COUNT(*) FROM "tickets" WHERE "event_id" = $2)`
+
+// insertTierTicketsQuery inserts the tickets in $3 of tier $2 of event $1,
+// stamped $4, if the tier was inserted
+const insertTierTicketsQuery = `INSERT INTO "tickets" ("id", "event_id", "tier_id", "seat", "status", "price", "lock_token", "created_at", "updated_at")
+SELECT ticket."id", $1, $2, ticket."seat", 'AVAILABLE', ticket."price", 0, $4, $4
+FROM jsonb_to_recordset($3::jsonb) AS ticket("id" text, "seat" text, "price" numeric)
+WHERE EXISTS (SELECT 1 FROM "ticket_tiers" WHERE "id" = $2)`
+
+// Create inserts the tier and its tickets, in chunks of inventoryChunkSize,
+// in one transaction with the event row locked like CreateInventory, so
+// concurrent calls cannot both fit into the same capacity
+func (r *ticketTierRepository) Create(ctx context.Context, tier *domain.TicketTier, tickets []*domain.Ticket) error {
+	type ticketRow struct {
+		ID    string          `json:"id"`
+		Seat  string          `json:"seat"`
+		Price decimal.Decimal `json:"price"`
+	}
+	kind, ok := tierKinds[tier.Kind]
+	if !ok {
+		return fmt.Errorf("%w: unknown kind %q", domain.ErrInvalidTier, tier.Kind)
+	}
+	if tier.ID == "" {
+		tier.ID = uuid.New().String()
+	}
+	sections := tier.Sections
+	if sections == nil {
+		sections = []string{}
+	}
+	sectionsJSON, err := json.Marshal(sections)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	lock := r.client.Prisma.ExecuteRaw(lockEventQuery, tier.EventID, now).Tx()
+	create := r.client.Prisma.ExecuteRaw(createTierQuery,
+		tier.ID, tier.EventID, tier.Name, string(kind), tier.Price.String(), len(tickets), string(sectionsJSON), now,
+	).Tx()
+	txs := []db.PrismaTransaction{lock, create}
+	for start := 0; start < len(tickets); start += inventoryChunkSize {
+		chunk := tickets[start:min(start+inventoryChunkSize, len(tickets))]
+		rows := make([]ticketRow, 0, len(chunk))
+		for _, ticket := range chunk {
+			if err := domain.CheckNewTicket(ticket); err != nil {
+				return err
+			}
+			if ticket.ID == "" {
+				ticket.ID = uuid.New().String()
+			}
+			ticket.TierID = tier.ID
+			rows = append(rows, ticketRow{ID: ticket.ID, Seat: ticket.Seat, Price: ticket.Price})
+		}
+		rowsJSON, err := json.Marshal(rows)
+		if err != nil {
+			return err
+		}
+		txs = append(txs, r.client.Prisma.ExecuteRaw(insertTierTicketsQuery, tier.EventID, tier.ID, string(rowsJSON), now).Tx())
+	}
+	if kind == db.TierKindReserved {
+		txs = append(txs, r.client.Prisma.ExecuteRaw(assignTierSeatsQuery, tier.EventID, now).Tx())
+	}
+
+	if err := r.client.Prisma.Transaction(txs...).Exec(ctx); err != nil {
+		if strings.Contains(err.Error(), "ticket_tiers_event_id_name_key") || strings.Contains(err.Error(), "tickets_event_id_seat_key") {
+			return fmt.Errorf("%w: the event already has tickets named %q", domain.ErrInvalidTier, tier.Name)
+		}
+		return ticketWriteError(err)
+	}
+	if lock.Result().Count == 0 {
+		return domain.ErrEventNotFound
+	}
+	if create.Result().Count == 0 {
+		return domain.ErrCapacityExceeded
+	}
+	tier.Quantity = len(tickets)
+	tier.CreatedAt = now
+	return nil
+}
+
+func (r *ticketTierRepository) GetByID(ctx context.Context, id string) (*domain.TicketTier, error) {
+	tier, err := r.client.TicketTier.FindUnique(
+		db.TicketTier.ID.Equals(id),
+	).Exec(ctx)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, domain.ErrTierNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toDomainTier(tier), nil
+}
+
+func (r *ticketTierRepository) ListByEvent(ctx context.Context, eventID string) ([]*domain.TicketTier, error) {
+	tiers, err := r.client.TicketTier.FindMany(
+		db.TicketTier.EventID.Equals(eventID),
+	).OrderBy(
+		db.TicketTier.CreatedAt.Order(db.SortOrderAsc),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*domain.TicketTier, 0, len(tiers))
+	for i := range tiers {
+		result = append(result, toDomainTier(&tiers[i]))
+	}
+	return result, nil
+}
+
+func (r *ticketTierRepository) CountAvailable(ctx context.Context, tierID string) (int, error) {
+	var rows []struct {
+		Count db.RawInt `json:"count"`
+	}
+	query := `SELECT COUNT(*)::int AS "count" FROM "tickets"
+WHERE "tier_id" = $1 AND ("status" = 'AVAILABLE' OR ("status" = 'RESERVED' AND "reserved_until" <= $2))`
+	if err := r.client.Prisma.QueryRaw(query, tierID, time.Now().UTC()).Exec(ctx, &rows); err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	return int(rows[0].Count), nil
+}
+
+func toDomainTier(tier *db.TicketTierModel) *domain.TicketTier {
+	kind := domain.TierReserved
+	for domainKind, dbKind := range tierKinds {
+		if dbKind == tier.Kind {
+			kind = domainKind
+		}
+	}
+	return &domain.TicketTier{
+		ID:        tier.ID,
+		EventID:   tier.EventID,
+		Name:      tier.Name,
+		Kind:      kind,
+		Price:     tier.Price,
+		Quantity:  tier.Quantity,
+		Sections:  tier.Sections,
+		CreatedAt: tier.CreatedAt,
+	}
+}
@@ -0,0 +1,92 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/flashtix/server/internal/domain"
+)
+
+// beginScript returns the record stored in KEYS[1], or stores ARGV[1] there
+// with a TTL of ARGV[2] milliseconds and returns nil when there is none
+const beginScript = `local existing = redis.call("GET", KEYS[1])
+if existing then
+	return existing
+end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+return false`
+
+// IdempotencyRepository implements domain.IdempotencyStore on top of Redis
+type IdempotencyRepository struct {
+	client redisClient
+}
+
+// NewIdempotencyRepository creates an idempotency store backed by the Upstash REST API
+func NewIdempotencyRepository(url, token string) *IdempotencyRepository {
+	return &IdempotencyRepository{
+		client: &UpstashRedisClient{
+			url:   url,
+			token: token,
+		},
+	}
+}
+
+// NewNativeIdempotencyRepository creates an idempotency store that talks
+// RESP over TCP to the Redis server at addr (host:port or redis:// URL)
+func NewNativeIdempotencyRepository(addr string) (*IdempotencyRepository, error) {
+	client, err := NewRESPClient(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &IdempotencyRepository{client: client}, nil
+}
+
+// Begin claims key for the request unless it is already claimed
+func (r *IdempotencyRepository) Begin(ctx context.Context, key, fingerprint string, ttl time.Duration) (*domain.IdempotencyRecord, bool, error) {
+	pending, err := json.Marshal(domain.IdempotencyRecord{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, false, err
+	}
+
+	result, err := r.client.eval(ctx, beginScript, []string{idempotencyKey(key)}, string(pending), fmt.Sprintf("%d", ttl.Milliseconds()))
+	if err != nil {
+		return nil, false, err
+	}
+	if result == nil {
+		return nil, true, nil
+	}
+
+	value, ok := result.(string)
+	if !ok {
+		return nil, false, fmt.Errorf("unexpected result type")
+	}
+	var record domain.IdempotencyRecord
+	if err := json.Unmarshal([]byte(value), &record); err != nil {
+		return nil, false, fmt.Errorf("failed to decode idempotency record: %w", err)
+	}
+	return &record, false, nil
+}
+
+// Complete stores the response of a finished request
+func (r *IdempotencyRepository) Complete(ctx context.Context, key string, record *domain.IdempotencyRecord, ttl time.Duration) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = r.client.eval(ctx, `return redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])`,
+		[]string{idempotencyKey(key)}, string(value), fmt.Sprintf("%d", ttl.Milliseconds()))
+	return err
+}
+
+// Abandon releases a claim so the request can be retried. The key is
+// chosen by the client, so it goes through eval like in Begin and Complete.
+func (r *IdempotencyRepository) Abandon(ctx context.Context, key string) error {
+	_, err := r.client.eval(ctx, `return redis.call("DEL", KEYS[1])`, []string{idempotencyKey(key)})
+	return err
+}
+
+func idempotencyKey(key string) string {
+	return "idempotency:" + key
+}
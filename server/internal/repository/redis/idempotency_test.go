@@ -0,0 +1,37 @@
+package redis
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestIdempotencyAbandonEscapesKey(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		w.Write([]byte(`{"result":1}`))
+	}))
+	defer server.Close()
+
+	repo := &IdempotencyRepository{client: &UpstashRedisClient{url: server.URL, token: "token"}}
+	key := "user123:a/b/seat_lock:event1:A1"
+	if err := repo.Abandon(context.Background(), key); err != nil {
+		t.Fatalf("Abandon failed: %v", err)
+	}
+
+	if len(paths) != 1 {
+		t.Fatalf("Expected one request, got %d", len(paths))
+	}
+	// eval/<script>/<key count>/<key>: the key stays a single argument
+	parts := strings.Split(strings.TrimPrefix(paths[0], "/"), "/")
+	if len(parts) != 4 || parts[0] != "eval" || parts[2] != "1" {
+		t.Fatalf("Expected the key as the only argument, got %s", paths[0])
+	}
+	if got, _ := url.PathUnescape(parts[3]); got != idempotencyKey(key) {
+		t.Errorf("Expected key %q, got %q", idempotencyKey(key), got)
+	}
+}
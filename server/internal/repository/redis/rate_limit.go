@@ -0,0 +1,81 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/flashtix/server/internal/domain"
+)
+
+// hitScript counts a hit in the fixed window KEYS[1] unless the sliding
+// window estimate, which weights the previous window KEYS[2] by ARGV[3]
+// (the share of it still inside the sliding window, in thousandths), has
+// reached ARGV[1]. Counters live for two windows of ARGV[2] milliseconds.
+// It returns {allowed, current, previous}.
+const hitScript = `local current = tonumber(redis.call("GET", KEYS[1]) or "0")
+local previous = tonumber(redis.call("GET", KEYS[2]) or "0")
+if previous * tonumber(ARGV[3]) / 1000 + current >= tonumber(ARGV[1]) then
+	return {0, current, previous}
+end
+current = redis.call("INCR", KEYS[1])
+if current == 1 then
+	redis.call("PEXPIRE", KEYS[1], 2 * tonumber(ARGV[2]))
+end
+return {1, current, previous}`
+
+// RateLimitRepository implements domain.RateLimiter on top of Redis
+type RateLimitRepository struct {
+	client redisClient
+}
+
+// NewRateLimitRepository creates a rate limiter backed by the Upstash REST API
+func NewRateLimitRepository(url, token string) *RateLimitRepository {
+	return &RateLimitRepository{
+		client: &UpstashRedisClient{
+			url:   url,
+			token: token,
+		},
+	}
+}
+
+// NewNativeRateLimitRepository creates a rate limiter that talks RESP over
+// TCP to the Redis server at addr (host:port or redis:// URL)
+func NewNativeRateLimitRepository(addr string) (*RateLimitRepository, error) {
+	client, err := NewRESPClient(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &RateLimitRepository{client: client}, nil
+}
+
+// Hit counts a request for key unless that would exceed limit within window
+func (r *RateLimitRepository) Hit(ctx context.Context, key string, limit int, window time.Duration) (domain.RateLimitWindow, error) {
+	now := time.Now()
+	index := now.UnixMilli() / window.Milliseconds()
+	elapsed := time.Duration(now.UnixMilli()%window.Milliseconds()) * time.Millisecond
+	overlap := 1000 - elapsed.Milliseconds()*1000/window.Milliseconds()
+
+	keys := []string{
+		fmt.Sprintf("rate_limit:%s:%d", key, index),
+		fmt.Sprintf("rate_limit:%s:%d", key, index-1),
+	}
+	result, err := r.client.eval(ctx, hitScript, keys,
+		fmt.Sprintf("%d", limit), fmt.Sprintf("%d", window.Milliseconds()), fmt.Sprintf("%d", overlap))
+	if err != nil {
+		return domain.RateLimitWindow{}, err
+	}
+
+	reply, ok := result.([]interface{})
+	if !ok || len(reply) != 3 {
+		return domain.RateLimitWindow{}, fmt.Errorf("unexpected result type")
+	}
+	current, _ := toInt64(reply[1])
+	previous, _ := toInt64(reply[2])
+	return domain.RateLimitWindow{
+		Allowed:  isOne(reply[0]),
+		Current:  int(current),
+		Previous: int(previous),
+		Elapsed:  elapsed,
+	}, nil
+}
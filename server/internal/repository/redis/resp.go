@@ -0,0 +1,243 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RESPClient is a minimal Redis client speaking RESP2 over TCP. It keeps a
+// small pool of connections so concurrent requests do not serialize.
+type RESPClient struct {
+	addr     string
+	password string
+	db       int
+	pool     chan *respConn
+}
+
+type respConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// redisError is an error reply sent by the server
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis error: " + string(e)
+}
+
+const respPoolSize = 16
+
+// NewRESPClient parses addr, either host:port or redis://[:password@]host:port[/db]
+func NewRESPClient(addr string) (*RESPClient, error) {
+	client := &RESPClient{
+		addr: addr,
+		pool: make(chan *respConn, respPoolSize),
+	}
+
+	if strings.HasPrefix(addr, "redis://") {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis url: %w", err)
+		}
+		client.addr = u.Host
+		if password, ok := u.User.Password(); ok {
+			client.password = password
+		}
+		if path := strings.TrimPrefix(u.Path, "/"); path != "" {
+			db, err := strconv.Atoi(path)
+			if err != nil {
+				return nil, fmt.Errorf("invalid redis database %q", path)
+			}
+			client.db = db
+		}
+	}
+
+	return client, nil
+}
+
+// do sends a single command and returns its decoded reply: string, int64,
+// []interface{} or nil for a null reply
+func (c *RESPClient) do(ctx context.Context, args ...string) (interface{}, error) {
+	conn, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := conn.roundTrip(ctx, args)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		// The stream may be out of sync after a network error
+		conn.conn.Close()
+		return nil, err
+	}
+
+	c.release(conn)
+	return result, err
+}
+
+func (c *RESPClient) acquire(ctx context.Context) (*respConn, error) {
+	select {
+	case conn := <-c.pool:
+		return conn, nil
+	default:
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	netConn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	conn := &respConn{conn: netConn, reader: bufio.NewReader(netConn)}
+
+	if c.password != "" {
+		if _, err := conn.roundTrip(ctx, []string{"AUTH", c.password}); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+	if c.db != 0 {
+		if _, err := conn.roundTrip(ctx, []string{"SELECT", strconv.Itoa(c.db)}); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+func (c *RESPClient) release(conn *respConn) {
+	select {
+	case c.pool <- conn:
+	default:
+		conn.conn.Close()
+	}
+}
+
+func (rc *respConn) roundTrip(ctx context.Context, args []string) (interface{}, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(10 * time.Second)
+	}
+	rc.conn.SetDeadline(deadline)
+
+	if _, err := rc.conn.Write(encodeCommand(args)); err != nil {
+		return nil, fmt.Errorf("failed to write command: %w", err)
+	}
+	return readReply(rc.reader)
+}
+
+// encodeCommand encodes args as a RESP array of bulk strings
+func encodeCommand(args []string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return []byte(b.String())
+}
+
+// readReply decodes one RESP2 reply
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reply: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty redis reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid bulk length: %w", err)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, fmt.Errorf("failed to read bulk string: %w", err)
+		}
+		return string(buf[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid array length: %w", err)
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			// Nested error replies (e.g. from EXEC) are kept as values
+			item, err := readReply(r)
+			var replyErr redisError
+			if err != nil && !errors.As(err, &replyErr) {
+				return nil, err
+			}
+			if err != nil {
+				item = replyErr
+			}
+			items[i] = item
+		}
+		return items, nil
+	}
+
+	return nil, fmt.Errorf("unexpected redis reply %q", line)
+}
+
+func (c *RESPClient) setNX(ctx context.Context, key, value string, expiration time.Duration) (bool, error) {
+	args := []string{"SET", key, value, "NX"}
+	if expiration > 0 {
+		args = append(args, "EX", strconv.Itoa(int(expiration.Seconds())))
+	}
+	result, err := c.do(ctx, args...)
+	if err != nil {
+		return false, err
+	}
+	return result != nil, nil
+}
+
+func (c *RESPClient) get(ctx context.Context, key string) (string, error) {
+	result, err := c.do(ctx, "GET", key)
+	if err != nil {
+		return "", err
+	}
+	if result == nil {
+		return "", fmt.Errorf("key not found")
+	}
+	value, ok := result.(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected result type")
+	}
+	return value, nil
+}
+
+func (c *RESPClient) del(ctx context.Context, key string) error {
+	_, err := c.do(ctx, "DEL", key)
+	return err
+}
+
+func (c *RESPClient) eval(ctx context.Context, script string, keys []string, args ...string) (interface{}, error) {
+	cmd := []string{"EVAL", script, strconv.Itoa(len(keys))}
+	cmd = append(cmd, keys...)
+	cmd = append(cmd, args...)
+	return c.do(ctx, cmd...)
+}
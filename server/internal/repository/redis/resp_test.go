@@ -0,0 +1,44 @@
+package redis
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodeCommand(t *testing.T) {
+	got := string(encodeCommand([]string{"SET", "seat_lock:e1:A1", "user123", "NX"}))
+	want := "*4\r\n$3\r\nSET\r\n$15\r\nseat_lock:e1:A1\r\n$7\r\nuser123\r\n$2\r\nNX\r\n"
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestReadReply(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  interface{}
+		err   bool
+	}{
+		{"simple string", "+OK\r\n", "OK", false},
+		{"integer", ":42\r\n", int64(42), false},
+		{"bulk string", "$7\r\nuser123\r\n", "user123", false},
+		{"null bulk", "$-1\r\n", nil, false},
+		{"array", "*2\r\n:1\r\n$1\r\na\r\n", []interface{}{int64(1), "a"}, false},
+		{"error", "-ERR wrong type\r\n", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readReply(bufio.NewReader(strings.NewReader(tt.input)))
+			if (err != nil) != tt.err {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %#v, got %#v", tt.want, got)
+			}
+		})
+	}
+}
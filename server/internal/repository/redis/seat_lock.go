@@ -6,9 +6,237 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
+
+	"github.com/flashtix/server/internal/domain"
 )
 
+// touchHoldsLua defines touch_holds, which makes the per-user holds sorted
+// set expire together with its longest-lived member. Members are seats, or
+// "claim:<claim>:<n>" for claimed tier tickets, and scores the unix time in
+// milliseconds their lock or claim expires.
+const touchHoldsLua = `local function touch_holds(key)
+	local last = redis.call("ZRANGE", key, -1, -1, "WITHSCORES")
+	if last[2] then
+		redis.call("PEXPIREAT", key, last[2])
+	end
+end
+`
+
+// lockScript takes the lock in KEYS[1] for ARGV[1] with a TTL of ARGV[2]
+// seconds and returns a fencing token from the per-seat counter in KEYS[2].
+// The current holder gets its existing token back with the TTL refreshed,
+// and the seat's entry ARGV[3] in the holds set KEYS[3], if any, moved to
+// expire at ARGV[4]; anyone else gets 0 while the lock is held.
+const lockScript = touchHoldsLua + `local holder = redis.call("GET", KEYS[1])
+if holder == ARGV[1] then
+	redis.call("EXPIRE", KEYS[1], ARGV[2])
+	if redis.call("ZADD", KEYS[3], "XX", "CH", ARGV[4], ARGV[3]) == 1 then
+		touch_holds(KEYS[3])
+	end
+	local token = redis.call("GET", KEYS[2])
+	if not token then
+		token = redis.call("INCR", KEYS[2])
+	end
+	return tonumber(token)
+end
+if holder then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[1], "EX", ARGV[2])
+return redis.call("INCR", KEYS[2])`
+
+// lockManyScript locks every seat in KEYS[1..n] for ARGV[1] with a TTL of
+// ARGV[2] seconds, using the fencing counters in KEYS[n+1..2n], or none of
+// them. The seats ARGV[5..] are recorded in the user's holds set KEYS[2n+1];
+// with ARGV[3] > 0 the user may not end up holding more than ARGV[3] seats
+// at time ARGV[4] (unix milliseconds). It returns {1, token...} on success,
+// {0, index...} with the 1-based positions of seats held by someone else,
+// or {-1} when the limit would be exceeded.
+const lockManyScript = touchHoldsLua + `local n = (#KEYS - 1) / 2
+local holds = KEYS[#KEYS]
+local now = tonumber(ARGV[4])
+local conflicts = {0}
+for i = 1, n do
+	local holder = redis.call("GET", KEYS[i])
+	if holder and holder ~= ARGV[1] then
+		table.insert(conflicts, i)
+	end
+end
+if #conflicts > 1 then
+	return conflicts
+end
+redis.call("ZREMRANGEBYSCORE", holds, "-inf", now)
+local max = tonumber(ARGV[3])
+if max > 0 then
+	local held = redis.call("ZCARD", holds)
+	for i = 1, n do
+		if not redis.call("ZSCORE", holds, ARGV[4 + i]) then
+			held = held + 1
+		end
+	end
+	if held > max then
+		return {-1}
+	end
+end
+local expires = now + tonumber(ARGV[2]) * 1000
+local tokens = {1}
+for i = 1, n do
+	local token
+	if redis.call("GET", KEYS[i]) then
+		redis.call("EXPIRE", KEYS[i], ARGV[2])
+		token = redis.call("GET", KEYS[n + i])
+		if not token then
+			token = redis.call("INCR", KEYS[n + i])
+		end
+	else
+		redis.call("SET", KEYS[i], ARGV[1], "EX", ARGV[2])
+		token = redis.call("INCR", KEYS[n + i])
+	end
+	redis.call("ZADD", holds, expires, ARGV[4 + i])
+	table.insert(tokens, tonumber(token))
+end
+touch_holds(holds)
+return tokens`
+
+// unlockScript deletes the lock only when it is still held by ARGV[1] and
+// drops seat ARGV[2] from the holds set KEYS[2]
+const unlockScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("ZREM", KEYS[2], ARGV[2])
+	return redis.call("DEL", KEYS[1])
+end
+return 0`
+
+// extendScript resets the lock TTL to ARGV[2] seconds only when it is still
+// held by ARGV[1], moving seat ARGV[3] in the holds set KEYS[2] to expire at
+// ARGV[4]
+const extendScript = touchHoldsLua + `if redis.call("GET", KEYS[1]) == ARGV[1] then
+	if redis.call("ZADD", KEYS[2], "XX", "CH", ARGV[4], ARGV[3]) == 1 then
+		touch_holds(KEYS[2])
+	end
+	return redis.call("EXPIRE", KEYS[1], ARGV[2])
+end
+return 0`
+
+// holdersScript returns the value of every key in KEYS, the lock holder, or
+// nil for keys that do not exist
+const holdersScript = `local holders = {}
+for i = 1, #KEYS do
+	holders[i] = redis.call("GET", KEYS[i])
+end
+return holders`
+
+// reclaimLua defines reclaim, which gives the tickets of claims on a tier
+// that lapsed by now back to its stock. A tier's keys are its stock counter,
+// a sorted set of claims scored by the unix time in milliseconds they
+// expire, and a hash of each claim's quantity.
+const reclaimLua = `local function reclaim(stock, claims, quantities, now)
+	local lapsed = redis.call("ZRANGEBYSCORE", claims, "-inf", now)
+	for _, claim in ipairs(lapsed) do
+		local quantity = redis.call("HGET", quantities, claim)
+		if quantity then
+			redis.call("INCRBY", stock, quantity)
+			redis.call("HDEL", quantities, claim)
+		end
+	end
+	redis.call("ZREMRANGEBYSCORE", claims, "-inf", now)
+end
+`
+
+// claimTierScript takes ARGV[2] tickets off the stock KEYS[1] for claim
+// ARGV[1], recording it in KEYS[2] and KEYS[3] to expire at ARGV[4], after
+// reclaiming what lapsed by ARGV[3]. Each ticket is also entered in the
+// user's holds set KEYS[4], which with ARGV[5] > 0 may not grow past ARGV[5]
+// seats and tickets. It returns 1 on success, 0 when the stock is too low,
+// -1 when the limit would be exceeded and -2 when the stock is not counted.
+const claimTierScript = touchHoldsLua + reclaimLua + `if redis.call("EXISTS", KEYS[1]) == 0 then
+	return -2
+end
+local now = tonumber(ARGV[3])
+reclaim(KEYS[1], KEYS[2], KEYS[3], now)
+local quantity = tonumber(ARGV[2])
+redis.call("ZREMRANGEBYSCORE", KEYS[4], "-inf", now)
+local max = tonumber(ARGV[5])
+if max > 0 and redis.call("ZCARD", KEYS[4]) + quantity > max then
+	return -1
+end
+if tonumber(redis.call("GET", KEYS[1])) < quantity then
+	return 0
+end
+redis.call("DECRBY", KEYS[1], quantity)
+redis.call("ZADD", KEYS[2], ARGV[4], ARGV[1])
+redis.call("HSET", KEYS[3], ARGV[1], quantity)
+for i = 1, quantity do
+	redis.call("ZADD", KEYS[4], ARGV[4], "claim:" .. ARGV[1] .. ":" .. i)
+end
+touch_holds(KEYS[4])
+return 1`
+
+// extendClaimScript moves claim ARGV[1] and its tickets in the holds set
+// KEYS[4] to expire at ARGV[3] unless it lapsed by ARGV[2]
+const extendClaimScript = touchHoldsLua + `local expires = redis.call("ZSCORE", KEYS[2], ARGV[1])
+if not expires or tonumber(expires) <= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call("ZADD", KEYS[2], ARGV[3], ARGV[1])
+local quantity = tonumber(redis.call("HGET", KEYS[3], ARGV[1]) or "0")
+for i = 1, quantity do
+	redis.call("ZADD", KEYS[4], "XX", ARGV[3], "claim:" .. ARGV[1] .. ":" .. i)
+end
+touch_holds(KEYS[4])
+return 1`
+
+// settleClaimScript ends claim ARGV[1], dropping its tickets from the holds
+// set KEYS[4] and returning all but ARGV[2] of them to the stock. Lapsed
+// claims are reclaimed by ARGV[3] first, so a claim is never returned twice.
+const settleClaimScript = reclaimLua + `if redis.call("EXISTS", KEYS[1]) == 1 then
+	reclaim(KEYS[1], KEYS[2], KEYS[3], tonumber(ARGV[3]))
+end
+local quantity = redis.call("HGET", KEYS[3], ARGV[1])
+if not quantity then
+	return 0
+end
+quantity = tonumber(quantity)
+redis.call("HDEL", KEYS[3], ARGV[1])
+redis.call("ZREM", KEYS[2], ARGV[1])
+for i = 1, quantity do
+	redis.call("ZREM", KEYS[4], "claim:" .. ARGV[1] .. ":" .. i)
+end
+local returned = quantity - tonumber(ARGV[2])
+if returned > 0 and redis.call("EXISTS", KEYS[1]) == 1 then
+	redis.call("INCRBY", KEYS[1], returned)
+end
+return 1`
+
+// tierStockScript returns the stock KEYS[1] after reclaiming what lapsed by
+// ARGV[1], or -1 when it is not counted
+const tierStockScript = reclaimLua + `if redis.call("EXISTS", KEYS[1]) == 0 then
+	return -1
+end
+reclaim(KEYS[1], KEYS[2], KEYS[3], tonumber(ARGV[1]))
+return tonumber(redis.call("GET", KEYS[1]))`
+
+// returnStockScript adds ARGV[1] to the stock KEYS[1] if it is counted
+const returnStockScript = `if redis.call("EXISTS", KEYS[1]) == 1 then
+	return redis.call("INCRBY", KEYS[1], ARGV[1])
+end
+return 0`
+
+// lockHoldersBatch bounds the keys per script call so Upstash request paths
+// stay a reasonable length for large venues
+const lockHoldersBatch = 200
+
+// redisClient is the command set the seat lock repository needs, implemented
+// by both the Upstash REST client and the native RESP client
+type redisClient interface {
+	setNX(ctx context.Context, key, value string, expiration time.Duration) (bool, error)
+	get(ctx context.Context, key string) (string, error)
+	del(ctx context.Context, key string) error
+	eval(ctx context.Context, script string, keys []string, args ...string) (interface{}, error)
+}
+
 type UpstashRedisClient struct {
 	url   string
 	token string
@@ -19,10 +247,12 @@ type UpstashResponse struct {
 	Error  string      `json:"error,omitempty"`
 }
 
+// SeatLockRepository implements domain.SeatLocker on top of Redis
 type SeatLockRepository struct {
-	client *UpstashRedisClient
+	client redisClient
 }
 
+// NewSeatLockRepository creates a seat lock repository backed by the Upstash REST API
 func NewSeatLockRepository(url, token string) *SeatLockRepository {
 	return &SeatLockRepository{
 		client: &UpstashRedisClient{
@@ -32,16 +262,94 @@ func NewSeatLockRepository(url, token string) *SeatLockRepository {
 	}
 }
 
-// LockSeat locks a seat for a user with expiration
-func (r *SeatLockRepository) LockSeat(ctx context.Context, eventID, seat string, userID string, expiration time.Duration) error {
+// NewNativeSeatLockRepository creates a seat lock repository that talks RESP
+// over TCP to the Redis server at addr (host:port or redis:// URL)
+func NewNativeSeatLockRepository(addr string) (*SeatLockRepository, error) {
+	client, err := NewRESPClient(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &SeatLockRepository{client: client}, nil
+}
+
+// LockSeat atomically locks a seat for a user with expiration and returns the
+// lock's fencing token. It returns false without touching the existing lock
+// when the seat is held by someone else.
+func (r *SeatLockRepository) LockSeat(ctx context.Context, eventID, seat string, userID string, expiration time.Duration) (int64, bool, error) {
 	key := fmt.Sprintf("seat_lock:%s:%s", eventID, seat)
-	return r.client.set(ctx, key, userID, expiration)
+	fenceKey := fmt.Sprintf("seat_fence:%s:%s", eventID, seat)
+	seconds := int(expiration.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	expiresAt := time.Now().Add(time.Duration(seconds) * time.Second).UnixMilli()
+	result, err := r.client.eval(ctx, lockScript, []string{key, fenceKey, holdsKey(eventID, userID)},
+		userID, fmt.Sprintf("%d", seconds), seat, fmt.Sprintf("%d", expiresAt))
+	if err != nil {
+		return 0, false, err
+	}
+	token, ok := toInt64(result)
+	if !ok {
+		return 0, false, fmt.Errorf("unexpected result type")
+	}
+	return token, token > 0, nil
 }
 
-// UnlockSeat unlocks a seat
-func (r *SeatLockRepository) UnlockSeat(ctx context.Context, eventID, seat string) error {
+// LockSeats locks all seats for a user in one atomic step or none of them.
+// When any seat is held by someone else it returns those seats instead of
+// fencing tokens. The seats are tracked in a per-user sorted set so the
+// maxHeld limit is checked in the same step.
+func (r *SeatLockRepository) LockSeats(ctx context.Context, eventID string, seats []string, userID string, expiration time.Duration, maxHeld int) ([]int64, []string, error) {
+	keys := make([]string, 2*len(seats)+1)
+	for i, seat := range seats {
+		keys[i] = fmt.Sprintf("seat_lock:%s:%s", eventID, seat)
+		keys[len(seats)+i] = fmt.Sprintf("seat_fence:%s:%s", eventID, seat)
+	}
+	keys[2*len(seats)] = holdsKey(eventID, userID)
+	seconds := int(expiration.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	args := []string{userID, fmt.Sprintf("%d", seconds), fmt.Sprintf("%d", maxHeld), fmt.Sprintf("%d", time.Now().UnixMilli())}
+	args = append(args, seats...)
+	result, err := r.client.eval(ctx, lockManyScript, keys, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	reply, ok := result.([]interface{})
+	if !ok || len(reply) == 0 {
+		return nil, nil, fmt.Errorf("unexpected result type")
+	}
+	if status, _ := toInt64(reply[0]); status == -1 {
+		return nil, nil, domain.ErrHoldLimit
+	}
+	values := make([]int64, len(reply)-1)
+	for i, item := range reply[1:] {
+		if values[i], ok = toInt64(item); !ok {
+			return nil, nil, fmt.Errorf("unexpected result type")
+		}
+	}
+
+	if status, _ := toInt64(reply[0]); status == 1 {
+		return values, nil, nil
+	}
+	conflicts := make([]string, len(values))
+	for i, index := range values {
+		conflicts[i] = seats[index-1]
+	}
+	return nil, conflicts, nil
+}
+
+// UnlockSeat unlocks a seat if it is held by userID. It returns false when
+// the lock is missing or owned by someone else.
+func (r *SeatLockRepository) UnlockSeat(ctx context.Context, eventID, seat string, userID string) (bool, error) {
 	key := fmt.Sprintf("seat_lock:%s:%s", eventID, seat)
-	return r.client.del(ctx, key)
+	result, err := r.client.eval(ctx, unlockScript, []string{key, holdsKey(eventID, userID)}, userID, seat)
+	if err != nil {
+		return false, err
+	}
+	return isOne(result), nil
 }
 
 // IsSeatLocked checks if a seat is locked and by whom
@@ -54,24 +362,170 @@ func (r *SeatLockRepository) IsSeatLocked(ctx context.Context, eventID, seat str
 	return userID, err
 }
 
-// ExtendLock extends the lock expiration
-func (r *SeatLockRepository) ExtendLock(ctx context.Context, eventID, seat string, expiration time.Duration) error {
+// ExtendLock extends the lock expiration if it is held by userID. It returns
+// false when the lock is missing or owned by someone else.
+func (r *SeatLockRepository) ExtendLock(ctx context.Context, eventID, seat string, userID string, expiration time.Duration) (bool, error) {
 	key := fmt.Sprintf("seat_lock:%s:%s", eventID, seat)
-	return r.client.expire(ctx, key, expiration)
+	seconds := fmt.Sprintf("%d", int(expiration.Seconds()))
+	expiresAt := fmt.Sprintf("%d", time.Now().Add(expiration).UnixMilli())
+	result, err := r.client.eval(ctx, extendScript, []string{key, holdsKey(eventID, userID)}, userID, seconds, seat, expiresAt)
+	if err != nil {
+		return false, err
+	}
+	return isOne(result), nil
+}
+
+// LockHolders returns for each seat the user holding its lock, or "", checking
+// up to lockHoldersBatch seats per round trip
+func (r *SeatLockRepository) LockHolders(ctx context.Context, eventID string, seats []string) ([]string, error) {
+	holders := make([]string, 0, len(seats))
+	for start := 0; start < len(seats); start += lockHoldersBatch {
+		batch := seats[start:min(start+lockHoldersBatch, len(seats))]
+		keys := make([]string, len(batch))
+		for i, seat := range batch {
+			keys[i] = fmt.Sprintf("seat_lock:%s:%s", eventID, seat)
+		}
+
+		result, err := r.client.eval(ctx, holdersScript, keys)
+		if err != nil {
+			return nil, err
+		}
+		reply, ok := result.([]interface{})
+		if !ok || len(reply) != len(batch) {
+			return nil, fmt.Errorf("unexpected result type")
+		}
+		for _, item := range reply {
+			holder, _ := item.(string)
+			holders = append(holders, holder)
+		}
+	}
+	return holders, nil
+}
+
+// SeedTierStock starts counting the tier's stock at available unless it is
+// counted already
+func (r *SeatLockRepository) SeedTierStock(ctx context.Context, eventID, tierID string, available int) error {
+	_, err := r.client.setNX(ctx, tierKeys(eventID, tierID)[0], fmt.Sprintf("%d", available), 0)
+	return err
+}
+
+// TierStock returns the tickets of the tier left to claim, giving back
+// those of lapsed claims first
+func (r *SeatLockRepository) TierStock(ctx context.Context, eventID, tierID string) (int, bool, error) {
+	result, err := r.client.eval(ctx, tierStockScript, tierKeys(eventID, tierID), fmt.Sprintf("%d", time.Now().UnixMilli()))
+	if err != nil {
+		return 0, false, err
+	}
+	available, ok := toInt64(result)
+	if !ok {
+		return 0, false, fmt.Errorf("unexpected result type")
+	}
+	if available < 0 {
+		return 0, false, nil
+	}
+	return int(available), true, nil
+}
+
+// ClaimTierStock takes quantity tickets off the tier's stock in one atomic
+// step, after giving back those of lapsed claims, so concurrent buyers can
+// never claim more tickets than the tier has
+func (r *SeatLockRepository) ClaimTierStock(ctx context.Context, eventID, tierID, claimID, userID string, quantity int, expiration time.Duration, maxHeld int) error {
+	now := time.Now()
+	keys := append(tierKeys(eventID, tierID), holdsKey(eventID, userID))
+	result, err := r.client.eval(ctx, claimTierScript, keys, claimID, fmt.Sprintf("%d", quantity),
+		fmt.Sprintf("%d", now.UnixMilli()), fmt.Sprintf("%d", now.Add(expiration).UnixMilli()), fmt.Sprintf("%d", maxHeld))
+	if err != nil {
+		return err
+	}
+	status, ok := toInt64(result)
+	if !ok {
+		return fmt.Errorf("unexpected result type")
+	}
+	switch status {
+	case 0:
+		return domain.ErrSoldOut
+	case -1:
+		return domain.ErrHoldLimit
+	case -2:
+		return domain.ErrTierStockUnknown
+	}
+	return nil
+}
+
+// ExtendTierClaim moves an unexpired claim's expiry
+func (r *SeatLockRepository) ExtendTierClaim(ctx context.Context, eventID, tierID, claimID, userID string, expiration time.Duration) (bool, error) {
+	now := time.Now()
+	keys := append(tierKeys(eventID, tierID), holdsKey(eventID, userID))
+	result, err := r.client.eval(ctx, extendClaimScript, keys, claimID,
+		fmt.Sprintf("%d", now.UnixMilli()), fmt.Sprintf("%d", now.Add(expiration).UnixMilli()))
+	if err != nil {
+		return false, err
+	}
+	return isOne(result), nil
+}
+
+// SettleTierClaim ends the claim and returns its unsold tickets to the stock
+func (r *SeatLockRepository) SettleTierClaim(ctx context.Context, eventID, tierID, claimID, userID string, sold int) (bool, error) {
+	keys := append(tierKeys(eventID, tierID), holdsKey(eventID, userID))
+	result, err := r.client.eval(ctx, settleClaimScript, keys, claimID, fmt.Sprintf("%d", sold), fmt.Sprintf("%d", time.Now().UnixMilli()))
+	if err != nil {
+		return false, err
+	}
+	return isOne(result), nil
+}
+
+// ReturnTierStock puts tickets back on a counted tier's stock
+func (r *SeatLockRepository) ReturnTierStock(ctx context.Context, eventID, tierID string, quantity int) error {
+	_, err := r.client.eval(ctx, returnStockScript, tierKeys(eventID, tierID)[:1], fmt.Sprintf("%d", quantity))
+	return err
+}
+
+// tierKeys are the stock counter, claims sorted set and claim quantities
+// hash of a general-admission tier
+func tierKeys(eventID, tierID string) []string {
+	return []string{
+		fmt.Sprintf("tier_stock:%s:%s", eventID, tierID),
+		fmt.Sprintf("tier_claims:%s:%s", eventID, tierID),
+		fmt.Sprintf("tier_claim_quantities:%s:%s", eventID, tierID),
+	}
+}
+
+// holdsKey is the sorted set of seats and claimed tier tickets the user
+// holds for the event
+func holdsKey(eventID, userID string) string {
+	return fmt.Sprintf("seat_holds:%s:%s", eventID, userID)
+}
+
+// isOne reports whether a script reply is the integer 1
+func isOne(result interface{}) bool {
+	n, ok := toInt64(result)
+	return ok && n == 1
+}
+
+// toInt64 converts an integer reply. Upstash decodes integers from JSON as
+// float64 while RESP replies carry int64.
+func toInt64(result interface{}) (int64, bool) {
+	switch n := result.(type) {
+	case float64:
+		return int64(n), true
+	case int64:
+		return n, true
+	}
+	return 0, false
 }
 
 // UpstashRedisClient methods
 func (c *UpstashRedisClient) set(ctx context.Context, key, value string, expiration time.Duration) error {
-	var url string
+	var endpoint string
 	if expiration > 0 {
 		// SET key value EX seconds
-		url = fmt.Sprintf("%s/set/%s/%s/ex/%d", c.url, key, value, int(expiration.Seconds()))
+		endpoint = fmt.Sprintf("%s/set/%s/%s/ex/%d", c.url, url.PathEscape(key), url.PathEscape(value), int(expiration.Seconds()))
 	} else {
 		// SET key value
-		url = fmt.Sprintf("%s/set/%s/%s", c.url, key, value)
+		endpoint = fmt.Sprintf("%s/set/%s/%s", c.url, url.PathEscape(key), url.PathEscape(value))
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -102,10 +556,53 @@ func (c *UpstashRedisClient) set(ctx context.Context, key, value string, expirat
 	return nil
 }
 
+// setNX issues SET key value NX [EX seconds] and reports whether the key was set
+func (c *UpstashRedisClient) setNX(ctx context.Context, key, value string, expiration time.Duration) (bool, error) {
+	var endpoint string
+	if expiration > 0 {
+		// SET key value NX EX seconds
+		endpoint = fmt.Sprintf("%s/set/%s/%s/nx/ex/%d", c.url, url.PathEscape(key), url.PathEscape(value), int(expiration.Seconds()))
+	} else {
+		// SET key value NX
+		endpoint = fmt.Sprintf("%s/set/%s/%s/nx", c.url, url.PathEscape(key), url.PathEscape(value))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return false, fmt.Errorf("upstash request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var upstashResp UpstashResponse
+	if err := json.NewDecoder(resp.Body).Decode(&upstashResp); err != nil {
+		return false, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if upstashResp.Error != "" {
+		return false, fmt.Errorf("upstash error: %s", upstashResp.Error)
+	}
+
+	// SET NX replies "OK" when the key was set and null when it already exists
+	return upstashResp.Result != nil, nil
+}
+
 func (c *UpstashRedisClient) get(ctx context.Context, key string) (string, error) {
-	url := fmt.Sprintf("%s/get/%s", c.url, key)
+	endpoint := fmt.Sprintf("%s/get/%s", c.url, url.PathEscape(key))
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
@@ -142,9 +639,9 @@ func (c *UpstashRedisClient) get(ctx context.Context, key string) (string, error
 }
 
 func (c *UpstashRedisClient) del(ctx context.Context, key string) error {
-	url := fmt.Sprintf("%s/del/%s", c.url, key)
+	endpoint := fmt.Sprintf("%s/del/%s", c.url, url.PathEscape(key))
 
-	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -175,12 +672,21 @@ func (c *UpstashRedisClient) del(ctx context.Context, key string) error {
 	return nil
 }
 
-func (c *UpstashRedisClient) expire(ctx context.Context, key string, expiration time.Duration) error {
-	url := fmt.Sprintf("%s/expire/%s/%d", c.url, key, int(expiration.Seconds()))
+// eval runs a Lua script through the /eval endpoint so the script's reads
+// and writes happen atomically on the server
+func (c *UpstashRedisClient) eval(ctx context.Context, script string, keys []string, args ...string) (interface{}, error) {
+	parts := []string{url.PathEscape(script), fmt.Sprintf("%d", len(keys))}
+	for _, key := range keys {
+		parts = append(parts, url.PathEscape(key))
+	}
+	for _, arg := range args {
+		parts = append(parts, url.PathEscape(arg))
+	}
+	endpoint := fmt.Sprintf("%s/eval/%s", c.url, strings.Join(parts, "/"))
 
-	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
@@ -188,23 +694,23 @@ func (c *UpstashRedisClient) expire(ctx context.Context, key string, expiration
 	client := &http.Client{Timeout: 10 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to make request: %w", err)
+		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("upstash request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("upstash request failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
 	var upstashResp UpstashResponse
 	if err := json.NewDecoder(resp.Body).Decode(&upstashResp); err != nil {
-		return fmt.Errorf("failed to decode response: %w", err)
+		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
 	if upstashResp.Error != "" {
-		return fmt.Errorf("upstash error: %s", upstashResp.Error)
+		return nil, fmt.Errorf("upstash error: %s", upstashResp.Error)
 	}
 
-	return nil
+	return upstashResp.Result, nil
 }
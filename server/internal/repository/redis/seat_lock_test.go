@@ -91,10 +91,33 @@ func TestSeatLockRepository(t *testing.T) {
 
 	// Test LockSeat
 	t.Run("LockSeat", func(t *testing.T) {
-		err := repo.LockSeat(ctx, eventID, seat, userID, 30*time.Second)
+		token, acquired, err := repo.LockSeat(ctx, eventID, seat, userID, 30*time.Second)
 		if err != nil {
 			t.Fatalf("Failed to lock seat: %v", err)
 		}
+		if !acquired {
+			t.Fatalf("Expected lock to be acquired")
+		}
+
+		// Re-locking as the holder returns the same fencing token
+		again, acquired, err := repo.LockSeat(ctx, eventID, seat, userID, 30*time.Second)
+		if err != nil {
+			t.Fatalf("Failed to lock seat: %v", err)
+		}
+		if !acquired || again != token {
+			t.Errorf("Expected token %d for holder, got %d (acquired %v)", token, again, acquired)
+		}
+	})
+
+	// Test LockSeat on a held seat
+	t.Run("LockSeat contended", func(t *testing.T) {
+		_, acquired, err := repo.LockSeat(ctx, eventID, seat, "user456", 30*time.Second)
+		if err != nil {
+			t.Fatalf("Failed to lock seat: %v", err)
+		}
+		if acquired {
+			t.Errorf("Expected lock held by %s to be kept", userID)
+		}
 	})
 
 	// Test IsSeatLocked
@@ -108,12 +131,59 @@ func TestSeatLockRepository(t *testing.T) {
 		}
 	})
 
+	// Test LockHolders
+	t.Run("LockHolders", func(t *testing.T) {
+		holders, err := repo.LockHolders(ctx, eventID, []string{seat, "Z99"})
+		if err != nil {
+			t.Fatalf("Failed to check seat locks: %v", err)
+		}
+		if len(holders) != 2 || holders[0] != userID || holders[1] != "" {
+			t.Errorf("Expected only %s to be locked by %s, got %v", seat, userID, holders)
+		}
+	})
+
+	// Test ExtendLock by another user
+	t.Run("ExtendLock not owner", func(t *testing.T) {
+		extended, err := repo.ExtendLock(ctx, eventID, seat, "user456", 60*time.Second)
+		if err != nil {
+			t.Fatalf("Failed to extend lock: %v", err)
+		}
+		if extended {
+			t.Errorf("Expected lock held by %s not to be extended", userID)
+		}
+	})
+
+	// Test ExtendLock
+	t.Run("ExtendLock", func(t *testing.T) {
+		extended, err := repo.ExtendLock(ctx, eventID, seat, userID, 60*time.Second)
+		if err != nil {
+			t.Fatalf("Failed to extend lock: %v", err)
+		}
+		if !extended {
+			t.Errorf("Expected lock to be extended")
+		}
+	})
+
+	// Test UnlockSeat by another user
+	t.Run("UnlockSeat not owner", func(t *testing.T) {
+		unlocked, err := repo.UnlockSeat(ctx, eventID, seat, "user456")
+		if err != nil {
+			t.Fatalf("Failed to unlock seat: %v", err)
+		}
+		if unlocked {
+			t.Errorf("Expected lock held by %s to be kept", userID)
+		}
+	})
+
 	// Test UnlockSeat
 	t.Run("UnlockSeat", func(t *testing.T) {
-		err := repo.UnlockSeat(ctx, eventID, seat)
+		unlocked, err := repo.UnlockSeat(ctx, eventID, seat, userID)
 		if err != nil {
 			t.Fatalf("Failed to unlock seat: %v", err)
 		}
+		if !unlocked {
+			t.Errorf("Expected seat to be unlocked")
+		}
 	})
 
 	// Verify seat is unlocked
@@ -0,0 +1,139 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+)
+
+// waitingRoomEventsKey is the set of events with buyers still waiting
+const waitingRoomEventsKey = "waiting_room:events"
+
+// joinScript returns the queue number of ARGV[1] from the hash in KEYS[2],
+// handing out the next number from the counter in KEYS[1] on first join and
+// registering event ARGV[2] in the set KEYS[3]
+const joinScript = `local number = redis.call("HGET", KEYS[2], ARGV[1])
+if number then
+	return tonumber(number)
+end
+number = redis.call("INCR", KEYS[1])
+redis.call("HSET", KEYS[2], ARGV[1], number)
+redis.call("SADD", KEYS[3], ARGV[2])
+return number`
+
+// advanceScript raises the watermark in KEYS[2] by ARGV[2], capped at the
+// last number handed out in KEYS[1], unless tick ARGV[1] was already
+// applied according to KEYS[3]. Once everyone is admitted event ARGV[3] is
+// dropped from the set KEYS[4].
+const advanceScript = `local admitted = tonumber(redis.call("GET", KEYS[2]) or "0")
+if redis.call("GET", KEYS[3]) == ARGV[1] then
+	return admitted
+end
+redis.call("SET", KEYS[3], ARGV[1])
+local last = tonumber(redis.call("GET", KEYS[1]) or "0")
+admitted = math.min(admitted + tonumber(ARGV[2]), last)
+redis.call("SET", KEYS[2], admitted)
+if admitted >= last then
+	redis.call("SREM", KEYS[4], ARGV[3])
+end
+return admitted`
+
+const membersScript = `return redis.call("SMEMBERS", KEYS[1])`
+
+// WaitingRoomRepository implements domain.WaitingRoomStore on top of Redis
+type WaitingRoomRepository struct {
+	client redisClient
+}
+
+// NewWaitingRoomRepository creates a waiting room store backed by the Upstash REST API
+func NewWaitingRoomRepository(url, token string) *WaitingRoomRepository {
+	return &WaitingRoomRepository{
+		client: &UpstashRedisClient{
+			url:   url,
+			token: token,
+		},
+	}
+}
+
+// NewNativeWaitingRoomRepository creates a waiting room store that talks
+// RESP over TCP to the Redis server at addr (host:port or redis:// URL)
+func NewNativeWaitingRoomRepository(addr string) (*WaitingRoomRepository, error) {
+	client, err := NewRESPClient(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &WaitingRoomRepository{client: client}, nil
+}
+
+// Join returns the user's queue number, assigning the next one on first join
+func (r *WaitingRoomRepository) Join(ctx context.Context, eventID, userID string) (int64, error) {
+	keys := []string{
+		fmt.Sprintf("waiting_room:%s:seq", eventID),
+		fmt.Sprintf("waiting_room:%s:users", eventID),
+		waitingRoomEventsKey,
+	}
+	result, err := r.client.eval(ctx, joinScript, keys, userID, eventID)
+	if err != nil {
+		return 0, err
+	}
+	number, ok := toInt64(result)
+	if !ok {
+		return 0, fmt.Errorf("unexpected result type")
+	}
+	return number, nil
+}
+
+// Admitted returns the highest queue number allowed to buy
+func (r *WaitingRoomRepository) Admitted(ctx context.Context, eventID string) (int64, error) {
+	value, err := r.client.get(ctx, fmt.Sprintf("waiting_room:%s:admitted", eventID))
+	if err != nil && err.Error() == "key not found" {
+		return 0, nil // nobody admitted yet
+	}
+	if err != nil {
+		return 0, err
+	}
+	var admitted int64
+	if _, err := fmt.Sscan(value, &admitted); err != nil {
+		return 0, fmt.Errorf("invalid admitted watermark %q", value)
+	}
+	return admitted, nil
+}
+
+// Advance admits up to count more buyers unless tick was already applied
+func (r *WaitingRoomRepository) Advance(ctx context.Context, eventID string, tick, count int64) (int64, error) {
+	keys := []string{
+		fmt.Sprintf("waiting_room:%s:seq", eventID),
+		fmt.Sprintf("waiting_room:%s:admitted", eventID),
+		fmt.Sprintf("waiting_room:%s:tick", eventID),
+		waitingRoomEventsKey,
+	}
+	result, err := r.client.eval(ctx, advanceScript, keys, fmt.Sprintf("%d", tick), fmt.Sprintf("%d", count), eventID)
+	if err != nil {
+		return 0, err
+	}
+	admitted, ok := toInt64(result)
+	if !ok {
+		return 0, fmt.Errorf("unexpected result type")
+	}
+	return admitted, nil
+}
+
+// Events lists the events with buyers still waiting
+func (r *WaitingRoomRepository) Events(ctx context.Context) ([]string, error) {
+	result, err := r.client.eval(ctx, membersScript, []string{waitingRoomEventsKey})
+	if err != nil {
+		return nil, err
+	}
+	members, ok := result.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected result type")
+	}
+	events := make([]string, 0, len(members))
+	for _, member := range members {
+		eventID, ok := member.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected result type")
+		}
+		events = append(events, eventID)
+	}
+	return events, nil
+}
@@ -0,0 +1,27 @@
+package services
+
+import (
+	"github.com/flashtix/server/internal/domain"
+	"github.com/shopspring/decimal"
+)
+
+// OrderPricing adds booking fees and taxes to the seat prices of an order
+type OrderPricing struct {
+	FeePerTicket map[string]decimal.Decimal // flat booking fee per ticket by currency code, none for a currency not listed
+	TaxRate      decimal.Decimal            // charged on the subtotal plus fees, e.g. 0.11 for 11%
+}
+
+// price sets the order's subtotal, fees, taxes and total from its items.
+// Each amount is rounded to the currency's minor unit, so the total is
+// exactly the sum of the other three.
+func (p OrderPricing) price(order *domain.Order) {
+	subtotal := decimal.Zero
+	for _, item := range order.Items {
+		subtotal = subtotal.Add(item.Price)
+	}
+
+	order.Subtotal = domain.RoundMoney(subtotal, order.Currency)
+	order.Fees = domain.RoundMoney(p.FeePerTicket[order.Currency].Mul(decimal.NewFromInt(int64(len(order.Items)))), order.Currency)
+	order.Taxes = domain.RoundMoney(order.Subtotal.Add(order.Fees).Mul(p.TaxRate), order.Currency)
+	order.Total = order.Subtotal.Add(order.Fees).Add(order.Taxes)
+}
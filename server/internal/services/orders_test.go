@@ -0,0 +1,57 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/flashtix/server/internal/domain"
+	"github.com/shopspring/decimal"
+)
+
+func TestOrderPricing(t *testing.T) {
+	pricing := OrderPricing{
+		FeePerTicket: map[string]decimal.Decimal{"USD": decimal.RequireFromString("2.5"), "IDR": decimal.NewFromInt(2500)},
+		TaxRate:      decimal.RequireFromString("0.11"),
+	}
+	order := &domain.Order{Currency: "USD", Items: []domain.OrderItem{
+		{Seat: "A1", Price: decimal.RequireFromString("49.99")},
+		{Seat: "A2", Price: decimal.RequireFromString("49.99")},
+		{Seat: "A3", Price: decimal.RequireFromString("25.50")},
+	}}
+	pricing.price(order)
+
+	// 11% of 132.98 is 14.6278
+	expected := map[string][2]decimal.Decimal{
+		"subtotal": {order.Subtotal, decimal.RequireFromString("125.48")},
+		"fees":     {order.Fees, decimal.RequireFromString("7.50")},
+		"taxes":    {order.Taxes, decimal.RequireFromString("14.63")},
+		"total":    {order.Total, decimal.RequireFromString("147.61")},
+	}
+	for name, amounts := range expected {
+		if !amounts[0].Equal(amounts[1]) {
+			t.Errorf("Expected %s %s, got %s", name, amounts[1], amounts[0])
+		}
+	}
+
+	// Rupiah has no minor unit
+	order = &domain.Order{Currency: "IDR", Items: []domain.OrderItem{
+		{Seat: "B1", Price: decimal.NewFromInt(150000)},
+		{Seat: "B2", Price: decimal.NewFromInt(150000)},
+	}}
+	pricing.price(order)
+	if !order.Taxes.Equal(decimal.NewFromInt(33550)) || !order.Total.Equal(decimal.NewFromInt(338550)) {
+		t.Errorf("Expected taxes 33550 and total 338550, got %s and %s", order.Taxes, order.Total)
+	}
+
+	// A currency without a fee is charged none
+	order = &domain.Order{Currency: "EUR", Items: []domain.OrderItem{{Seat: "C1", Price: decimal.NewFromInt(100)}}}
+	pricing.price(order)
+	if !order.Fees.IsZero() || !order.Total.Equal(decimal.NewFromInt(111)) {
+		t.Errorf("Expected no fees and total 111, got %s and %s", order.Fees, order.Total)
+	}
+
+	order = &domain.Order{Currency: "IDR", Items: []domain.OrderItem{{Seat: "B1", Price: decimal.RequireFromString("100000.5")}}}
+	OrderPricing{}.price(order)
+	if !order.Total.Equal(decimal.NewFromInt(100001)) {
+		t.Errorf("Expected rupiah to round half away from zero, got %s", order.Total)
+	}
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/flashtix/server/internal/domain"
+)
+
+// Outcomes of a payment webhook event, as recorded in the audit log
+const (
+	PaymentEventConfirmed  = "confirmed"
+	PaymentEventReleased   = "released"
+	PaymentEventIgnored    = "ignored"
+	PaymentEventNotApplied = "not applied"
+)
+
+// HandlePaymentEvent applies a webhook notification to the hold its payment
+// belongs to: an authorized payment confirms the hold, a failed or voided
+// one releases it. The payment's state is read back from the provider
+// rather than trusted from the event. It returns the outcome for the audit
+// log; an error means the event should be delivered again.
+func (s *TicketService) HandlePaymentEvent(ctx context.Context, event *domain.PaymentEvent) (string, error) {
+	switch event.Type {
+	case domain.PaymentEventAuthorized, domain.PaymentEventFailed, domain.PaymentEventVoided:
+	default:
+		return PaymentEventIgnored, nil
+	}
+
+	hold, err := s.holdRepo.GetByID(ctx, event.HoldID)
+	if errors.Is(err, domain.ErrHoldNotFound) {
+		return PaymentEventIgnored + ": hold not found", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if hold.PaymentID != event.PaymentID {
+		return PaymentEventIgnored + ": payment does not belong to the hold", nil
+	}
+
+	if event.Type == domain.PaymentEventAuthorized {
+		seats, err := s.ConfirmHold(ctx, hold.ID, hold.UserID)
+		if err != nil {
+			if finalPaymentError(err) {
+				return fmt.Sprintf("%s: %v", PaymentEventNotApplied, err), nil
+			}
+			return "", err
+		}
+		return fmt.Sprintf("%s %d seats", PaymentEventConfirmed, len(seats)), nil
+	}
+
+	if err := s.ReleaseHold(ctx, hold.ID, hold.UserID); err != nil {
+		if finalPaymentError(err) {
+			return fmt.Sprintf("%s: %v", PaymentEventNotApplied, err), nil
+		}
+		return "", err
+	}
+	return PaymentEventReleased, nil
+}
+
+// finalPaymentError reports whether err means the hold can never be
+// confirmed or released, so delivering the event again would not help
+func finalPaymentError(err error) bool {
+	for _, final := range []error{
+		domain.ErrHoldNotFound,
+		domain.ErrSeatNotReserved,
+		domain.ErrSeatTaken,
+		domain.ErrReservationNotOwned,
+		domain.ErrReservationExpired,
+		domain.ErrStaleLockToken,
+		domain.ErrPurchaseLimit,
+		domain.ErrPaymentFailed,
+		domain.ErrTicketNotFound,
+	} {
+		if errors.Is(err, final) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/flashtix/server/internal/domain"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// RefundRequest asks for some or all tickets of an order to be refunded
+type RefundRequest struct {
+	OrderID     string
+	Seats       []string // empty for every ticket not yet refunded
+	RequestedBy string
+	// Admin refunds skip the event's refund window and fee and may choose
+	// whether the seats go back on sale
+	Admin   bool
+	Restock *bool
+}
+
+// RefundService returns money for sold tickets through the payment provider
+// and takes the tickets back
+type RefundService struct {
+	orderRepo    domain.OrderRepository
+	eventRepo    domain.EventRepository
+	refundRepo   domain.RefundRepository
+	seatLockRepo domain.SeatLocker
+	payments     domain.PaymentProvider
+	seatEvents   *SeatEventBroker
+}
+
+func NewRefundService(orderRepo domain.OrderRepository, eventRepo domain.EventRepository, refundRepo domain.RefundRepository, seatLockRepo domain.SeatLocker, payments domain.PaymentProvider, seatEvents *SeatEventBroker) *RefundService {
+	return &RefundService{
+		orderRepo:    orderRepo,
+		eventRepo:    eventRepo,
+		refundRepo:   refundRepo,
+		seatLockRepo: seatLockRepo,
+		payments:     payments,
+		seatEvents:   seatEvents,
+	}
+}
+
+// RefundOrder refunds the requested tickets of a paid order. Buyers may only
+// refund their own orders, while the event offers refunds and before its
+// refund window closes, and pay the event's refund fee per ticket. The
+// items are claimed by a pending refund before the provider is asked for
+// the money, so concurrent requests cannot refund a ticket twice; a request
+// for tickets claimed by a refund that never finished resumes that refund.
+func (s *RefundService) RefundOrder(ctx context.Context, req RefundRequest) (*domain.Refund, error) {
+	order, err := s.orderRepo.GetByID(ctx, req.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	if !req.Admin && order.UserID != req.RequestedBy {
+		return nil, domain.ErrOrderNotFound
+	}
+	if order.Status != domain.OrderPaid || order.PaymentID == "" {
+		return nil, domain.ErrOrderNotRefundable
+	}
+
+	event, err := s.eventRepo.GetByID(ctx, order.EventID)
+	if err != nil {
+		return nil, err
+	}
+	restock := event.RestockRefunds
+	feePerTicket := decimal.Zero
+	if req.Admin {
+		if req.Restock != nil {
+			restock = *req.Restock
+		}
+	} else {
+		if !event.Refundable {
+			return nil, domain.ErrRefundNotAllowed
+		}
+		closesAt := event.Date.Add(-time.Duration(event.RefundCutoffHours) * time.Hour)
+		if !time.Now().Before(closesAt) {
+			return nil, domain.ErrRefundWindowClosed
+		}
+		feePerTicket = event.RefundFeePerTicket
+	}
+
+	previous, err := s.refundRepo.ListByOrder(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	items, pending, err := refundItems(order, req.Seats, previous)
+	if err != nil {
+		return nil, err
+	}
+	refund := pending
+	if refund == nil {
+		share := refundShare(order, items, previous)
+		fee := domain.RoundMoney(feePerTicket.Mul(decimal.NewFromInt(int64(len(items)))), order.Currency)
+		if fee.GreaterThan(share) {
+			fee = share
+		}
+		refund = &domain.Refund{
+			ID:          uuid.New().String(),
+			OrderID:     order.ID,
+			PaymentID:   order.PaymentID,
+			Items:       items,
+			Amount:      share.Sub(fee),
+			Fee:         fee,
+			Currency:    order.Currency,
+			Restock:     restock,
+			RequestedBy: req.RequestedBy,
+			Status:      domain.RefundPending,
+		}
+		if err := s.refundRepo.Create(ctx, refund); err != nil {
+			return nil, err
+		}
+	}
+
+	if refund.Amount.IsPositive() {
+		ref, err := s.payments.RefundPayment(ctx, refund.PaymentID, refund.Amount, refund.ID)
+		if err != nil {
+			// Only a refusal releases the tickets; after any other error the
+			// provider may have refunded, so the refund stays pending and
+			// the next request retries it under the same key
+			if errors.Is(err, domain.ErrPaymentFailed) {
+				if err := s.refundRepo.Fail(ctx, refund.ID); err != nil {
+					log.Printf("Failed to mark refund %s failed: %v", refund.ID, err)
+				}
+			}
+			return nil, err
+		}
+		refund.ProviderRefundID = ref
+	}
+
+	restocked, err := s.refundRepo.Complete(ctx, refund.ID, refund.ProviderRefundID)
+	if err != nil {
+		return nil, fmt.Errorf("payment refunded but refund %s was not completed: %w", refund.ID, err)
+	}
+	if len(restocked) > 0 {
+		s.restock(ctx, order.EventID, restocked)
+	}
+	refund.Status = domain.RefundSucceeded
+	return refund, nil
+}
+
+// restock announces the restocked seats and puts restocked general-admission
+// tickets back on their tier's stock
+func (s *RefundService) restock(ctx context.Context, eventID string, tickets []*domain.Ticket) {
+	seats := make([]string, 0, len(tickets))
+	tiers := make(map[string]int)
+	for _, ticket := range tickets {
+		seats = append(seats, ticket.Seat)
+		if ticket.TierID != "" {
+			tiers[ticket.TierID]++
+		}
+	}
+	s.seatEvents.Publish(eventID, domain.SeatEventReleased, seats...)
+
+	// Only general-admission tiers have their stock counted, the locker
+	// ignores the others
+	for tierID, quantity := range tiers {
+		if err := s.seatLockRepo.ReturnTierStock(ctx, eventID, tierID, quantity); err != nil {
+			// The tickets are on sale in the database; the stock stays
+			// short until it is seeded again, which cannot oversell
+			log.Printf("Failed to return %d refunded tickets to tier %s: %v", quantity, tierID, err)
+		}
+	}
+}
+
+// refundItems picks the order items to refund: the requested seats, or
+// every item no refund has claimed. When all of them are claimed by the
+// same pending refund, that refund is returned to be resumed instead.
+func refundItems(order *domain.Order, seats []string, previous []*domain.Refund) ([]domain.OrderItem, *domain.Refund, error) {
+	pending := make(map[string]*domain.Refund)
+	for _, refund := range previous {
+		if refund.Status == domain.RefundPending {
+			pending[refund.ID] = refund
+		}
+	}
+
+	var items []domain.OrderItem
+	if len(seats) == 0 {
+		for _, item := range order.Items {
+			if item.RefundID == "" {
+				items = append(items, item)
+			}
+		}
+		if len(items) == 0 {
+			for _, refund := range previous {
+				if refund.Status == domain.RefundPending {
+					return nil, refund, nil
+				}
+			}
+			return nil, nil, domain.ErrAlreadyRefunded
+		}
+		return items, nil, nil
+	}
+
+	bySeat := make(map[string]domain.OrderItem, len(order.Items))
+	for _, item := range order.Items {
+		bySeat[item.Seat] = item
+	}
+	claimedBy := ""
+	for _, seat := range uniqueSeats(seats) {
+		item, ok := bySeat[seat]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %s is not part of the order", domain.ErrTicketNotFound, seat)
+		}
+		if item.RefundID != "" {
+			if pending[item.RefundID] == nil || (claimedBy != "" && claimedBy != item.RefundID) {
+				return nil, nil, fmt.Errorf("%w: %s", domain.ErrAlreadyRefunded, seat)
+			}
+			claimedBy = item.RefundID
+		}
+		items = append(items, item)
+	}
+	if claimedBy != "" {
+		refund := pending[claimedBy]
+		if len(refund.Items) != len(items) {
+			return nil, nil, fmt.Errorf("%w: a refund of some of these seats is in progress", domain.ErrAlreadyRefunded)
+		}
+		for _, item := range items {
+			if item.RefundID != claimedBy {
+				return nil, nil, fmt.Errorf("%w: a refund of some of these seats is in progress", domain.ErrAlreadyRefunded)
+			}
+		}
+		return nil, refund, nil
+	}
+	return items, nil, nil
+}
+
+// refundShare is the part of the order total paid for the items, fees and
+// taxes included. Items are charged in proportion to their price; the last
+// items of the order get whatever earlier refunds left, so the refunds
+// always add up to the order total.
+func refundShare(order *domain.Order, items []domain.OrderItem, previous []*domain.Refund) decimal.Decimal {
+	settled := decimal.Zero
+	claimed := 0
+	for _, refund := range previous {
+		if refund.Status == domain.RefundFailed {
+			continue
+		}
+		settled = settled.Add(refund.Amount).Add(refund.Fee)
+		claimed += len(refund.Items)
+	}
+	if claimed+len(items) >= len(order.Items) {
+		return order.Total.Sub(settled)
+	}
+
+	price := decimal.Zero
+	for _, item := range items {
+		price = price.Add(item.Price)
+	}
+	var share decimal.Decimal
+	if order.Subtotal.IsZero() {
+		share = order.Total.Mul(decimal.NewFromInt(int64(len(items)))).Div(decimal.NewFromInt(int64(len(order.Items))))
+	} else {
+		share = order.Total.Mul(price).Div(order.Subtotal)
+	}
+	return domain.RoundMoney(share, order.Currency)
+}
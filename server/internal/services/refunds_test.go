@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flashtix/server/internal/domain"
+	"github.com/flashtix/server/internal/payments"
+	"github.com/flashtix/server/internal/repository/memory"
+	"github.com/shopspring/decimal"
+)
+
+// singleOrderRepo serves one order
+type singleOrderRepo struct {
+	domain.OrderRepository
+	order *domain.Order
+}
+
+func (r *singleOrderRepo) GetByID(ctx context.Context, id string) (*domain.Order, error) {
+	if id != r.order.ID {
+		return nil, domain.ErrOrderNotFound
+	}
+	order := *r.order
+	order.Items = append([]domain.OrderItem(nil), r.order.Items...)
+	return &order, nil
+}
+
+// singleEventRepo serves one event
+type singleEventRepo struct {
+	domain.EventRepository
+	event *domain.Event
+}
+
+func (r *singleEventRepo) GetByID(ctx context.Context, id string) (*domain.Event, error) {
+	return r.event, nil
+}
+
+// orderRefundRepo keeps refunds of a singleOrderRepo's order in memory
+type orderRefundRepo struct {
+	orders  *singleOrderRepo
+	refunds []*domain.Refund
+}
+
+func (r *orderRefundRepo) Create(ctx context.Context, refund *domain.Refund) error {
+	order := r.orders.order
+	for _, item := range refund.Items {
+		for _, claimed := range order.Items {
+			if claimed.TicketID == item.TicketID && claimed.RefundID != "" {
+				return domain.ErrAlreadyRefunded
+			}
+		}
+	}
+	for _, item := range refund.Items {
+		for i := range order.Items {
+			if order.Items[i].TicketID == item.TicketID {
+				order.Items[i].RefundID = refund.ID
+			}
+		}
+	}
+	stored := *refund
+	r.refunds = append(r.refunds, &stored)
+	return nil
+}
+
+func (r *orderRefundRepo) ListByOrder(ctx context.Context, orderID string) ([]*domain.Refund, error) {
+	return r.refunds, nil
+}
+
+func (r *orderRefundRepo) Complete(ctx context.Context, id, providerRefundID string) ([]*domain.Ticket, error) {
+	order := r.orders.order
+	for _, refund := range r.refunds {
+		if refund.ID != id || refund.Status != domain.RefundPending {
+			continue
+		}
+		refund.Status = domain.RefundSucceeded
+		order.Refunded = order.Refunded.Add(refund.Amount)
+		order.Status = domain.OrderRefunded
+		for _, item := range order.Items {
+			if !r.succeeded(item.RefundID) {
+				order.Status = domain.OrderPaid
+			}
+		}
+		var tickets []*domain.Ticket
+		if refund.Restock {
+			for _, item := range refund.Items {
+				tickets = append(tickets, &domain.Ticket{ID: item.TicketID, EventID: order.EventID, Seat: item.Seat})
+			}
+		}
+		return tickets, nil
+	}
+	return nil, nil
+}
+
+// succeeded reports whether the refund with the given ID succeeded
+func (r *orderRefundRepo) succeeded(id string) bool {
+	for _, refund := range r.refunds {
+		if refund.ID == id {
+			return refund.Status == domain.RefundSucceeded
+		}
+	}
+	return false
+}
+
+func (r *orderRefundRepo) Fail(ctx context.Context, id string) error {
+	return nil
+}
+
+func TestRefundOrder(t *testing.T) {
+	ctx := context.Background()
+	provider := payments.NewFakeProvider()
+	payment, _ := provider.CreatePayment(ctx, "hold1", decimal.RequireFromString("147.61"), "USD")
+	provider.CapturePayment(ctx, payment.ID, decimal.RequireFromString("147.61"))
+
+	order := &domain.Order{
+		ID: "order1", UserID: "user123", EventID: "event1", PaymentID: payment.ID,
+		Currency: "USD", Status: domain.OrderPaid,
+		Items: []domain.OrderItem{
+			{TicketID: "t1", Seat: "A1", Price: decimal.RequireFromString("49.99")},
+			{TicketID: "t2", Seat: "A2", Price: decimal.RequireFromString("49.99")},
+			{TicketID: "t3", Seat: "A3", Price: decimal.RequireFromString("25.50")},
+		},
+	}
+	OrderPricing{FeePerTicket: map[string]decimal.Decimal{"USD": decimal.RequireFromString("2.5")}, TaxRate: decimal.RequireFromString("0.11")}.price(order)
+
+	event := &domain.Event{
+		ID: "event1", Date: time.Now().Add(72 * time.Hour), Currency: "USD",
+		Refundable: true, RefundCutoffHours: 24, RefundFeePerTicket: decimal.NewFromInt(1), RestockRefunds: true,
+	}
+	orders := &singleOrderRepo{order: order}
+	refunds := &orderRefundRepo{orders: orders}
+	seatEvents := NewSeatEventBroker()
+	updates, unsubscribe := seatEvents.Subscribe("event1")
+	defer unsubscribe()
+	service := NewRefundService(orders, &singleEventRepo{event: event}, refunds, memory.NewSeatLockRepository(), provider, seatEvents)
+
+	if _, err := service.RefundOrder(ctx, RefundRequest{OrderID: "order1", RequestedBy: "user456"}); !errors.Is(err, domain.ErrOrderNotFound) {
+		t.Errorf("Expected another user's order to be hidden, got %v", err)
+	}
+
+	// 49.99 of a 125.48 subtotal is 58.81 of the 147.61 total, less a 1.00 fee
+	refund, err := service.RefundOrder(ctx, RefundRequest{OrderID: "order1", Seats: []string{"A1"}, RequestedBy: "user123"})
+	if err != nil {
+		t.Fatalf("RefundOrder failed: %v", err)
+	}
+	if !refund.Amount.Equal(decimal.RequireFromString("57.81")) || !refund.Fee.Equal(decimal.NewFromInt(1)) {
+		t.Errorf("Expected 57.81 refunded and 1.00 kept, got %s and %s", refund.Amount, refund.Fee)
+	}
+	if update := <-updates; update.Seat != "A1" || update.Type != domain.SeatEventReleased {
+		t.Errorf("Expected A1 to go back on sale, got %+v", update)
+	}
+
+	if _, err := service.RefundOrder(ctx, RefundRequest{OrderID: "order1", Seats: []string{"A1"}, RequestedBy: "user123"}); !errors.Is(err, domain.ErrAlreadyRefunded) {
+		t.Errorf("Expected ErrAlreadyRefunded, got %v", err)
+	}
+
+	event.Date = time.Now().Add(12 * time.Hour)
+	if _, err := service.RefundOrder(ctx, RefundRequest{OrderID: "order1", RequestedBy: "user123"}); !errors.Is(err, domain.ErrRefundWindowClosed) {
+		t.Errorf("Expected ErrRefundWindowClosed, got %v", err)
+	}
+
+	// Admins refund the rest after the window, without fee or restocking
+	restock := false
+	refund, err = service.RefundOrder(ctx, RefundRequest{OrderID: "order1", RequestedBy: "admin", Admin: true, Restock: &restock})
+	if err != nil {
+		t.Fatalf("Admin RefundOrder failed: %v", err)
+	}
+	if !refund.Amount.Equal(decimal.RequireFromString("88.80")) || !refund.Fee.IsZero() || len(refund.Items) != 2 {
+		t.Errorf("Expected the remaining 88.80 for 2 tickets, got %s for %d", refund.Amount, len(refund.Items))
+	}
+	if order.Status != domain.OrderRefunded {
+		t.Errorf("Expected the order to be refunded, got %s", order.Status)
+	}
+	if refunded := provider.Refunded(payment.ID); !refunded.Equal(decimal.RequireFromString("146.61")) {
+		t.Errorf("Expected 146.61 refunded in total, got %s", refunded)
+	}
+	if len(updates) != 0 {
+		t.Errorf("Expected seats refunded without restocking to stay off sale, got %d updates", len(updates))
+	}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/flashtix/server/internal/domain"
+)
+
+// seatEventBuffer is how many updates a subscriber may fall behind before it
+// is disconnected
+const seatEventBuffer = 64
+
+// SeatEventBroker fans seat transitions out to every subscriber of an event
+// on this node. Publishing never blocks: a subscriber whose buffer is full is
+// dropped and its channel closed, so it should reload the seat map and
+// subscribe again.
+type SeatEventBroker struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan domain.SeatEvent]struct{}
+}
+
+func NewSeatEventBroker() *SeatEventBroker {
+	return &SeatEventBroker{subscribers: make(map[string]map[chan domain.SeatEvent]struct{})}
+}
+
+// Subscribe returns a channel of updates for the event and a function that
+// ends the subscription. The channel is closed when the subscription ends.
+func (b *SeatEventBroker) Subscribe(eventID string) (<-chan domain.SeatEvent, func()) {
+	ch := make(chan domain.SeatEvent, seatEventBuffer)
+
+	b.mu.Lock()
+	if b.subscribers[eventID] == nil {
+		b.subscribers[eventID] = make(map[chan domain.SeatEvent]struct{})
+	}
+	b.subscribers[eventID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(eventID, ch)
+	}
+}
+
+// Publish sends one update per seat to the event's subscribers
+func (b *SeatEventBroker) Publish(eventID, eventType string, seats ...string) {
+	status := domain.SeatAvailable
+	switch eventType {
+	case domain.SeatEventLocked:
+		status = domain.SeatHeld
+	case domain.SeatEventSold:
+		status = domain.SeatSold
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	for _, seat := range seats {
+		update := domain.SeatEvent{EventID: eventID, Seat: seat, Type: eventType, Status: status, At: now}
+		for ch := range b.subscribers[eventID] {
+			select {
+			case ch <- update:
+			default:
+				// Too slow to keep up; cut it loose rather than stall the caller
+				b.remove(eventID, ch)
+			}
+		}
+	}
+}
+
+// remove drops and closes a subscription if it is still registered. The
+// caller must hold b.mu.
+func (b *SeatEventBroker) remove(eventID string, ch chan domain.SeatEvent) {
+	subscribers := b.subscribers[eventID]
+	if _, ok := subscribers[ch]; !ok {
+		return
+	}
+	delete(subscribers, ch)
+	close(ch)
+	if len(subscribers) == 0 {
+		delete(b.subscribers, eventID)
+	}
+}
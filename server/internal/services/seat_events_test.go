@@ -0,0 +1,37 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/flashtix/server/internal/domain"
+)
+
+func TestSeatEventBroker(t *testing.T) {
+	broker := NewSeatEventBroker()
+	fast, stopFast := broker.Subscribe("event1")
+	defer stopFast()
+	slow, stopSlow := broker.Subscribe("event1")
+	defer stopSlow()
+	other, stopOther := broker.Subscribe("event2")
+	defer stopOther()
+
+	broker.Publish("event1", domain.SeatEventSold, "A1")
+	if update := <-fast; update.Seat != "A1" || update.Status != domain.SeatSold {
+		t.Errorf("Expected A1 sold, got %+v", update)
+	}
+	if len(other) != 0 {
+		t.Errorf("Expected no updates for another event, got %d", len(other))
+	}
+
+	// Only the subscriber that stops reading is dropped
+	for i := 0; i < seatEventBuffer; i++ {
+		broker.Publish("event1", domain.SeatEventLocked, "A2")
+		<-fast
+	}
+	for range slow {
+	}
+	broker.Publish("event1", domain.SeatEventReleased, "A2")
+	if update, ok := <-fast; !ok || update.Status != domain.SeatAvailable {
+		t.Errorf("Expected fast subscriber to keep receiving, got %+v", update)
+	}
+}
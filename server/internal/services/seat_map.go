@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/flashtix/server/internal/domain"
+)
+
+// seatMapTTL is how long a computed seat map is served from memory. Clients
+// poll during an on-sale, so every poller within the window shares one
+// database query and one lock lookup per event.
+const seatMapTTL = time.Second
+
+type seatMapEntry struct {
+	mu        sync.Mutex
+	seats     []domain.SeatAvailability
+	expiresAt time.Time
+}
+
+// seatMapCache memoizes seat maps per event. Concurrent callers for the same
+// event wait for a single refresh instead of each hitting the stores.
+// Expired entries are swept at most once per seatMapTTL, so events no one
+// polls any more do not stay in memory.
+type seatMapCache struct {
+	mu      sync.Mutex
+	entries map[string]*seatMapEntry
+	sweptAt time.Time
+}
+
+func newSeatMapCache() *seatMapCache {
+	return &seatMapCache{entries: make(map[string]*seatMapEntry)}
+}
+
+func (c *seatMapCache) get(ctx context.Context, eventID string, load func(context.Context, string) ([]domain.SeatAvailability, error)) ([]domain.SeatAvailability, error) {
+	c.mu.Lock()
+	if now := time.Now(); now.Sub(c.sweptAt) >= seatMapTTL {
+		c.sweep(now)
+	}
+	entry, ok := c.entries[eventID]
+	if !ok {
+		entry = &seatMapEntry{}
+		c.entries[eventID] = entry
+	}
+	c.mu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if time.Now().Before(entry.expiresAt) {
+		return entry.seats, nil
+	}
+
+	seats, err := load(ctx, eventID)
+	if err != nil {
+		// Do not keep entries around for events that failed to load
+		c.mu.Lock()
+		if entry.seats == nil {
+			delete(c.entries, eventID)
+		}
+		c.mu.Unlock()
+		return nil, err
+	}
+
+	entry.seats = seats
+	entry.expiresAt = time.Now().Add(seatMapTTL)
+	return seats, nil
+}
+
+// sweep drops the entries that expired before now. Entries being refreshed
+// are left alone. The caller holds c.mu.
+func (c *seatMapCache) sweep(now time.Time) {
+	for eventID, entry := range c.entries {
+		if !entry.mu.TryLock() {
+			continue
+		}
+		if !entry.expiresAt.IsZero() && now.After(entry.expiresAt) {
+			delete(c.entries, eventID)
+		}
+		entry.mu.Unlock()
+	}
+	c.sweptAt = now
+}
+
+// SeatMap returns every seat of the event with its public status. The
+// result may be up to seatMapTTL old and must not be modified.
+func (s *TicketService) SeatMap(ctx context.Context, eventID string) ([]domain.SeatAvailability, error) {
+	return s.seatMaps.get(ctx, eventID, s.loadSeatMap)
+}
+
+// loadSeatMap merges ticket status from the database with live seat locks.
+// A seat is held while it has an unexpired reservation or a lock, so seats
+// locked but not yet written to the database already show as taken.
+func (s *TicketService) loadSeatMap(ctx context.Context, eventID string) ([]domain.SeatAvailability, error) {
+	tickets, err := s.ticketRepo.GetByEventID(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if len(tickets) == 0 {
+		// Tell an event without inventory apart from an unknown event
+		if _, err := s.eventRepo.GetByID(ctx, eventID); err != nil {
+			return nil, err
+		}
+		return []domain.SeatAvailability{}, nil
+	}
+	if tickets, err = s.seatedTickets(ctx, eventID, tickets); err != nil {
+		return nil, err
+	}
+
+	seats := make([]string, len(tickets))
+	for i, ticket := range tickets {
+		seats[i] = ticket.Seat
+	}
+	holders, err := s.seatLockRepo.LockHolders(ctx, eventID, seats)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := make([]domain.SeatAvailability, len(tickets))
+	for i, ticket := range tickets {
+		status := domain.SeatAvailable
+		switch {
+		case ticket.Status.Purchased():
+			// Refunded seats that were not restocked stay off sale
+			status = domain.SeatSold
+		case holders[i] != "":
+			status = domain.SeatHeld
+		case ticket.Status == domain.TicketReserved && ticket.ReservedUntil != nil && ticket.ReservedUntil.After(now):
+			status = domain.SeatHeld
+		}
+
+		result[i] = domain.SeatAvailability{
+			Seat:   ticket.Seat,
+			SeatID: ticket.SeatID,
+			Status: status,
+			Price:  ticket.Price,
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Seat < result[j].Seat })
+	return result, nil
+}
+
+// seatedTickets drops the tickets of general-admission tiers, which have no
+// place on the seat map
+func (s *TicketService) seatedTickets(ctx context.Context, eventID string, tickets []*domain.Ticket) ([]*domain.Ticket, error) {
+	tiered := false
+	for _, ticket := range tickets {
+		if ticket.TierID != "" {
+			tiered = true
+			break
+		}
+	}
+	if !tiered {
+		return tickets, nil
+	}
+
+	tiers, err := s.tierRepo.ListByEvent(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	admission := make(map[string]bool, len(tiers))
+	for _, tier := range tiers {
+		admission[tier.ID] = tier.GeneralAdmission()
+	}
+	seated := make([]*domain.Ticket, 0, len(tickets))
+	for _, ticket := range tickets {
+		if !admission[ticket.TierID] {
+			seated = append(seated, ticket)
+		}
+	}
+	return seated, nil
+}
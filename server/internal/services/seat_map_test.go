@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/flashtix/server/internal/domain"
+	"github.com/flashtix/server/internal/repository/memory"
+)
+
+// eventTicketRepo serves a fixed set of tickets for one event
+type eventTicketRepo struct {
+	domain.TicketRepository
+	tickets []*domain.Ticket
+	calls   int
+}
+
+func (r *eventTicketRepo) GetByEventID(ctx context.Context, eventID string) ([]*domain.Ticket, error) {
+	r.calls++
+	return r.tickets, nil
+}
+
+func (r *eventTicketRepo) GetByEventAndSeat(ctx context.Context, eventID, seat string) (*domain.Ticket, error) {
+	for _, ticket := range r.tickets {
+		if ticket.Seat == seat {
+			return ticket, nil
+		}
+	}
+	return nil, domain.ErrTicketNotFound
+}
+
+func (r *eventTicketRepo) GetByEventAndSeatID(ctx context.Context, eventID, seatID string) (*domain.Ticket, error) {
+	for _, ticket := range r.tickets {
+		if ticket.SeatID != "" && ticket.SeatID == seatID {
+			return ticket, nil
+		}
+	}
+	return nil, domain.ErrTicketNotFound
+}
+
+func TestSeatLabels(t *testing.T) {
+	ctx := context.Background()
+	repo := &eventTicketRepo{tickets: []*domain.Ticket{
+		{Seat: "VIP-A1", SeatID: "seat1", Status: domain.TicketAvailable},
+		{Seat: "VIP-A2", SeatID: "seat2", Status: domain.TicketAvailable},
+		{Seat: "Floor-1", Status: domain.TicketAvailable},
+	}}
+	service := NewTicketService(repo, nil, nil, nil, nil, memory.NewSeatLockRepository(), nil, OrderPricing{})
+
+	seats, err := service.SeatLabels(ctx, "event1", []string{"seat2", "seat1", "seat2"})
+	if err != nil {
+		t.Fatalf("SeatLabels failed: %v", err)
+	}
+	if len(seats) != 2 || seats[0] != "VIP-A2" || seats[1] != "VIP-A1" {
+		t.Errorf("Expected [VIP-A2 VIP-A1], got %v", seats)
+	}
+
+	if _, err := service.SeatLabels(ctx, "event1", []string{"seat1", "seat3"}); err != domain.ErrTicketNotFound {
+		t.Errorf("Expected ErrTicketNotFound for an unknown seat, got %v", err)
+	}
+	if _, err := service.SeatLabels(ctx, "event1", nil); err != ErrNoSeats {
+		t.Errorf("Expected ErrNoSeats, got %v", err)
+	}
+}
+
+func TestSeatMap(t *testing.T) {
+	ctx := context.Background()
+	locks := memory.NewSeatLockRepository()
+	future := time.Now().Add(time.Minute)
+	past := time.Now().Add(-time.Minute)
+	repo := &eventTicketRepo{tickets: []*domain.Ticket{
+		{Seat: "A4", Status: domain.TicketAvailable},
+		{Seat: "A3", Status: domain.TicketReserved, UserID: "user456", ReservedUntil: &past},
+		{Seat: "A2", Status: domain.TicketReserved, UserID: "user123", ReservedUntil: &future},
+		{Seat: "A1", Status: domain.TicketSold, UserID: "user123"},
+		{Seat: "A5", Status: domain.TicketAvailable},
+	}}
+	locks.LockSeat(ctx, "event1", "A5", "user789", time.Minute)
+
+	service := NewTicketService(repo, nil, nil, nil, nil, locks, nil, OrderPricing{})
+	seats, err := service.SeatMap(ctx, "event1")
+	if err != nil {
+		t.Fatalf("SeatMap failed: %v", err)
+	}
+
+	expected := []domain.SeatAvailability{
+		{Seat: "A1", Status: domain.SeatSold},
+		{Seat: "A2", Status: domain.SeatHeld},
+		{Seat: "A3", Status: domain.SeatAvailable},
+		{Seat: "A4", Status: domain.SeatAvailable},
+		{Seat: "A5", Status: domain.SeatHeld},
+	}
+	if len(seats) != len(expected) {
+		t.Fatalf("Expected %d seats, got %d", len(expected), len(seats))
+	}
+	for i := range expected {
+		if seats[i] != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], seats[i])
+		}
+	}
+
+	// Polls within the TTL are served from memory
+	service.SeatMap(ctx, "event1")
+	if repo.calls != 1 {
+		t.Errorf("Expected 1 database read, got %d", repo.calls)
+	}
+}
+
+func TestSeatMapCacheSweepsExpiredEntries(t *testing.T) {
+	ctx := context.Background()
+	cache := newSeatMapCache()
+	load := func(ctx context.Context, eventID string) ([]domain.SeatAvailability, error) {
+		return []domain.SeatAvailability{{Seat: "A1", Status: domain.SeatAvailable}}, nil
+	}
+
+	cache.get(ctx, "event1", load)
+	cache.get(ctx, "event2", load)
+	if len(cache.entries) != 2 {
+		t.Fatalf("Expected 2 cached seat maps, got %d", len(cache.entries))
+	}
+
+	// event1 is no longer polled and its seat map expired a while ago
+	cache.entries["event1"].expiresAt = time.Now().Add(-time.Minute)
+	cache.sweptAt = time.Now().Add(-time.Minute)
+	cache.get(ctx, "event2", load)
+	if _, ok := cache.entries["event1"]; ok || len(cache.entries) != 1 {
+		t.Errorf("Expected only event2 to stay cached, got %d entries", len(cache.entries))
+	}
+}
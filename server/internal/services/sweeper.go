@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/flashtix/server/internal/domain"
+)
+
+// ReservationSweeper periodically returns lapsed reservations to inventory,
+// drops any seat locks their holders still have, deletes spent holds and
+// voids the payments and cancels the orders of holds that expired unsold. It
+// is safe to run on every replica: the repositories claim expired rows with
+// SKIP LOCKED and unlocking is owner-checked.
+type ReservationSweeper struct {
+	ticketRepo   domain.TicketRepository
+	holdRepo     domain.HoldRepository
+	orderRepo    domain.OrderRepository
+	seatLockRepo domain.SeatLocker
+	payments     domain.PaymentProvider
+	seatEvents   *SeatEventBroker
+	interval     time.Duration
+	batchSize    int
+	maxBatches   int
+	reclaimed    atomic.Int64
+}
+
+func NewReservationSweeper(ticketRepo domain.TicketRepository, holdRepo domain.HoldRepository, orderRepo domain.OrderRepository, seatLockRepo domain.SeatLocker, payments domain.PaymentProvider, seatEvents *SeatEventBroker) *ReservationSweeper {
+	return &ReservationSweeper{
+		ticketRepo:   ticketRepo,
+		holdRepo:     holdRepo,
+		orderRepo:    orderRepo,
+		seatLockRepo: seatLockRepo,
+		payments:     payments,
+		seatEvents:   seatEvents,
+		interval:     30 * time.Second,
+		batchSize:    500,
+		maxBatches:   20, // at most 10,000 seats per tick
+	}
+}
+
+// Run sweeps every interval until ctx is cancelled
+func (s *ReservationSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Sweep(ctx); err != nil && ctx.Err() == nil {
+			log.Println("Reservation sweep failed:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep releases expired reservations in batches until none are left or the
+// per-tick batch budget is spent, and returns how many it released
+func (s *ReservationSweeper) Sweep(ctx context.Context) (int, error) {
+	released := 0
+	for i := 0; i < s.maxBatches; i++ {
+		expired, err := s.ticketRepo.ReleaseExpired(ctx, time.Now(), s.batchSize)
+		if err != nil {
+			return released, err
+		}
+
+		for _, reservation := range expired {
+			s.seatEvents.Publish(reservation.EventID, domain.SeatEventExpired, reservation.Seat)
+			if reservation.UserID == "" {
+				continue
+			}
+			if _, err := s.seatLockRepo.UnlockSeat(ctx, reservation.EventID, reservation.Seat, reservation.UserID); err != nil {
+				// The lock carries its own TTL, so a failed unlock only delays reuse
+				log.Printf("Failed to unlock expired seat %s/%s: %v", reservation.EventID, reservation.Seat, err)
+			}
+		}
+
+		released += len(expired)
+		if len(expired) < s.batchSize {
+			break
+		}
+	}
+
+	if released > 0 {
+		total := s.reclaimed.Add(int64(released))
+		log.Printf("Released %d expired reservations (%d since start)", released, total)
+	}
+
+	// Drop holds that no longer cover any reserved seat and give back the
+	// money authorized for those that sold nothing
+	payments, err := s.holdRepo.DeleteExpired(ctx, time.Now(), s.batchSize)
+	if err != nil {
+		return released, err
+	}
+	for _, paymentID := range payments {
+		if err := s.payments.VoidPayment(ctx, paymentID); err != nil {
+			// An unvoided authorization lapses at the provider on its own
+			log.Printf("Failed to void payment %s of expired hold: %v", paymentID, err)
+		}
+		if err := s.orderRepo.CancelPending(ctx, paymentID); err != nil {
+			log.Printf("Failed to cancel the order of expired payment %s: %v", paymentID, err)
+		}
+	}
+	return released, nil
+}
+
+// Reclaimed returns the number of reservations released since startup
+func (s *ReservationSweeper) Reclaimed() int64 {
+	return s.reclaimed.Load()
+}
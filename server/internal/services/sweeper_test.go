@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/flashtix/server/internal/domain"
+	"github.com/flashtix/server/internal/payments"
+	"github.com/flashtix/server/internal/repository/memory"
+	"github.com/shopspring/decimal"
+)
+
+// expiringTicketRepo hands out a fixed backlog of expired reservations
+type expiringTicketRepo struct {
+	domain.TicketRepository
+	backlog []domain.ExpiredReservation
+	calls   int
+}
+
+func (r *expiringTicketRepo) ReleaseExpired(ctx context.Context, now time.Time, limit int) ([]domain.ExpiredReservation, error) {
+	r.calls++
+	n := min(limit, len(r.backlog))
+	batch := r.backlog[:n]
+	r.backlog = r.backlog[n:]
+	return batch, nil
+}
+
+// expiredHoldRepo counts hold cleanups and hands out the payments of
+// expired holds once
+type expiredHoldRepo struct {
+	domain.HoldRepository
+	calls    int
+	payments []string
+}
+
+func (r *expiredHoldRepo) DeleteExpired(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	r.calls++
+	payments := r.payments
+	r.payments = nil
+	return payments, nil
+}
+
+// cancelledOrderRepo records the payments whose orders were cancelled
+type cancelledOrderRepo struct {
+	domain.OrderRepository
+	cancelled []string
+}
+
+func (r *cancelledOrderRepo) CancelPending(ctx context.Context, paymentID string) error {
+	r.cancelled = append(r.cancelled, paymentID)
+	return nil
+}
+
+func TestReservationSweeper(t *testing.T) {
+	ctx := context.Background()
+	locks := memory.NewSeatLockRepository()
+	repo := &expiringTicketRepo{}
+
+	for _, seat := range []string{"A1", "A2", "A3", "A4", "A5"} {
+		repo.backlog = append(repo.backlog, domain.ExpiredReservation{EventID: "event1", Seat: seat, UserID: "user123"})
+	}
+	locks.LockSeat(ctx, "event1", "A1", "user123", time.Minute)
+	locks.LockSeat(ctx, "event1", "A2", "user456", time.Minute)
+
+	provider := payments.NewFakeProvider()
+	payment, _ := provider.CreatePayment(ctx, "hold1", decimal.NewFromInt(50), "USD")
+	holds := &expiredHoldRepo{payments: []string{payment.ID}}
+	seatEvents := NewSeatEventBroker()
+	updates, unsubscribe := seatEvents.Subscribe("event1")
+	defer unsubscribe()
+	orders := &cancelledOrderRepo{}
+	sweeper := NewReservationSweeper(repo, holds, orders, locks, provider, seatEvents)
+	sweeper.batchSize = 2
+
+	released, err := sweeper.Sweep(ctx)
+	if err != nil {
+		t.Fatalf("Sweep failed: %v", err)
+	}
+	if released != 5 {
+		t.Errorf("Expected 5 released, got %d", released)
+	}
+	if repo.calls != 3 {
+		t.Errorf("Expected 3 batches, got %d", repo.calls)
+	}
+	if holds.calls != 1 {
+		t.Errorf("Expected expired holds to be cleaned up once, got %d", holds.calls)
+	}
+	if payment, _ := provider.GetPayment(ctx, payment.ID); payment.Status != domain.PaymentVoided {
+		t.Errorf("Expected the expired hold's payment to be voided, got %s", payment.Status)
+	}
+	if len(orders.cancelled) != 1 || orders.cancelled[0] != payment.ID {
+		t.Errorf("Expected the expired hold's order to be cancelled, got %v", orders.cancelled)
+	}
+	if len(updates) != 5 {
+		t.Errorf("Expected 5 seat updates, got %d", len(updates))
+	}
+	if update := <-updates; update.Type != domain.SeatEventExpired || update.Status != domain.SeatAvailable {
+		t.Errorf("Expected expired seat to become available, got %+v", update)
+	}
+	if sweeper.Reclaimed() != 5 {
+		t.Errorf("Expected 5 reclaimed, got %d", sweeper.Reclaimed())
+	}
+
+	if lockedBy, _ := locks.IsSeatLocked(ctx, "event1", "A1"); lockedBy != "" {
+		t.Errorf("Expected expired holder's lock to be dropped, got %q", lockedBy)
+	}
+	if lockedBy, _ := locks.IsSeatLocked(ctx, "event1", "A2"); lockedBy != "user456" {
+		t.Errorf("Expected another user's lock to be kept, got %q", lockedBy)
+	}
+}
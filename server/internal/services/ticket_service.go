@@ -3,87 +3,929 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log"
 	"time"
 
 	"github.com/flashtix/server/internal/domain"
-	"github.com/flashtix/server/internal/repository/redis"
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxSeatsPerReservation caps how many seats one multi-seat hold may cover
+	MaxSeatsPerReservation = 10
+	// MaxHoldExtensions caps how many times a buyer may extend a hold
+	MaxHoldExtensions = 1
+	// HoverLockDuration is how long a seat stays locked while a buyer is
+	// looking at it in the seat picker
+	HoverLockDuration = 30 * time.Second
+)
+
+var (
+	ErrNoSeats      = errors.New("no seats requested")
+	ErrTooManySeats = fmt.Errorf("at most %d seats can be reserved at once", MaxSeatsPerReservation)
+	ErrNotInHold    = errors.New("the hold does not cover that many tickets")
 )
 
 type TicketService struct {
-	ticketRepo   domain.TicketRepository
-	eventRepo    domain.EventRepository
-	seatLockRepo *redis.SeatLockRepository
-	lockDuration time.Duration
+	ticketRepo    domain.TicketRepository
+	eventRepo     domain.EventRepository
+	holdRepo      domain.HoldRepository
+	tierRepo      domain.TicketTierRepository
+	orderRepo     domain.OrderRepository
+	seatLockRepo  domain.SeatLocker
+	payments      domain.PaymentProvider
+	pricing       OrderPricing
+	lockDuration  time.Duration
+	holdExtension time.Duration
+	seatMaps      *seatMapCache
+	seatEvents    *SeatEventBroker
 }
 
-func NewTicketService(ticketRepo domain.TicketRepository, eventRepo domain.EventRepository, seatLockRepo *redis.SeatLockRepository) *TicketService {
+func NewTicketService(ticketRepo domain.TicketRepository, eventRepo domain.EventRepository, holdRepo domain.HoldRepository, tierRepo domain.TicketTierRepository, orderRepo domain.OrderRepository, seatLockRepo domain.SeatLocker, payments domain.PaymentProvider, pricing OrderPricing) *TicketService {
 	return &TicketService{
-		ticketRepo:   ticketRepo,
-		eventRepo:    eventRepo,
-		seatLockRepo: seatLockRepo,
-		lockDuration: 10 * time.Minute, // 10 minutes lock
+		ticketRepo:    ticketRepo,
+		eventRepo:     eventRepo,
+		holdRepo:      holdRepo,
+		tierRepo:      tierRepo,
+		orderRepo:     orderRepo,
+		seatLockRepo:  seatLockRepo,
+		payments:      payments,
+		pricing:       pricing,
+		lockDuration:  10 * time.Minute, // 10 minutes lock
+		holdExtension: 5 * time.Minute,
+		seatMaps:      newSeatMapCache(),
+		seatEvents:    NewSeatEventBroker(),
 	}
 }
 
-func (s *TicketService) ReserveSeat(ctx context.Context, eventID, seat, userID string) error {
-	// Check if seat is already locked
-	lockedBy, err := s.seatLockRepo.IsSeatLocked(ctx, eventID, seat)
+// SeatEvents returns the broker that receives the service's seat transitions
+func (s *TicketService) SeatEvents() *SeatEventBroker {
+	return s.seatEvents
+}
+
+// SubscribeSeatEvents subscribes to live seat transitions of an existing event
+func (s *TicketService) SubscribeSeatEvents(ctx context.Context, eventID string) (<-chan domain.SeatEvent, func(), error) {
+	if _, err := s.eventRepo.GetByID(ctx, eventID); err != nil {
+		return nil, nil, err
+	}
+	updates, unsubscribe := s.seatEvents.Subscribe(eventID)
+	return updates, unsubscribe, nil
+}
+
+// SeatLabels resolves venue seat IDs to the labels of the event's tickets
+// for those seats. Seats are locked, reserved and sold by label, which is
+// unique per event and derived from the venue seat, so callers addressing
+// seats by ID go through here first.
+func (s *TicketService) SeatLabels(ctx context.Context, eventID string, seatIDs []string) ([]string, error) {
+	seatIDs = uniqueSeats(seatIDs)
+	if len(seatIDs) == 0 {
+		return nil, ErrNoSeats
+	}
+	if len(seatIDs) > MaxSeatsPerReservation {
+		return nil, ErrTooManySeats
+	}
+
+	seats := make([]string, len(seatIDs))
+	for i, seatID := range seatIDs {
+		ticket, err := s.ticketRepo.GetByEventAndSeatID(ctx, eventID, seatID)
+		if err != nil {
+			return nil, err
+		}
+		seats[i] = ticket.Seat
+	}
+	return seats, nil
+}
+
+// ReserveSeat holds a single seat for the user
+func (s *TicketService) ReserveSeat(ctx context.Context, eventID, seat, userID string) (*domain.Hold, error) {
+	return s.ReserveSeats(ctx, eventID, []string{seat}, userID)
+}
+
+// ReserveSeats locks every seat in Redis and then reserves them in the
+// database under one new hold, or holds none of them. When some seats cannot
+// be held it returns a *domain.SeatConflictError listing them.
+func (s *TicketService) ReserveSeats(ctx context.Context, eventID string, seats []string, userID string) (*domain.Hold, error) {
+	seats = uniqueSeats(seats)
+	if len(seats) == 0 {
+		return nil, ErrNoSeats
+	}
+	if len(seats) > MaxSeatsPerReservation {
+		return nil, ErrTooManySeats
+	}
+
+	event, err := s.eventRepo.GetByID(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	maxHeld, purchaseBound, err := s.holdAllowance(ctx, event, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Seats the user already holds, under another hold or a hover lock, keep
+	// their locks if this reservation fails
+	holders, err := s.seatLockRepo.LockHolders(ctx, eventID, seats)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lock every seat in Redis in one atomic step, within the user's allowance
+	lockTokens, conflicts, err := s.seatLockRepo.LockSeats(ctx, eventID, seats, userID, s.lockDuration, maxHeld)
+	if errors.Is(err, domain.ErrHoldLimit) && purchaseBound {
+		err = domain.ErrPurchaseLimit
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(conflicts) > 0 {
+		return nil, &domain.SeatConflictError{Result: domain.SeatTaken, Seats: conflicts}
+	}
+
+	// Reserve in database under a new hold, stamped with the locks' fencing tokens
+	holdID := uuid.New().String()
+	now := time.Now()
+	result, conflicts, err := s.ticketRepo.ReserveSeats(ctx, eventID, seats, lockTokens, userID, holdID, s.lockDuration)
+	if err == nil && result != domain.SeatOK {
+		err = &domain.SeatConflictError{Result: result, Seats: conflicts}
+	}
+	if err != nil {
+		// Unlock the seats this call locked if the database update fails
+		for i, seat := range seats {
+			if holders[i] == userID {
+				continue
+			}
+			if _, err := s.seatLockRepo.UnlockSeat(ctx, eventID, seat, userID); err != nil {
+				log.Printf("Failed to unlock seat %s/%s: %v", eventID, seat, err)
+			}
+		}
+		return nil, err
+	}
+	s.seatEvents.Publish(eventID, domain.SeatEventLocked, seats...)
+
+	return &domain.Hold{
+		ID:        holdID,
+		EventID:   eventID,
+		UserID:    userID,
+		Seats:     seats,
+		ExpiresAt: now.Add(s.lockDuration),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
+}
+
+// ReserveTier holds quantity tickets of a general-admission tier for the
+// user. The tickets are first claimed from the tier's stock in the seat
+// locker, which refuses atomically once the tier is sold out, and then
+// reserved in the database under one new hold.
+func (s *TicketService) ReserveTier(ctx context.Context, eventID, tierID string, quantity int, userID string) (*domain.Hold, error) {
+	if quantity < 1 {
+		return nil, ErrNoSeats
+	}
+	if quantity > MaxSeatsPerReservation {
+		return nil, ErrTooManySeats
+	}
+
+	event, err := s.eventRepo.GetByID(ctx, eventID)
 	if err != nil {
+		return nil, err
+	}
+	tier, err := s.eventTier(ctx, eventID, tierID)
+	if err != nil {
+		return nil, err
+	}
+	if !tier.GeneralAdmission() {
+		return nil, fmt.Errorf("%w: %s is sold by seat", domain.ErrInvalidTier, tier.Name)
+	}
+	maxHeld, purchaseBound, err := s.holdAllowance(ctx, event, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The hold ID names the claim, so the hold settles it later
+	holdID := uuid.New().String()
+	now := time.Now()
+	err = s.claimTierStock(ctx, tier, holdID, userID, quantity, maxHeld, s.lockDuration)
+	if errors.Is(err, domain.ErrHoldLimit) && purchaseBound {
+		err = domain.ErrPurchaseLimit
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	seats, err := s.ticketRepo.ReserveTier(ctx, eventID, tierID, quantity, userID, holdID, s.lockDuration)
+	if err != nil {
+		// Give the claimed tickets back
+		if _, err := s.seatLockRepo.SettleTierClaim(ctx, eventID, tierID, holdID, userID, 0); err != nil {
+			log.Printf("Failed to return the claim of hold %s to tier %s: %v", holdID, tierID, err)
+		}
+		return nil, err
+	}
+	s.seatEvents.Publish(eventID, domain.SeatEventLocked, seats...)
+
+	return &domain.Hold{
+		ID:        holdID,
+		EventID:   eventID,
+		UserID:    userID,
+		TierID:    tierID,
+		Seats:     seats,
+		ExpiresAt: now.Add(s.lockDuration),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
+}
+
+// claimTierStock claims tickets of the tier for the hold. A tier whose
+// stock the locker does not count yet, e.g. after a Redis restart, is
+// seeded from the tickets the database has available.
+func (s *TicketService) claimTierStock(ctx context.Context, tier *domain.TicketTier, holdID, userID string, quantity, maxHeld int, expiration time.Duration) error {
+	err := s.seatLockRepo.ClaimTierStock(ctx, tier.EventID, tier.ID, holdID, userID, quantity, expiration, maxHeld)
+	if !errors.Is(err, domain.ErrTierStockUnknown) {
 		return err
 	}
-	if lockedBy != "" && lockedBy != userID {
-		return errors.New("seat is already reserved")
+	if err := s.seedTierStock(ctx, tier); err != nil {
+		return err
 	}
+	return s.seatLockRepo.ClaimTierStock(ctx, tier.EventID, tier.ID, holdID, userID, quantity, expiration, maxHeld)
+}
 
-	// Lock the seat in Redis
-	err = s.seatLockRepo.LockSeat(ctx, eventID, seat, userID, s.lockDuration)
+func (s *TicketService) seedTierStock(ctx context.Context, tier *domain.TicketTier) error {
+	available, err := s.tierRepo.CountAvailable(ctx, tier.ID)
 	if err != nil {
 		return err
 	}
+	return s.seatLockRepo.SeedTierStock(ctx, tier.EventID, tier.ID, available)
+}
+
+// eventTier returns the tier, or domain.ErrTierNotFound when it belongs to
+// another event
+func (s *TicketService) eventTier(ctx context.Context, eventID, tierID string) (*domain.TicketTier, error) {
+	tier, err := s.tierRepo.GetByID(ctx, tierID)
+	if err != nil {
+		return nil, err
+	}
+	if tier.EventID != eventID {
+		return nil, domain.ErrTierNotFound
+	}
+	return tier, nil
+}
+
+// Tiers returns the event's ticket tiers with the tickets left of each
+// general-admission tier
+func (s *TicketService) Tiers(ctx context.Context, eventID string) ([]*domain.TicketTier, error) {
+	if _, err := s.eventRepo.GetByID(ctx, eventID); err != nil {
+		return nil, err
+	}
+	tiers, err := s.tierRepo.ListByEvent(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	for _, tier := range tiers {
+		if !tier.GeneralAdmission() {
+			continue
+		}
+		available, counted, err := s.seatLockRepo.TierStock(ctx, eventID, tier.ID)
+		if err == nil && !counted {
+			if err = s.seedTierStock(ctx, tier); err == nil {
+				available, _, err = s.seatLockRepo.TierStock(ctx, eventID, tier.ID)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		tier.Available = &available
+	}
+	return tiers, nil
+}
+
+// holdAllowance returns how many seats of the event the user may hold at
+// once, 0 for no limit. The allowance is the event's hold limit, lowered to
+// what is left of the ticket limit after the user's purchases; purchaseBound
+// reports that the ticket limit is the tighter one. Seats already held are
+// counted by the seat locker when it applies the allowance.
+func (s *TicketService) holdAllowance(ctx context.Context, event *domain.Event, userID string) (maxHeld int, purchaseBound bool, err error) {
+	maxHeld = event.MaxHeldPerUser
+	if event.MaxTicketsPerUser == 0 {
+		return maxHeld, false, nil
+	}
+
+	sold, err := s.ticketRepo.CountSold(ctx, event.ID, userID)
+	if err != nil {
+		return 0, false, err
+	}
+	remaining := event.MaxTicketsPerUser - sold
+	if remaining <= 0 {
+		return 0, false, domain.ErrPurchaseLimit
+	}
+	if maxHeld == 0 || remaining < maxHeld {
+		return remaining, true, nil
+	}
+	return maxHeld, false, nil
+}
+
+// HoverLock briefly locks a seat the buyer is considering so others see it
+// as held. It does not reserve the seat; a lock the buyer already has, for
+// example from a reservation, is left untouched. Hover locks count toward
+// the user's hold allowance like reserved seats. Only available seats can
+// be locked; others fail with domain.ErrTicketNotFound or ErrSeatTaken. It
+// returns when the lock expires.
+func (s *TicketService) HoverLock(ctx context.Context, eventID, seat, userID string) (time.Time, error) {
+	ticket, err := s.ticketRepo.GetByEventAndSeat(ctx, eventID, seat)
+	if err != nil {
+		return time.Time{}, err
+	}
+	lockedBy, err := s.seatLockRepo.IsSeatLocked(ctx, eventID, seat)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if lockedBy == userID {
+		return time.Time{}, nil
+	}
+	if ticket.Status != domain.TicketAvailable {
+		return time.Time{}, domain.ErrSeatTaken
+	}
+
+	event, err := s.eventRepo.GetByID(ctx, eventID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	maxHeld, purchaseBound, err := s.holdAllowance(ctx, event, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(HoverLockDuration)
+	_, conflicts, err := s.seatLockRepo.LockSeats(ctx, eventID, []string{seat}, userID, HoverLockDuration, maxHeld)
+	if errors.Is(err, domain.ErrHoldLimit) && purchaseBound {
+		err = domain.ErrPurchaseLimit
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(conflicts) > 0 {
+		return time.Time{}, domain.ErrSeatTaken
+	}
+
+	s.seatEvents.Publish(eventID, domain.SeatEventLocked, seat)
+	return expiresAt, nil
+}
 
-	// Reserve in database
-	err = s.ticketRepo.ReserveSeat(ctx, eventID, seat, userID, s.lockDuration)
+// ReleaseHoverLock drops a hover lock. Seats the buyer has reserved keep
+// their lock until the reservation is confirmed or released, and only
+// available seats are announced as released.
+func (s *TicketService) ReleaseHoverLock(ctx context.Context, eventID, seat, userID string) error {
+	ticket, err := s.ticketRepo.GetByEventAndSeat(ctx, eventID, seat)
 	if err != nil {
-		// Unlock if database update fails
-		s.seatLockRepo.UnlockSeat(ctx, eventID, seat)
 		return err
 	}
+	if ticket.Status == domain.TicketReserved && ticket.UserID == userID {
+		return nil
+	}
 
+	unlocked, err := s.seatLockRepo.UnlockSeat(ctx, eventID, seat, userID)
+	if err != nil {
+		return err
+	}
+	if unlocked && ticket.Status == domain.TicketAvailable {
+		s.seatEvents.Publish(eventID, domain.SeatEventReleased, seat)
+	}
 	return nil
 }
 
-func (s *TicketService) ConfirmPurchase(ctx context.Context, eventID, seat, userID string) error {
-	// Check if seat is locked by this user
+// HoverLockLapsed ends a hover lock that has run out and tells other buyers
+// the seat is free again, unless it has since been reserved, sold or locked
+// by someone else
+func (s *TicketService) HoverLockLapsed(ctx context.Context, eventID, seat, userID string) error {
+	ticket, err := s.ticketRepo.GetByEventAndSeat(ctx, eventID, seat)
+	if err != nil {
+		return err
+	}
+	if ticket.Status != domain.TicketAvailable {
+		return nil
+	}
+
+	// The lock may outlive the local expiry by a moment
+	if _, err := s.seatLockRepo.UnlockSeat(ctx, eventID, seat, userID); err != nil {
+		return err
+	}
 	lockedBy, err := s.seatLockRepo.IsSeatLocked(ctx, eventID, seat)
 	if err != nil {
 		return err
 	}
-	if lockedBy != userID {
-		return errors.New("seat not reserved by this user")
+	if lockedBy == "" {
+		s.seatEvents.Publish(eventID, domain.SeatEventReleased, seat)
+	}
+	return nil
+}
+
+// uniqueSeats drops empty and repeated seats while keeping request order
+func uniqueSeats(seats []string) []string {
+	seen := make(map[string]bool, len(seats))
+	result := make([]string, 0, len(seats))
+	for _, seat := range seats {
+		if seat == "" || seen[seat] {
+			continue
+		}
+		seen[seat] = true
+		result = append(result, seat)
+	}
+	return result
+}
+
+// ConfirmPurchase confirms the hold covering the user's reservation of the
+// seat; see ConfirmHold
+func (s *TicketService) ConfirmPurchase(ctx context.Context, eventID, seat, userID string) ([]string, error) {
+	ticket, err := s.ticketRepo.GetByEventAndSeat(ctx, eventID, seat)
+	if err != nil {
+		return nil, err
+	}
+	if ticket.Status != domain.TicketReserved {
+		return nil, domain.ErrSeatNotReserved
+	}
+	if ticket.UserID != userID || ticket.HoldID == "" {
+		return nil, domain.ErrReservationNotOwned
+	}
+	return s.ConfirmHold(ctx, ticket.HoldID, userID)
+}
+
+// ConfirmSeats sells the given seats of one of the user's holds and releases
+// the rest of the hold; see ConfirmHold
+func (s *TicketService) ConfirmSeats(ctx context.Context, eventID string, seats []string, userID string) ([]string, error) {
+	seats = uniqueSeats(seats)
+	if len(seats) == 0 {
+		return nil, ErrNoSeats
+	}
+	holdID := ""
+	for _, seat := range seats {
+		ticket, err := s.ticketRepo.GetByEventAndSeat(ctx, eventID, seat)
+		if err != nil {
+			return nil, err
+		}
+		if ticket.Status != domain.TicketReserved {
+			return nil, domain.ErrSeatNotReserved
+		}
+		if ticket.UserID != userID || ticket.HoldID == "" {
+			return nil, domain.ErrReservationNotOwned
+		}
+		if holdID != "" && ticket.HoldID != holdID {
+			return nil, fmt.Errorf("%w: the seats must be in one hold", ErrNotInHold)
+		}
+		holdID = ticket.HoldID
+	}
+
+	hold, err := s.GetHold(ctx, holdID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.confirmHold(ctx, hold, seats, userID)
+}
+
+// ConfirmTier sells quantity tickets of the user's hold on the
+// general-admission tier and returns the rest of the hold to the tier; see
+// ConfirmHold
+func (s *TicketService) ConfirmTier(ctx context.Context, eventID, tierID string, quantity int, userID string) ([]string, error) {
+	if quantity < 1 {
+		return nil, ErrNoSeats
+	}
+	if _, err := s.eventTier(ctx, eventID, tierID); err != nil {
+		return nil, err
+	}
+	hold, err := s.holdRepo.FindByTier(ctx, tierID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if quantity > len(hold.Seats) {
+		return nil, ErrNotInHold
+	}
+	return s.confirmHold(ctx, hold, hold.Seats[:quantity], userID)
+}
+
+// StartPayment opens an order for the user's hold and starts its payment,
+// or returns the payment already started for it. The order charges the
+// price of the seats the hold reserves plus fees and taxes.
+func (s *TicketService) StartPayment(ctx context.Context, holdID, userID string) (*domain.Payment, error) {
+	hold, err := s.GetHold(ctx, holdID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if hold.PaymentID != "" {
+		return s.payments.GetPayment(ctx, hold.PaymentID)
+	}
+	if !time.Now().Before(hold.ExpiresAt) {
+		return nil, domain.ErrReservationExpired
+	}
+	if len(hold.Seats) == 0 {
+		return nil, domain.ErrSeatNotReserved
+	}
+	event, err := s.eventRepo.GetByID(ctx, hold.EventID)
+	if err != nil {
+		return nil, err
+	}
+
+	order := &domain.Order{
+		ID:       uuid.New().String(),
+		UserID:   userID,
+		EventID:  hold.EventID,
+		HoldID:   hold.ID,
+		Currency: event.Currency,
+		Status:   domain.OrderPending,
+	}
+	for _, seat := range hold.Seats {
+		ticket, err := s.ticketRepo.GetByEventAndSeat(ctx, hold.EventID, seat)
+		if err != nil {
+			return nil, err
+		}
+		order.Items = append(order.Items, domain.OrderItem{TicketID: ticket.ID, Seat: seat, Price: ticket.Price})
+	}
+	s.pricing.price(order)
+
+	payment, err := s.payments.CreatePayment(ctx, hold.ID, order.Total, order.Currency)
+	if err != nil {
+		return nil, err
+	}
+	// The order is stored before the hold points at the payment, so a
+	// retry after a failure here finds no payment and tries again. A
+	// concurrent request may have opened the order first; the hold then
+	// takes the payment of that order or none at all.
+	order.PaymentID = payment.ID
+	err = s.orderRepo.Create(ctx, order)
+	if errors.Is(err, domain.ErrOrderExists) {
+		err = s.checkOrderPayment(ctx, hold.ID, payment)
+	}
+	if err != nil {
+		return nil, err
 	}
+	if err := s.holdRepo.SetPayment(ctx, hold.ID, payment.ID); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
 
-	// Update ticket status to sold
-	ticket, err := s.ticketRepo.GetByID(ctx, "dummy-id") // This needs proper ID, adjust as needed
+// checkOrderPayment makes sure the order already open for the hold is paid
+// with payment. A payment the order does not use is voided so it can never
+// be captured.
+func (s *TicketService) checkOrderPayment(ctx context.Context, holdID string, payment *domain.Payment) error {
+	order, err := s.orderRepo.GetByHoldID(ctx, holdID)
 	if err != nil {
 		return err
 	}
-	ticket.Status = "sold"
-	err = s.ticketRepo.Update(ctx, ticket)
+	if order.PaymentID == payment.ID {
+		return nil
+	}
+	if err := s.payments.VoidPayment(ctx, payment.ID); err != nil {
+		log.Printf("Failed to void payment %s not used by order %s: %v", payment.ID, order.ID, err)
+	}
+	return domain.ErrPaymentConflict
+}
+
+// ConfirmHold sells the seats of the user's hold once its payment has been
+// authorized, captures the order total of the seats sold and marks the
+// order paid. When the capture failed after the seats were sold, calling it
+// again retries the capture. Seats that can no longer be sold are released, dropped from
+// the order and not charged for. It fails with domain.ErrPaymentRequired
+// until the buyer has paid; when the payment was declined the payment is
+// voided, the hold released and domain.ErrPaymentFailed returned. When no
+// seat can be sold the payment is voided, the order cancelled and the first
+// seat's error returned, e.g. ErrReservationExpired or
+// domain.ErrPurchaseLimit.
+func (s *TicketService) ConfirmHold(ctx context.Context, holdID, userID string) ([]string, error) {
+	hold, err := s.GetHold(ctx, holdID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.confirmHold(ctx, hold, hold.Seats, userID)
+}
+
+// confirmHold sells the seats in sell, all of which must be in the hold, and
+// releases the hold's other seats once anything was sold
+func (s *TicketService) confirmHold(ctx context.Context, hold *domain.Hold, sell []string, userID string) ([]string, error) {
+	if hold.PaymentID == "" {
+		return nil, domain.ErrPaymentRequired
+	}
+	if len(hold.Seats) == 0 && len(hold.Sold) == 0 {
+		// Released or expired; the sweeper voids the payment of a hold that
+		// lapsed without selling anything
+		return nil, domain.ErrSeatNotReserved
+	}
+
+	payment, err := s.payments.GetPayment(ctx, hold.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+	switch payment.Status {
+	case domain.PaymentAuthorized:
+	case domain.PaymentFailed, domain.PaymentVoided:
+		if err := s.ReleaseHold(ctx, hold.ID, userID); err != nil {
+			return nil, err
+		}
+		return nil, domain.ErrPaymentFailed
+	case domain.PaymentCaptured:
+		// Confirmed by an earlier request
+		return nil, nil
+	default:
+		return nil, domain.ErrPaymentRequired
+	}
+
+	event, err := s.eventRepo.GetByID(ctx, hold.EventID)
+	if err != nil {
+		return nil, err
+	}
+	order, err := s.orderRepo.GetByHoldID(ctx, hold.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(hold.Seats) == 0 {
+		// Sold by an earlier request that failed to capture the payment
+		return s.captureOrder(ctx, order, payment, hold.Sold)
+	}
+
+	var sold []string
+	var firstErr error
+	for _, seat := range sell {
+		if err := s.confirmSeat(ctx, event, hold, seat, userID); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		sold = append(sold, seat)
+	}
+
+	if len(sold) == 0 {
+		// A concurrent confirmation may have sold the seats and be about
+		// to capture the payment
+		if !s.soldUnderHold(ctx, hold) {
+			if err := s.payments.VoidPayment(ctx, payment.ID); err != nil {
+				log.Printf("Failed to void payment %s of hold %s: %v", payment.ID, hold.ID, err)
+			}
+			if err := s.orderRepo.CancelPending(ctx, payment.ID); err != nil {
+				log.Printf("Failed to cancel order %s: %v", order.ID, err)
+			}
+		}
+		return nil, firstErr
+	}
+	if len(sold) < len(hold.Seats) {
+		for _, seat := range hold.Seats {
+			err := s.ReleaseSeat(ctx, hold.EventID, seat, userID)
+			if err != nil && !errors.Is(err, domain.ErrSeatNotReserved) && !errors.Is(err, domain.ErrReservationNotOwned) {
+				log.Printf("Failed to release unsold seat %s/%s: %v", hold.EventID, seat, err)
+			}
+		}
+	}
+	if hold.TierID != "" {
+		// Return what the hold claimed but did not sell to the tier
+		if _, err := s.seatLockRepo.SettleTierClaim(ctx, hold.EventID, hold.TierID, hold.ID, userID, len(sold)); err != nil {
+			log.Printf("Failed to settle the claim of hold %s: %v", hold.ID, err)
+		}
+	}
+
+	// Seats sold by an earlier attempt are paid for by the same capture
+	return s.captureOrder(ctx, order, payment, append(sold, hold.Sold...))
+}
+
+// captureOrder captures the order total of the sold seats and marks the
+// order paid. When the capture fails the order stays pending, so confirming
+// the hold again retries it.
+func (s *TicketService) captureOrder(ctx context.Context, order *domain.Order, payment *domain.Payment, sold []string) ([]string, error) {
+	// Charge only for what was sold
+	soldSeats := make(map[string]bool, len(sold))
+	for _, seat := range sold {
+		soldSeats[seat] = true
+	}
+	items := order.Items[:0]
+	for _, item := range order.Items {
+		if soldSeats[item.Seat] {
+			items = append(items, item)
+		}
+	}
+	order.Items = items
+	s.pricing.price(order)
+
+	if _, err := s.payments.CapturePayment(ctx, payment.ID, order.Total); err != nil {
+		return sold, fmt.Errorf("seats sold but payment %s was not captured: %w", payment.ID, err)
+	}
+	order.Status = domain.OrderPaid
+	if err := s.orderRepo.Update(ctx, order); err != nil {
+		return sold, fmt.Errorf("seats sold but order %s was not updated: %w", order.ID, err)
+	}
+	return sold, nil
+}
+
+// soldUnderHold reports whether any seat of the hold was sold under it,
+// including by a concurrent request. Lookup failures count as sold so a
+// payment is never voided by mistake.
+func (s *TicketService) soldUnderHold(ctx context.Context, hold *domain.Hold) bool {
+	current, err := s.holdRepo.GetByID(ctx, hold.ID)
+	return err != nil || len(current.Sold) > 0
+}
+
+// confirmSeat moves the user's reservation of the seat from RESERVED to
+// SOLD. It fails with domain.ErrTicketNotFound, ErrSeatNotReserved,
+// ErrSeatTaken or ErrReservationExpired when the hold is not valid, and with
+// domain.ErrPurchaseLimit when the user already bought the event's limit.
+// General-admission tickets have no seat lock and are confirmed with a lock
+// token of 0.
+func (s *TicketService) confirmSeat(ctx context.Context, event *domain.Event, hold *domain.Hold, seat, userID string) error {
+	eventID := event.ID
+
+	// Fetch the fencing token of our lock so a write racing a newer lock
+	// holder is refused by the database
+	var lockToken int64
+	if hold.TierID == "" {
+		token, held, err := s.seatLockRepo.LockSeat(ctx, eventID, seat, userID, s.lockDuration)
+		if err != nil {
+			return err
+		}
+		if !held {
+			return domain.ErrReservationNotOwned
+		}
+		lockToken = token
+	}
+
+	// Mark the ticket sold only if the user still holds an unexpired
+	// reservation and has tickets left in their allowance
+	result, err := s.ticketRepo.ConfirmSeat(ctx, eventID, seat, userID, lockToken, event.MaxTicketsPerUser)
 	if err != nil {
+		// Keep the lock so the user can retry before it expires
 		return err
 	}
 
-	// Unlock the seat
-	return s.seatLockRepo.UnlockSeat(ctx, eventID, seat)
+	// The lock is no longer needed once the seat is sold or the hold is gone
+	if hold.TierID == "" {
+		if _, err := s.seatLockRepo.UnlockSeat(ctx, eventID, seat, userID); err != nil {
+			return err
+		}
+	}
+	if result == domain.SeatTaken {
+		return domain.ErrReservationNotOwned
+	}
+	switch result {
+	case domain.SeatOK:
+		s.seatEvents.Publish(eventID, domain.SeatEventSold, seat)
+	case domain.SeatLimitReached:
+		// The seat can never be bought by this user, so free it for others
+		if released, err := s.ticketRepo.ReleaseSeat(ctx, eventID, seat, userID); err == nil && released == domain.SeatOK {
+			s.seatEvents.Publish(eventID, domain.SeatEventReleased, seat)
+		}
+	}
+	return result.Err()
 }
 
-func (s *TicketService) ReleaseSeat(ctx context.Context, eventID, seat string) error {
+// ReleaseSeat gives up the user's reservation of the seat
+func (s *TicketService) ReleaseSeat(ctx context.Context, eventID, seat, userID string) error {
 	// Release from database
-	err := s.ticketRepo.ReleaseSeat(ctx, eventID, seat)
+	result, err := s.ticketRepo.ReleaseSeat(ctx, eventID, seat, userID)
 	if err != nil {
 		return err
 	}
+	if result == domain.SeatTaken {
+		return domain.ErrReservationNotOwned
+	}
 
 	// Unlock from Redis
-	return s.seatLockRepo.UnlockSeat(ctx, eventID, seat)
+	if _, err := s.seatLockRepo.UnlockSeat(ctx, eventID, seat, userID); err != nil {
+		return err
+	}
+	if result == domain.SeatOK {
+		s.seatEvents.Publish(eventID, domain.SeatEventReleased, seat)
+	}
+	return result.Err()
+}
+
+// GetHold returns the user's hold. Holds of other users are reported as
+// domain.ErrHoldNotFound so their IDs cannot be probed.
+func (s *TicketService) GetHold(ctx context.Context, holdID, userID string) (*domain.Hold, error) {
+	hold, err := s.holdRepo.GetByID(ctx, holdID)
+	if err != nil {
+		return nil, err
+	}
+	if hold.UserID != userID {
+		return nil, domain.ErrHoldNotFound
+	}
+	return hold, nil
+}
+
+// ExtendHold pushes the hold's expiry back by holdExtension, at most
+// MaxHoldExtensions times, and extends the seat locks to match. A lock that
+// lapsed before the hold did is taken again; when someone else got the seat
+// meanwhile it fails with domain.ErrSeatTaken, and when a general-admission
+// tier's tickets went back on sale and were claimed with
+// ErrReservationExpired. The hold is only extended once its locks are.
+func (s *TicketService) ExtendHold(ctx context.Context, holdID, userID string) (*domain.Hold, error) {
+	hold, err := s.GetHold(ctx, holdID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !time.Now().Before(hold.ExpiresAt) {
+		return nil, domain.ErrReservationExpired
+	}
+	if hold.Extensions >= MaxHoldExtensions {
+		return nil, domain.ErrHoldExtensionLimit
+	}
+
+	expiresAt := hold.ExpiresAt.Add(s.holdExtension)
+	ttl := time.Until(expiresAt)
+	if hold.TierID != "" {
+		err = s.extendTierClaim(ctx, hold, ttl)
+	} else {
+		err = s.extendSeatLocks(ctx, hold, ttl)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Locks extended for a hold that can no longer be extended lapse on
+	// their own
+	if err := s.holdRepo.Extend(ctx, holdID, userID, expiresAt, MaxHoldExtensions); err != nil {
+		return nil, err
+	}
+	hold.ExpiresAt = expiresAt
+	hold.Extensions++
+	return hold, nil
+}
+
+// extendSeatLocks extends the locks of the hold's seats to ttl, locking
+// again the seats whose lock lapsed if nobody else took them
+func (s *TicketService) extendSeatLocks(ctx context.Context, hold *domain.Hold, ttl time.Duration) error {
+	var lapsed []string
+	for _, seat := range hold.Seats {
+		extended, err := s.seatLockRepo.ExtendLock(ctx, hold.EventID, seat, hold.UserID, ttl)
+		if err != nil {
+			return err
+		}
+		if !extended {
+			lapsed = append(lapsed, seat)
+		}
+	}
+	if len(lapsed) == 0 {
+		return nil
+	}
+
+	_, conflicts, err := s.seatLockRepo.LockSeats(ctx, hold.EventID, lapsed, hold.UserID, ttl, 0)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return &domain.SeatConflictError{Result: domain.SeatTaken, Seats: conflicts}
+	}
+	return nil
+}
+
+// extendTierClaim extends the claim of a general-admission hold to ttl, or
+// claims its tickets again if the claim lapsed
+func (s *TicketService) extendTierClaim(ctx context.Context, hold *domain.Hold, ttl time.Duration) error {
+	extended, err := s.seatLockRepo.ExtendTierClaim(ctx, hold.EventID, hold.TierID, hold.ID, hold.UserID, ttl)
+	if err != nil || extended {
+		return err
+	}
+
+	tier := &domain.TicketTier{ID: hold.TierID, EventID: hold.EventID}
+	err = s.claimTierStock(ctx, tier, hold.ID, hold.UserID, len(hold.Seats), 0, ttl)
+	if errors.Is(err, domain.ErrSoldOut) {
+		return domain.ErrReservationExpired
+	}
+	return err
+}
+
+// ReleaseHold gives up every seat the user's hold still reserves, voids its
+// payment and deletes it. A hold that already sold seats keeps its payment
+// and stays, so confirming it again can capture what it sold.
+func (s *TicketService) ReleaseHold(ctx context.Context, holdID, userID string) error {
+	hold, err := s.GetHold(ctx, holdID, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, seat := range hold.Seats {
+		err := s.ReleaseSeat(ctx, hold.EventID, seat, userID)
+		if err != nil && !errors.Is(err, domain.ErrSeatNotReserved) {
+			return err
+		}
+	}
+	if hold.TierID != "" {
+		// Everything sold under the hold already settled its claim
+		if _, err := s.seatLockRepo.SettleTierClaim(ctx, hold.EventID, hold.TierID, hold.ID, userID, 0); err != nil {
+			return err
+		}
+	}
+	if hold.PaymentID != "" {
+		payment, err := s.payments.GetPayment(ctx, hold.PaymentID)
+		if err != nil {
+			return err
+		}
+		if payment.Status != domain.PaymentCaptured {
+			// Seats sold under the hold keep their payment until captured
+			if s.soldUnderHold(ctx, hold) {
+				return nil
+			}
+			if err := s.payments.VoidPayment(ctx, payment.ID); err != nil {
+				return err
+			}
+			if err := s.orderRepo.CancelPending(ctx, payment.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return s.holdRepo.Delete(ctx, holdID)
 }
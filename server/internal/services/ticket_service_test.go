@@ -0,0 +1,274 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flashtix/server/internal/domain"
+	"github.com/flashtix/server/internal/payments"
+	"github.com/flashtix/server/internal/repository/memory"
+	"github.com/shopspring/decimal"
+)
+
+// conflictTicketRepo refuses every reservation as if the database found
+// the first seat taken
+type conflictTicketRepo struct {
+	domain.TicketRepository
+}
+
+func (r *conflictTicketRepo) ReserveSeats(ctx context.Context, eventID string, seats []string, lockTokens []int64, userID, holdID string, duration time.Duration) (domain.SeatResult, []string, error) {
+	return domain.SeatTaken, seats[:1], nil
+}
+
+func TestReserveSeatsKeepsEarlierLocksOnFailure(t *testing.T) {
+	ctx := context.Background()
+	locks := memory.NewSeatLockRepository()
+	events := &singleEventRepo{event: &domain.Event{ID: "event1"}}
+	service := NewTicketService(&conflictTicketRepo{}, events, nil, nil, nil, locks, nil, OrderPricing{})
+
+	// A1 is held by an earlier reservation of the same user
+	if _, _, err := locks.LockSeats(ctx, "event1", []string{"A1"}, "user123", time.Minute, 0); err != nil {
+		t.Fatalf("LockSeats failed: %v", err)
+	}
+
+	_, err := service.ReserveSeats(ctx, "event1", []string{"A1", "A2"}, "user123")
+	var conflict *domain.SeatConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("Expected a seat conflict, got %v", err)
+	}
+	if holder, _ := locks.IsSeatLocked(ctx, "event1", "A1"); holder != "user123" {
+		t.Errorf("Expected the earlier lock on A1 to survive, got holder %q", holder)
+	}
+	if holder, _ := locks.IsSeatLocked(ctx, "event1", "A2"); holder != "" {
+		t.Errorf("Expected the lock taken on A2 to be released, got holder %q", holder)
+	}
+}
+
+// extendHoldRepo serves one hold and records its extensions
+type extendHoldRepo struct {
+	domain.HoldRepository
+	hold     *domain.Hold
+	extended int
+}
+
+func (r *extendHoldRepo) GetByID(ctx context.Context, id string) (*domain.Hold, error) {
+	if id != r.hold.ID {
+		return nil, domain.ErrHoldNotFound
+	}
+	hold := *r.hold
+	return &hold, nil
+}
+
+func (r *extendHoldRepo) Extend(ctx context.Context, id, userID string, expiresAt time.Time, maxExtensions int) error {
+	r.extended++
+	r.hold.ExpiresAt = expiresAt
+	r.hold.Extensions++
+	return nil
+}
+
+func TestExtendHoldAfterLockLapsed(t *testing.T) {
+	ctx := context.Background()
+	locks := memory.NewSeatLockRepository()
+	holds := &extendHoldRepo{hold: &domain.Hold{
+		ID:        "hold1",
+		EventID:   "event1",
+		UserID:    "user123",
+		Seats:     []string{"A1", "A2"},
+		ExpiresAt: time.Now().Add(time.Minute),
+	}}
+	service := NewTicketService(nil, nil, holds, nil, nil, locks, nil, OrderPricing{})
+
+	// Only A1 is still locked; A2's lock lapsed and is free
+	locks.LockSeats(ctx, "event1", []string{"A1"}, "user123", time.Minute, 0)
+	hold, err := service.ExtendHold(ctx, "hold1", "user123")
+	if err != nil {
+		t.Fatalf("ExtendHold failed: %v", err)
+	}
+	if hold.Extensions != 1 || holds.extended != 1 {
+		t.Errorf("Expected the hold to be extended once, got %d extensions", hold.Extensions)
+	}
+	if holder, _ := locks.IsSeatLocked(ctx, "event1", "A2"); holder != "user123" {
+		t.Errorf("Expected the lapsed lock on A2 to be taken again, got holder %q", holder)
+	}
+
+	// Someone else got A2 after its lock lapsed again
+	holds.hold.Extensions = 0
+	locks.UnlockSeat(ctx, "event1", "A2", "user123")
+	locks.LockSeat(ctx, "event1", "A2", "user456", time.Minute)
+	if _, err := service.ExtendHold(ctx, "hold1", "user123"); !errors.Is(err, domain.ErrSeatTaken) {
+		t.Fatalf("Expected ErrSeatTaken, got %v", err)
+	}
+	if holds.extended != 1 {
+		t.Errorf("Expected the hold not to be extended again, got %d extensions", holds.extended)
+	}
+}
+
+func TestExtendHoldAfterTierClaimLapsed(t *testing.T) {
+	ctx := context.Background()
+	locks := memory.NewSeatLockRepository()
+	holds := &extendHoldRepo{hold: &domain.Hold{
+		ID:        "hold1",
+		EventID:   "event1",
+		UserID:    "user123",
+		TierID:    "tier1",
+		Seats:     []string{"Floor-1", "Floor-2"},
+		ExpiresAt: time.Now().Add(time.Minute),
+	}}
+	service := NewTicketService(nil, nil, holds, nil, nil, locks, nil, OrderPricing{})
+
+	// The claim lapsed and its tickets went to another buyer
+	locks.SeedTierStock(ctx, "event1", "tier1", 2)
+	if err := locks.ClaimTierStock(ctx, "event1", "tier1", "hold2", "user456", 2, time.Minute, 0); err != nil {
+		t.Fatalf("ClaimTierStock failed: %v", err)
+	}
+	if _, err := service.ExtendHold(ctx, "hold1", "user123"); !errors.Is(err, domain.ErrReservationExpired) {
+		t.Fatalf("Expected ErrReservationExpired, got %v", err)
+	}
+	if holds.extended != 0 {
+		t.Errorf("Expected the hold not to be extended, got %d extensions", holds.extended)
+	}
+
+	// Once the tickets are back the claim is taken again
+	locks.SettleTierClaim(ctx, "event1", "tier1", "hold2", "user456", 0)
+	if _, err := service.ExtendHold(ctx, "hold1", "user123"); err != nil {
+		t.Fatalf("ExtendHold failed: %v", err)
+	}
+	if available, _, _ := locks.TierStock(ctx, "event1", "tier1"); available != 0 {
+		t.Errorf("Expected both tickets claimed again, got %d available", available)
+	}
+}
+
+// flakyCaptureProvider fails the first capture of every payment
+type flakyCaptureProvider struct {
+	*payments.FakeProvider
+	failed map[string]bool
+}
+
+func (p *flakyCaptureProvider) CapturePayment(ctx context.Context, id string, amount decimal.Decimal) (*domain.Payment, error) {
+	if !p.failed[id] {
+		p.failed[id] = true
+		return nil, errors.New("provider unavailable")
+	}
+	return p.FakeProvider.CapturePayment(ctx, id, amount)
+}
+
+// holdOrderRepo serves the order of one hold and keeps its updates
+type holdOrderRepo struct {
+	domain.OrderRepository
+	order *domain.Order
+}
+
+func (r *holdOrderRepo) GetByHoldID(ctx context.Context, holdID string) (*domain.Order, error) {
+	if holdID != r.order.HoldID {
+		return nil, domain.ErrOrderNotFound
+	}
+	order := *r.order
+	order.Items = append([]domain.OrderItem(nil), r.order.Items...)
+	return &order, nil
+}
+
+func (r *holdOrderRepo) Update(ctx context.Context, order *domain.Order) error {
+	updated := *order
+	r.order = &updated
+	return nil
+}
+
+func TestConfirmHoldRetriesFailedCapture(t *testing.T) {
+	ctx := context.Background()
+	provider := &flakyCaptureProvider{FakeProvider: payments.NewFakeProvider(), failed: make(map[string]bool)}
+	payment, _ := provider.CreatePayment(ctx, "hold1", decimal.NewFromInt(100), "USD")
+
+	// Both seats were sold under the hold but the payment not yet captured
+	holds := &extendHoldRepo{hold: &domain.Hold{
+		ID:        "hold1",
+		EventID:   "event1",
+		UserID:    "user123",
+		Sold:      []string{"A1", "A2"},
+		PaymentID: payment.ID,
+		ExpiresAt: time.Now().Add(time.Minute),
+	}}
+	orders := &holdOrderRepo{order: &domain.Order{
+		ID: "order1", UserID: "user123", EventID: "event1", HoldID: "hold1", PaymentID: payment.ID,
+		Currency: "USD", Status: domain.OrderPending,
+		Items: []domain.OrderItem{
+			{TicketID: "t1", Seat: "A1", Price: decimal.NewFromInt(50)},
+			{TicketID: "t2", Seat: "A2", Price: decimal.NewFromInt(50)},
+		},
+	}}
+	events := &singleEventRepo{event: &domain.Event{ID: "event1", Currency: "USD"}}
+	service := NewTicketService(nil, events, holds, nil, orders, memory.NewSeatLockRepository(), provider, OrderPricing{})
+
+	// Releasing the hold must not void the payment of the sold seats
+	if err := service.ReleaseHold(ctx, "hold1", "user123"); err != nil {
+		t.Fatalf("ReleaseHold failed: %v", err)
+	}
+	if current, _ := provider.GetPayment(ctx, payment.ID); current.Status != domain.PaymentAuthorized {
+		t.Fatalf("Expected the payment to stay authorized, got %s", current.Status)
+	}
+
+	if _, err := service.ConfirmHold(ctx, "hold1", "user123"); err == nil {
+		t.Fatal("Expected the first capture to fail")
+	}
+	if orders.order.Status != domain.OrderPending {
+		t.Errorf("Expected the order to stay pending, got %s", orders.order.Status)
+	}
+
+	sold, err := service.ConfirmHold(ctx, "hold1", "user123")
+	if err != nil {
+		t.Fatalf("ConfirmHold failed: %v", err)
+	}
+	if len(sold) != 2 || orders.order.Status != domain.OrderPaid || !orders.order.Total.Equal(decimal.NewFromInt(100)) {
+		t.Errorf("Expected both seats paid for 100, got %v and order %s of %s", sold, orders.order.Status, orders.order.Total)
+	}
+	if current, _ := provider.GetPayment(ctx, payment.ID); current.Status != domain.PaymentCaptured {
+		t.Errorf("Expected the payment to be captured, got %s", current.Status)
+	}
+}
+
+func TestHoverLockOnlyAvailableSeats(t *testing.T) {
+	ctx := context.Background()
+	locks := memory.NewSeatLockRepository()
+	repo := &eventTicketRepo{tickets: []*domain.Ticket{
+		{Seat: "A1", Status: domain.TicketAvailable},
+		{Seat: "A2", Status: domain.TicketSold, UserID: "user456"},
+	}}
+	events := &singleEventRepo{event: &domain.Event{ID: "event1"}}
+	service := NewTicketService(repo, events, nil, nil, nil, locks, nil, OrderPricing{})
+	updates, unsubscribe := service.SeatEvents().Subscribe("event1")
+	defer unsubscribe()
+
+	if _, err := service.HoverLock(ctx, "event1", "A2", "user123"); !errors.Is(err, domain.ErrSeatTaken) {
+		t.Errorf("Expected ErrSeatTaken for a sold seat, got %v", err)
+	}
+	if _, err := service.HoverLock(ctx, "event1", "Z99", "user123"); !errors.Is(err, domain.ErrTicketNotFound) {
+		t.Errorf("Expected ErrTicketNotFound for an unknown seat, got %v", err)
+	}
+	if holder, _ := locks.IsSeatLocked(ctx, "event1", "A2"); holder != "" {
+		t.Errorf("Expected the sold seat to stay unlocked, got holder %q", holder)
+	}
+	select {
+	case update := <-updates:
+		t.Fatalf("Expected no seat update, got %+v", update)
+	default:
+	}
+
+	if _, err := service.HoverLock(ctx, "event1", "A1", "user123"); err != nil {
+		t.Fatalf("HoverLock failed: %v", err)
+	}
+	if update := <-updates; update.Type != domain.SeatEventLocked {
+		t.Errorf("Expected the available seat to be announced locked, got %+v", update)
+	}
+
+	// A seat sold since it was locked is not announced as released
+	repo.tickets[0].Status = domain.TicketSold
+	if err := service.ReleaseHoverLock(ctx, "event1", "A1", "user123"); err != nil {
+		t.Fatalf("ReleaseHoverLock failed: %v", err)
+	}
+	select {
+	case update := <-updates:
+		t.Errorf("Expected no release of a sold seat, got %+v", update)
+	default:
+	}
+}
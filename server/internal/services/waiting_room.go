@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/flashtix/server/internal/domain"
+)
+
+// queueToken is the signed claim a buyer receives on joining a waiting room
+type queueToken struct {
+	EventID   string `json:"e"`
+	UserID    string `json:"u"`
+	Number    int64  `json:"n"`
+	ExpiresAt int64  `json:"x"`
+}
+
+// WaitingRoom queues buyers per event and admits them at a fixed rate.
+// Buyers hold a signed position token; only tokens whose number is within
+// the admitted watermark may enter the purchase flow.
+type WaitingRoom struct {
+	store     domain.WaitingRoomStore
+	secret    []byte
+	admitRate int64 // buyers admitted per second per event
+	tokenTTL  time.Duration
+}
+
+func NewWaitingRoom(store domain.WaitingRoomStore, secret string, admitRate int) *WaitingRoom {
+	if admitRate < 1 {
+		admitRate = 1
+	}
+	return &WaitingRoom{
+		store:     store,
+		secret:    []byte(secret),
+		admitRate: int64(admitRate),
+		tokenTTL:  6 * time.Hour,
+	}
+}
+
+// Join queues the user for the event and returns their position token.
+// Joining again returns the same place in line.
+func (w *WaitingRoom) Join(ctx context.Context, eventID, userID string) (string, *domain.QueueStatus, error) {
+	number, err := w.store.Join(ctx, eventID, userID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token := w.sign(queueToken{
+		EventID:   eventID,
+		UserID:    userID,
+		Number:    number,
+		ExpiresAt: time.Now().Add(w.tokenTTL).Unix(),
+	})
+	status, err := w.status(ctx, eventID, number)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, status, nil
+}
+
+// Status reports the position and estimated wait of the token's holder
+func (w *WaitingRoom) Status(ctx context.Context, eventID, userID, token string) (*domain.QueueStatus, error) {
+	claim, err := w.verify(token, eventID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return w.status(ctx, eventID, claim.Number)
+}
+
+// Admit fails with domain.ErrInvalidQueueToken or domain.ErrNotAdmitted
+// unless the token lets the user buy tickets for the event now
+func (w *WaitingRoom) Admit(ctx context.Context, eventID, userID, token string) error {
+	status, err := w.Status(ctx, eventID, userID, token)
+	if err != nil {
+		return err
+	}
+	if !status.Admitted {
+		return domain.ErrNotAdmitted
+	}
+	return nil
+}
+
+// Run admits the next admitRate buyers of every waiting event each second
+// until ctx is cancelled. Every replica may run it: the store applies each
+// second's admissions only once.
+func (w *WaitingRoom) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := w.advance(ctx, now.Unix()); err != nil && ctx.Err() == nil {
+				log.Println("Waiting room admission failed:", err)
+			}
+		}
+	}
+}
+
+func (w *WaitingRoom) advance(ctx context.Context, tick int64) error {
+	events, err := w.store.Events(ctx)
+	if err != nil {
+		return err
+	}
+	for _, eventID := range events {
+		if _, err := w.store.Advance(ctx, eventID, tick, w.admitRate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *WaitingRoom) status(ctx context.Context, eventID string, number int64) (*domain.QueueStatus, error) {
+	admitted, err := w.store.Admitted(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &domain.QueueStatus{EventID: eventID, Admitted: number <= admitted}
+	if !status.Admitted {
+		status.Position = number - admitted
+		status.EstimatedWaitSeconds = (status.Position + w.admitRate - 1) / w.admitRate
+	}
+	return status, nil
+}
+
+// sign encodes the claim as base64url(payload).base64url(HMAC-SHA256)
+func (w *WaitingRoom) sign(claim queueToken) string {
+	payload, _ := json.Marshal(claim)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(w.mac(encoded))
+}
+
+// verify checks the token signature and expiry and that it was issued to
+// userID for eventID
+func (w *WaitingRoom) verify(token, eventID, userID string) (*queueToken, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, domain.ErrInvalidQueueToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, w.mac(encoded)) {
+		return nil, domain.ErrInvalidQueueToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, domain.ErrInvalidQueueToken
+	}
+	var claim queueToken
+	if err := json.Unmarshal(payload, &claim); err != nil {
+		return nil, domain.ErrInvalidQueueToken
+	}
+	if claim.EventID != eventID || claim.UserID != userID || time.Now().Unix() > claim.ExpiresAt {
+		return nil, domain.ErrInvalidQueueToken
+	}
+	return &claim, nil
+}
+
+func (w *WaitingRoom) mac(payload string) []byte {
+	h := hmac.New(sha256.New, w.secret)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
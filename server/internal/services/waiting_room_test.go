@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/flashtix/server/internal/domain"
+	"github.com/flashtix/server/internal/repository/memory"
+)
+
+func TestWaitingRoom(t *testing.T) {
+	ctx := context.Background()
+	room := NewWaitingRoom(memory.NewWaitingRoomRepository(), "secret", 2)
+
+	tokens := make([]string, 3)
+	for i, userID := range []string{"user1", "user2", "user3"} {
+		token, status, err := room.Join(ctx, "event1", userID)
+		if err != nil {
+			t.Fatalf("Join failed: %v", err)
+		}
+		if status.Position != int64(i+1) {
+			t.Errorf("Expected position %d, got %d", i+1, status.Position)
+		}
+		tokens[i] = token
+	}
+
+	if err := room.Admit(ctx, "event1", "user1", tokens[0]); !errors.Is(err, domain.ErrNotAdmitted) {
+		t.Errorf("Expected ErrNotAdmitted before admission, got %v", err)
+	}
+
+	// A second advance in the same tick admits nobody else
+	room.advance(ctx, 1)
+	room.advance(ctx, 1)
+
+	if err := room.Admit(ctx, "event1", "user2", tokens[1]); err != nil {
+		t.Errorf("Expected user2 to be admitted, got %v", err)
+	}
+	status, err := room.Status(ctx, "event1", "user3", tokens[2])
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.Admitted || status.Position != 1 || status.EstimatedWaitSeconds != 1 {
+		t.Errorf("Expected user3 next in line, got %+v", status)
+	}
+
+	// Tokens are bound to the user and event and cannot be altered
+	if err := room.Admit(ctx, "event1", "user3", tokens[0]); !errors.Is(err, domain.ErrInvalidQueueToken) {
+		t.Errorf("Expected another user's token to be rejected, got %v", err)
+	}
+	if err := room.Admit(ctx, "event2", "user1", tokens[0]); !errors.Is(err, domain.ErrInvalidQueueToken) {
+		t.Errorf("Expected token for another event to be rejected, got %v", err)
+	}
+	if err := room.Admit(ctx, "event1", "user1", tokens[0]+"x"); !errors.Is(err, domain.ErrInvalidQueueToken) {
+		t.Errorf("Expected tampered token to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,2 @@
+-- AlterTable
+ALTER TABLE "tickets" ADD COLUMN "lock_token" BIGINT NOT NULL DEFAULT 0;
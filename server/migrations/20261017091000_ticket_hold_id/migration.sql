@@ -0,0 +1,5 @@
+-- AlterTable
+ALTER TABLE "tickets" ADD COLUMN "hold_id" TEXT;
+
+-- CreateIndex
+CREATE INDEX "tickets_hold_id_idx" ON "tickets"("hold_id");
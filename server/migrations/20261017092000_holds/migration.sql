@@ -0,0 +1,30 @@
+-- CreateTable
+CREATE TABLE "holds" (
+    "id" TEXT NOT NULL,
+    "event_id" TEXT NOT NULL,
+    "user_id" TEXT NOT NULL,
+    "expires_at" TIMESTAMP(6) NOT NULL,
+    "extensions" INTEGER NOT NULL DEFAULT 0,
+    "created_at" TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP(6) NOT NULL,
+
+    CONSTRAINT "holds_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE INDEX "holds_user_id_idx" ON "holds"("user_id");
+
+-- CreateIndex
+CREATE INDEX "holds_expires_at_idx" ON "holds"("expires_at");
+
+-- Clear hold ids that have no hold row before adding the foreign key
+UPDATE "tickets" SET "hold_id" = NULL WHERE "hold_id" IS NOT NULL;
+
+-- AddForeignKey
+ALTER TABLE "tickets" ADD CONSTRAINT "tickets_hold_id_fkey" FOREIGN KEY ("hold_id") REFERENCES "holds"("id") ON DELETE SET NULL ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "holds" ADD CONSTRAINT "holds_event_id_fkey" FOREIGN KEY ("event_id") REFERENCES "events"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "holds" ADD CONSTRAINT "holds_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,3 @@
+-- AlterTable
+ALTER TABLE "events" ADD COLUMN "max_held_per_user" INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN "max_tickets_per_user" INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+-- AlterTable
+ALTER TABLE "holds" ADD COLUMN "payment_id" TEXT;
+
+-- CreateIndex
+CREATE UNIQUE INDEX "holds_payment_id_key" ON "holds"("payment_id");
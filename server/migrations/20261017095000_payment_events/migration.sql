@@ -0,0 +1,22 @@
+-- CreateTable
+CREATE TABLE "payment_events" (
+    "id" TEXT NOT NULL,
+    "type" TEXT,
+    "provider_type" TEXT NOT NULL,
+    "payment_id" TEXT,
+    "hold_id" TEXT,
+    "payload" JSONB NOT NULL,
+    "result" TEXT,
+    "deliveries" INTEGER NOT NULL DEFAULT 1,
+    "created_at" TIMESTAMP(6) NOT NULL,
+    "received_at" TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "processed_at" TIMESTAMP(6),
+
+    CONSTRAINT "payment_events_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE INDEX "payment_events_payment_id_idx" ON "payment_events"("payment_id");
+
+-- CreateIndex
+CREATE INDEX "payment_events_hold_id_idx" ON "payment_events"("hold_id");
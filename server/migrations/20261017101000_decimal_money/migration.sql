@@ -0,0 +1,18 @@
+-- AlterTable: every hold so far was charged in US dollars, so existing
+-- events keep that currency and their prices mean what they meant; only
+-- new events take the default
+ALTER TABLE "events" ADD COLUMN "currency" VARCHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE "events" ALTER COLUMN "currency" SET DEFAULT 'IDR';
+
+UPDATE "orders" SET "currency" = UPPER("currency");
+
+-- AlterTable: convert binary floats to exact decimals, rounded to cents
+ALTER TABLE "tickets" ALTER COLUMN "price" TYPE DECIMAL(12,2) USING ROUND("price"::numeric, 2),
+ALTER COLUMN "price" SET DEFAULT 0;
+
+ALTER TABLE "orders" ALTER COLUMN "subtotal" TYPE DECIMAL(14,2) USING ROUND("subtotal"::numeric, 2),
+ALTER COLUMN "fees" TYPE DECIMAL(14,2) USING ROUND("fees"::numeric, 2),
+ALTER COLUMN "taxes" TYPE DECIMAL(14,2) USING ROUND("taxes"::numeric, 2),
+ALTER COLUMN "total" TYPE DECIMAL(14,2) USING ROUND("total"::numeric, 2);
+
+ALTER TABLE "order_items" ALTER COLUMN "price" TYPE DECIMAL(12,2) USING ROUND("price"::numeric, 2);
@@ -0,0 +1,47 @@
+-- AlterEnum
+ALTER TYPE "TicketStatus" ADD VALUE 'REFUNDED';
+
+-- CreateEnum
+CREATE TYPE "RefundStatus" AS ENUM ('PENDING', 'SUCCEEDED', 'FAILED');
+
+-- AlterTable
+ALTER TABLE "events" ADD COLUMN "refundable" BOOLEAN NOT NULL DEFAULT false,
+ADD COLUMN "refund_cutoff_hours" INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN "refund_fee_per_ticket" DECIMAL(12,2) NOT NULL DEFAULT 0,
+ADD COLUMN "restock_refunds" BOOLEAN NOT NULL DEFAULT false;
+
+-- AlterTable
+ALTER TABLE "orders" ADD COLUMN "refunded" DECIMAL(14,2) NOT NULL DEFAULT 0;
+
+-- AlterTable
+ALTER TABLE "order_items" ADD COLUMN "refund_id" TEXT;
+
+-- CreateTable
+CREATE TABLE "refunds" (
+    "id" TEXT NOT NULL,
+    "order_id" TEXT NOT NULL,
+    "payment_id" TEXT NOT NULL,
+    "provider_refund_id" TEXT,
+    "amount" DECIMAL(14,2) NOT NULL,
+    "fee" DECIMAL(14,2) NOT NULL DEFAULT 0,
+    "currency" VARCHAR(3) NOT NULL,
+    "restock" BOOLEAN NOT NULL DEFAULT false,
+    "requested_by" TEXT NOT NULL,
+    "status" "RefundStatus" NOT NULL DEFAULT 'PENDING',
+    "created_at" TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP(6) NOT NULL,
+
+    CONSTRAINT "refunds_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE INDEX "refunds_order_id_idx" ON "refunds"("order_id");
+
+-- CreateIndex
+CREATE INDEX "order_items_refund_id_idx" ON "order_items"("refund_id");
+
+-- AddForeignKey
+ALTER TABLE "refunds" ADD CONSTRAINT "refunds_order_id_fkey" FOREIGN KEY ("order_id") REFERENCES "orders"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "order_items" ADD CONSTRAINT "order_items_refund_id_fkey" FOREIGN KEY ("refund_id") REFERENCES "refunds"("id") ON DELETE SET NULL ON UPDATE CASCADE;
@@ -0,0 +1,27 @@
+-- AlterEnum
+ALTER TYPE "TicketStatus" ADD VALUE 'CHECKED_IN';
+
+-- CreateFunction
+-- Mirrors the transition table in internal/domain/ticket_status.go
+CREATE FUNCTION "check_ticket_transition"() RETURNS trigger AS $$
+BEGIN
+    IF NEW."status" IS DISTINCT FROM OLD."status" AND (OLD."status"::text, NEW."status"::text) NOT IN (
+        ('AVAILABLE', 'RESERVED'),
+        ('RESERVED', 'SOLD'),
+        ('RESERVED', 'AVAILABLE'),
+        ('SOLD', 'REFUNDED'),
+        ('SOLD', 'CHECKED_IN'),
+        ('REFUNDED', 'AVAILABLE')
+    ) THEN
+        -- FT001 is matched by ticketWriteError in internal/repository/postgres
+        RAISE EXCEPTION 'illegal ticket transition from % to % of seat %', OLD."status", NEW."status", NEW."seat"
+            USING ERRCODE = 'FT001';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- CreateTrigger
+CREATE TRIGGER "tickets_status_transition"
+BEFORE UPDATE OF "status" ON "tickets"
+FOR EACH ROW EXECUTE FUNCTION "check_ticket_transition"();
@@ -0,0 +1,81 @@
+-- CreateTable
+CREATE TABLE "venues" (
+    "id" TEXT NOT NULL,
+    "name" VARCHAR(255) NOT NULL,
+    "address" TEXT NOT NULL DEFAULT '',
+    "created_at" TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP(6) NOT NULL,
+
+    CONSTRAINT "venues_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateTable
+CREATE TABLE "venue_sections" (
+    "id" TEXT NOT NULL,
+    "venue_id" TEXT NOT NULL,
+    "name" VARCHAR(50) NOT NULL,
+    "position" INTEGER NOT NULL,
+
+    CONSTRAINT "venue_sections_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateTable
+CREATE TABLE "venue_rows" (
+    "id" TEXT NOT NULL,
+    "section_id" TEXT NOT NULL,
+    "name" VARCHAR(20) NOT NULL,
+    "position" INTEGER NOT NULL,
+
+    CONSTRAINT "venue_rows_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateTable
+CREATE TABLE "venue_seats" (
+    "id" TEXT NOT NULL,
+    "row_id" TEXT NOT NULL,
+    "number" INTEGER NOT NULL,
+    "label" VARCHAR(50) NOT NULL,
+    "x" DOUBLE PRECISION NOT NULL DEFAULT 0,
+    "y" DOUBLE PRECISION NOT NULL DEFAULT 0,
+    "accessible" BOOLEAN NOT NULL DEFAULT false,
+    "restricted_view" BOOLEAN NOT NULL DEFAULT false,
+    "aisle" BOOLEAN NOT NULL DEFAULT false,
+
+    CONSTRAINT "venue_seats_pkey" PRIMARY KEY ("id")
+);
+
+-- AlterTable
+ALTER TABLE "events" ADD COLUMN "venue_id" TEXT;
+
+-- AlterTable
+ALTER TABLE "tickets" ADD COLUMN "seat_id" TEXT;
+
+-- CreateIndex
+CREATE UNIQUE INDEX "venue_sections_venue_id_name_key" ON "venue_sections"("venue_id", "name");
+
+-- CreateIndex
+CREATE UNIQUE INDEX "venue_rows_section_id_name_key" ON "venue_rows"("section_id", "name");
+
+-- CreateIndex
+CREATE UNIQUE INDEX "venue_seats_row_id_number_key" ON "venue_seats"("row_id", "number");
+
+-- CreateIndex
+CREATE INDEX "events_venue_id_idx" ON "events"("venue_id");
+
+-- CreateIndex
+CREATE INDEX "tickets_seat_id_idx" ON "tickets"("seat_id");
+
+-- AddForeignKey
+ALTER TABLE "venue_sections" ADD CONSTRAINT "venue_sections_venue_id_fkey" FOREIGN KEY ("venue_id") REFERENCES "venues"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "venue_rows" ADD CONSTRAINT "venue_rows_section_id_fkey" FOREIGN KEY ("section_id") REFERENCES "venue_sections"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "venue_seats" ADD CONSTRAINT "venue_seats_row_id_fkey" FOREIGN KEY ("row_id") REFERENCES "venue_rows"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "events" ADD CONSTRAINT "events_venue_id_fkey" FOREIGN KEY ("venue_id") REFERENCES "venues"("id") ON DELETE RESTRICT ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "tickets" ADD CONSTRAINT "tickets_seat_id_fkey" FOREIGN KEY ("seat_id") REFERENCES "venue_seats"("id") ON DELETE RESTRICT ON UPDATE CASCADE;
@@ -0,0 +1,40 @@
+-- CreateEnum
+CREATE TYPE "TierKind" AS ENUM ('RESERVED', 'GENERAL_ADMISSION');
+
+-- CreateTable
+CREATE TABLE "ticket_tiers" (
+    "id" TEXT NOT NULL,
+    "event_id" TEXT NOT NULL,
+    "name" VARCHAR(43) NOT NULL,
+    "kind" "TierKind" NOT NULL,
+    "price" DECIMAL(12,2) NOT NULL DEFAULT 0,
+    "quantity" INTEGER NOT NULL DEFAULT 0,
+    "sections" TEXT[],
+    "created_at" TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT "ticket_tiers_pkey" PRIMARY KEY ("id")
+);
+
+-- AlterTable
+ALTER TABLE "tickets" ADD COLUMN "tier_id" TEXT;
+
+-- AlterTable
+ALTER TABLE "holds" ADD COLUMN "tier_id" TEXT;
+
+-- CreateIndex
+CREATE UNIQUE INDEX "ticket_tiers_event_id_name_key" ON "ticket_tiers"("event_id", "name");
+
+-- CreateIndex
+CREATE INDEX "tickets_tier_id_status_idx" ON "tickets"("tier_id", "status");
+
+-- CreateIndex
+CREATE INDEX "holds_tier_id_user_id_idx" ON "holds"("tier_id", "user_id");
+
+-- AddForeignKey
+ALTER TABLE "ticket_tiers" ADD CONSTRAINT "ticket_tiers_event_id_fkey" FOREIGN KEY ("event_id") REFERENCES "events"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "tickets" ADD CONSTRAINT "tickets_tier_id_fkey" FOREIGN KEY ("tier_id") REFERENCES "ticket_tiers"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "holds" ADD CONSTRAINT "holds_tier_id_fkey" FOREIGN KEY ("tier_id") REFERENCES "ticket_tiers"("id") ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,2 @@
+-- CreateIndex
+CREATE UNIQUE INDEX "tickets_event_id_seat_id_key" ON "tickets"("event_id", "seat_id");